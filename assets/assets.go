@@ -0,0 +1,43 @@
+// Package assets holds the files the bot uses at runtime, embedded in the binary so it doesn't depend on the
+// working directory or on downloading them
+package assets
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+//go:embed emojis/*
+var emojis embed.FS
+
+//go:embed overlay/index.html
+var overlayPage []byte
+
+// OverlayPage is the HTML of the stream overlay
+func OverlayPage() []byte {
+	return overlayPage
+}
+
+// EmojiImage returns the image of an emoji by its name (like "aured"), whatever format it's stored in
+func EmojiImage(name string) ([]byte, error) {
+	entries, err := emojis.ReadDir("emojis")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range entries {
+		if strings.TrimSuffix(v.Name(), path.Ext(v.Name())) == name {
+			return emojis.ReadFile("emojis/" + v.Name())
+		}
+	}
+	return nil, fmt.Errorf("no emoji asset named %s", name)
+}
+
+// Hash identifies the contents of an asset, so changes to it can be noticed
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+// Package bus carries messages between the processes of a sharded bot, like capture events that reached a process
+// which doesn't own the capture's guild
+package bus
+
+import "errors"
+
+// ErrNoSubscribers is returned when nothing is listening to the topic a message was published to
+var ErrNoSubscribers = errors.New("nothing is subscribed to the topic")
+
+// Handler is called with every message published to a topic it's subscribed to
+type Handler func(data []byte)
+
+// Bus delivers messages to whatever is subscribed to their topic, in whichever process that is. Messages published
+// one after another to the same topic are delivered in that order
+type Bus interface {
+	// Publish delivers a message to the topic's subscribers, and returns once they have it
+	Publish(topic string, data []byte) error
+	// Subscribe calls handler with every message published to the topic from now on
+	Subscribe(topic string, handler Handler) error
+	// Close stops delivering messages
+	Close() error
+}
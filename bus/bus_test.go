@@ -0,0 +1,160 @@
+package bus
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+//inbox collects the messages a handler gets
+type inbox struct {
+	messages []string
+	lock     sync.Mutex
+}
+
+func (in *inbox) handle(data []byte) {
+	in.lock.Lock()
+	in.messages = append(in.messages, string(data))
+	in.lock.Unlock()
+}
+
+func (in *inbox) check(t *testing.T, want ...string) {
+	t.Helper()
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	if len(in.messages) != len(want) {
+		t.Fatalf("got %q, want %q", in.messages, want)
+	}
+	for i := range want {
+		if in.messages[i] != want[i] {
+			t.Errorf("got %q, want %q", in.messages, want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	first, second := &inbox{}, &inbox{}
+	m.Subscribe("topic", first.handle)
+	m.Subscribe("topic", second.handle)
+
+	if err := m.Publish("other", []byte("lost")); err != ErrNoSubscribers {
+		t.Errorf("publishing to a topic nothing subscribed to returned %v, want %v", err, ErrNoSubscribers)
+	}
+	for _, v := range []string{"one", "two", "three"} {
+		if err := m.Publish("topic", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first.check(t, "one", "two", "three")
+	second.check(t, "one", "two", "three")
+	if !m.Subscribed("topic") || m.Subscribed("other") {
+		t.Error("Subscribed doesn't match what was subscribed to")
+	}
+
+	m.Close()
+	if m.Publish("topic", []byte("closed")) == nil || m.Subscribe("topic", first.handle) == nil {
+		t.Error("a closed bus still works")
+	}
+	first.check(t, "one", "two", "three")
+}
+
+func TestMemoryHandlersCanPublish(t *testing.T) {
+	m := NewMemory()
+	replies := &inbox{}
+	m.Subscribe("replies", replies.handle)
+	m.Subscribe("requests", func(data []byte) {
+		m.Publish("replies", append([]byte("re: "), data...))
+	})
+
+	if err := m.Publish("requests", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	replies.check(t, "re: hi")
+}
+
+//startHTTPPair starts two processes that own topics a and b, and reach each other over HTTP
+func startHTTPPair(t *testing.T) (a, b *HTTP, inboxA, inboxB *inbox) {
+	peers := map[string]string{}
+	a = NewHTTP(peers, "secret", time.Second)
+	b = NewHTTP(peers, "secret", time.Second)
+	inboxA, inboxB = &inbox{}, &inbox{}
+	a.Subscribe("a", inboxA.handle)
+	b.Subscribe("b", inboxB.handle)
+
+	for topic, bus := range map[string]*HTTP{"a": a, "b": b} {
+		mux := http.NewServeMux()
+		mux.Handle(HTTPPath, bus)
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		peers[topic] = server.URL
+	}
+	//c is owned by a, but nothing there subscribes to it
+	peers["c"] = peers["a"]
+	return a, b, inboxA, inboxB
+}
+
+func TestHTTP(t *testing.T) {
+	a, b, inboxA, inboxB := startHTTPPair(t)
+
+	for _, v := range []string{"one", "two"} {
+		if err := a.Publish("b", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Publish("a", []byte("back")); err != nil {
+		t.Fatal(err)
+	}
+	//topics subscribed to in the same process don't go over HTTP
+	if err := a.Publish("a", []byte("local")); err != nil {
+		t.Fatal(err)
+	}
+	inboxA.check(t, "back", "local")
+	inboxB.check(t, "one", "two")
+
+	for _, topic := range []string{"c", "unknown"} {
+		if err := b.Publish(topic, []byte("lost")); err != ErrNoSubscribers {
+			t.Errorf("publishing to %s returned %v, want %v", topic, err, ErrNoSubscribers)
+		}
+	}
+}
+
+func TestHTTPServeRejects(t *testing.T) {
+	bus := NewHTTP(nil, "secret", time.Second)
+	delivered := &inbox{}
+	bus.Subscribe("topic", delivered.handle)
+	body := []byte(`{"event":"state"}`)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		signature string
+		status    int
+	}{
+		{"a signed message", http.MethodPost, HTTPPath + "topic", webhook.Sign("secret", body), http.StatusNoContent},
+		{"a GET", http.MethodGet, HTTPPath + "topic", webhook.Sign("secret", body), http.StatusMethodNotAllowed},
+		{"no signature", http.MethodPost, HTTPPath + "topic", "", http.StatusUnauthorized},
+		{"another secret", http.MethodPost, HTTPPath + "topic", webhook.Sign("other", body), http.StatusUnauthorized},
+		{"another body", http.MethodPost, HTTPPath + "topic", webhook.Sign("secret", []byte("{}")), http.StatusUnauthorized},
+		{"an unowned topic", http.MethodPost, HTTPPath + "other", webhook.Sign("secret", body), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			req.Header.Set(webhook.SignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			bus.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("got HTTP %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+	//only the signed message was delivered
+	delivered.check(t, string(body))
+}
@@ -0,0 +1,111 @@
+package bus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+// HTTPPath is where HTTP buses are served, followed by the topic
+const HTTPPath = "/bus/"
+
+// maxHTTPMessage is the biggest message an HTTP bus accepts
+const maxHTTPMessage = 1024 * 1024
+
+// HTTP is a bus between processes that can reach each other's HTTP servers. Every topic is owned by one process:
+// messages for topics subscribed to in this process are delivered here, and the rest are POSTed to the process that
+// owns the topic, signed with the secret every process shares
+type HTTP struct {
+	local  *Memory
+	peers  map[string]string
+	secret string
+	client *http.Client
+}
+
+// NewHTTP makes an HTTP bus. peers maps each topic to the base URL of the process that owns it, like
+// http://bot-1:8123. The bus has to be served at HTTPPath for other processes to reach it
+func NewHTTP(peers map[string]string, secret string, timeout time.Duration) *HTTP {
+	return &HTTP{
+		local:  NewMemory(),
+		peers:  peers,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (b *HTTP) Publish(topic string, data []byte) error {
+	if b.local.Subscribed(topic) {
+		return b.local.Publish(topic, data)
+	}
+	base, ok := b.peers[topic]
+	if !ok {
+		return ErrNoSubscribers
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(base, "/")+HTTPPath+url.PathEscape(topic), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(b.secret, data))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNoSubscribers
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("%s answered HTTP %d", webhook.HostOf(base), resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *HTTP) Subscribe(topic string, handler Handler) error {
+	return b.local.Subscribe(topic, handler)
+}
+
+func (b *HTTP) Close() error {
+	return b.local.Close()
+}
+
+// ServeHTTP delivers messages POSTed by other processes to this process' subscribers
+func (b *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxHTTPMessage))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !webhook.Verify(b.secret, body, r.Header.Get(webhook.SignatureHeader)) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	topic, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), HTTPPath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//only topics this process subscribes to are delivered, so messages can't bounce between processes
+	if !b.local.Subscribed(topic) {
+		http.Error(w, ErrNoSubscribers.Error(), http.StatusNotFound)
+		return
+	}
+	if err := b.local.Publish(topic, body); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package bus
+
+import (
+	"errors"
+	"sync"
+)
+
+// Memory is a bus that only reaches subscribers in the same process. It's all a bot that isn't sharded needs, and is
+// handy for tests
+type Memory struct {
+	handlers map[string][]Handler
+	closed   bool
+	lock     sync.RWMutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		handlers: map[string][]Handler{},
+		lock:     sync.RWMutex{},
+	}
+}
+
+// Publish calls the topic's handlers one at a time, on the caller's goroutine
+func (m *Memory) Publish(topic string, data []byte) error {
+	m.lock.RLock()
+	if m.closed {
+		m.lock.RUnlock()
+		return errors.New("the bus is closed")
+	}
+	//the handlers are called without the lock, so they can publish or subscribe themselves
+	handlers := append([]Handler{}, m.handlers[topic]...)
+	m.lock.RUnlock()
+
+	if len(handlers) == 0 {
+		return ErrNoSubscribers
+	}
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(topic string, handler Handler) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return errors.New("the bus is closed")
+	}
+	m.handlers[topic] = append(m.handlers[topic], handler)
+	return nil
+}
+
+// Subscribed is true if anything in this process is subscribed to the topic
+func (m *Memory) Subscribed(topic string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.handlers[topic]) > 0
+}
+
+func (m *Memory) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = true
+	m.handlers = map[string][]Handler{}
+	return nil
+}
@@ -0,0 +1,54 @@
+// webhook-receiver prints the events the bot sends to a webhook, after checking their signature. It's for trying out
+// webhooks locally: run the bot with WEBHOOK_ALLOW_PRIVATE=true, then
+//
+//	go run ./cmd/webhook-receiver -secret <the secret the bot sent you>
+//	.au webhook add http://localhost:9000/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "secret of the webhook; defaults to WEBHOOK_SECRET")
+	flag.Parse()
+	if *secret == "" {
+		log.Println("No secret given, so signatures won't be checked")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *secret != "" && !webhook.Verify(*secret, body, r.Header.Get(webhook.SignatureHeader)) {
+			log.Printf("Rejected delivery %s: bad signature\n", r.Header.Get(webhook.DeliveryHeader))
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		pretty := bytes.NewBuffer([]byte{})
+		if json.Indent(pretty, body, "", "  ") != nil {
+			pretty = bytes.NewBuffer(body)
+		}
+		log.Printf("%s (delivery %s):\n%s\n", r.Header.Get(webhook.EventHeader), r.Header.Get(webhook.DeliveryHeader), pretty)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening for webhooks at %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
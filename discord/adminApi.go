@@ -0,0 +1,291 @@
+package discord
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+//set while the bot is connected to the discord gateway, and while the socket server is accepting connections
+var (
+	discordConnected      int32
+	socketServerListening int32
+)
+
+// trackGatewayConnection keeps discordConnected up to date. It has to be called before the session is opened
+func trackGatewayConnection(dg *discordgo.Session) {
+	dg.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		atomic.StoreInt32(&discordConnected, 1)
+	})
+	dg.AddHandler(func(s *discordgo.Session, d *discordgo.Disconnect) {
+		logger.Warn("Lost the connection to the discord gateway")
+		atomic.StoreInt32(&discordConnected, 0)
+	})
+}
+
+type healthStatus struct {
+	Discord      bool `json:"discord"`
+	SocketServer bool `json:"socketServer"`
+	Guilds       int  `json:"guilds"`
+}
+
+func currentHealth() healthStatus {
+	return healthStatus{
+		Discord:      atomic.LoadInt32(&discordConnected) == 1,
+		SocketServer: atomic.LoadInt32(&socketServerListening) == 1,
+		Guilds:       AllGuilds.Len(),
+	}
+}
+
+// healthzHandler answers as long as the bot is running, for liveness probes
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentHealth())
+}
+
+// readyzHandler only succeeds while the bot is connected to discord and accepting captures, for readiness probes
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	health := currentHealth()
+	status := http.StatusOK
+	if !health.Discord || !health.SocketServer {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+type apiGuild struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Language string    `json:"language"`
+	Games    []apiGame `json:"games,omitempty"`
+	NumGames int       `json:"numGames"`
+}
+
+type apiTrackedChannel struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ForGhosts bool   `json:"forGhosts"`
+}
+
+type apiPlayer struct {
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Alive    bool   `json:"alive"`
+	ClientID int    `json:"clientID,omitempty"`
+	//LinkedUserID is the discord user linked to the player, if any
+	LinkedUserID string `json:"linkedUserID,omitempty"`
+}
+
+type apiGame struct {
+	ID            string              `json:"id"`
+	Phase         string              `json:"phase"`
+	Room          string              `json:"room"`
+	Region        string              `json:"region"`
+	CaptureLinked bool                `json:"captureLinked"`
+	LinkCode      string              `json:"linkCode,omitempty"`
+	VoicePreset   string              `json:"voicePreset"`
+	Tracking      []apiTrackedChannel `json:"tracking"`
+	Players       []apiPlayer         `json:"players"`
+	//LinkedUsers maps linked discord user IDs to the color of their player
+	LinkedUsers map[string]string `json:"linkedUsers"`
+}
+
+func (guild *GuildState) toAPIGame(gs *GameState) apiGame {
+	room, region := gs.AmongUsData.GetRoomRegion()
+	phase := gs.AmongUsData.GetPhase()
+	ag := apiGame{
+		ID:            gs.ID,
+		Phase:         string(game.PhaseNames[phase]),
+		Room:          room,
+		Region:        region,
+		CaptureLinked: gs.GetLinkCode() == "",
+		LinkCode:      gs.GetLinkCode(),
+		VoicePreset:   gs.GetVoicePresetName(),
+		Tracking:      make([]apiTrackedChannel, 0),
+		Players:       make([]apiPlayer, 0),
+		LinkedUsers:   map[string]string{},
+	}
+	for _, v := range gs.Tracking.GetAll() {
+		ag.Tracking = append(ag.Tracking, apiTrackedChannel{ID: v.channelID, Name: v.channelName, ForGhosts: v.forGhosts})
+	}
+	linked := guild.UserData.GetLinkedUsers(gs.ID)
+	for _, v := range linked {
+		ag.LinkedUsers[v.GetID()] = game.GetColorStringForInt(v.GetColor())
+	}
+	for _, v := range gs.AmongUsData.GetAllPlayers() {
+		player := apiPlayer{Name: v.Name, Color: game.GetColorStringForInt(v.Color), Alive: v.IsAlive, ClientID: v.ClientID}
+		for _, user := range linked {
+			if user.GetColor() == v.Color && user.GetPlayerName() == v.Name {
+				player.LinkedUserID = user.GetID()
+				break
+			}
+		}
+		ag.Players = append(ag.Players, player)
+	}
+	return ag
+}
+
+func toAPIGuild(s *discordgo.Session, guild *GuildState, withGames bool) apiGuild {
+	ag := apiGuild{
+		ID:       guild.PersistentGuildData.GuildID,
+		Language: guild.PersistentGuildData.GetLanguage(),
+		NumGames: guild.Games.Size(),
+	}
+	if g, err := s.State.Guild(ag.ID); err == nil {
+		ag.Name = g.Name
+	}
+	if withGames {
+		ag.Games = make([]apiGame, 0)
+		for _, gs := range guild.Games.All() {
+			ag.Games = append(ag.Games, guild.toAPIGame(gs))
+		}
+	}
+	return ag
+}
+
+// adminAPIHandler serves the admin API under /api/, for anyone with the token:
+//
+//	GET  /api/guilds                                    every guild the bot is in
+//	GET  /api/guilds/{guildID}                          a guild's games, with their phase, players, links and tracking
+//	POST /api/guilds/{guildID}/games/{gameID}/phase     force a phase, with a body like {"phase": "tasks"}
+//	POST /api/guilds/{guildID}/games/{gameID}/end       end a game
+//	POST /api/guilds/{guildID}/games/{gameID}/linkcode  replace the code captures link to the game with
+func adminAPIHandler(s *discordgo.Session, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+		if parts[0] != "guilds" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		if len(parts) == 1 {
+			if r.Method != http.MethodGet {
+				writeError(w, http.StatusMethodNotAllowed, "use GET")
+				return
+			}
+			all := AllGuilds.All()
+			guilds := make([]apiGuild, 0, len(all))
+			for _, guild := range all {
+				guilds = append(guilds, toAPIGuild(s, guild, false))
+			}
+			sort.Slice(guilds, func(i, j int) bool {
+				return guilds[i].ID < guilds[j].ID
+			})
+			writeJSON(w, http.StatusOK, guilds)
+			return
+		}
+
+		guild, ok := AllGuilds.Get(parts[1])
+		if !ok {
+			writeError(w, http.StatusNotFound, "no guild with that ID")
+			return
+		}
+		if len(parts) == 2 {
+			if r.Method != http.MethodGet {
+				writeError(w, http.StatusMethodNotAllowed, "use GET")
+				return
+			}
+			writeJSON(w, http.StatusOK, toAPIGuild(s, guild, true))
+			return
+		}
+
+		if len(parts) != 5 || parts[2] != "games" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		gs := guild.Games.Get(parts[3])
+		if gs == nil {
+			writeError(w, http.StatusNotFound, "no game with that ID")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+
+		switch parts[4] {
+		case "phase":
+			body := struct {
+				Phase string `json:"phase"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, "expected a body like {\"phase\": \"tasks\"}")
+				return
+			}
+			phase := getPhaseFromArgs([]string{body.Phase})
+			if phase == game.UNINITIALIZED {
+				writeError(w, http.StatusBadRequest, "unknown phase; use lobby, tasks or discuss")
+				return
+			}
+			guild.gameLog(gs).Infof("Admin API forcing the game to %s", game.PhaseNames[phase])
+			queuePhaseUpdate(guild.PersistentGuildData.GuildID, PhaseUpdate{GameID: gs.ID, Phase: phase, By: byAdminAPI()})
+			writeJSON(w, http.StatusAccepted, guild.toAPIGame(gs))
+		case "end":
+			guild.gameLog(gs).Info("Admin API ending the game")
+			//ended by the guild's updatesListener, so it can't interleave with the game's phase and player updates
+			if !queuePhaseUpdate(guild.PersistentGuildData.GuildID, PhaseUpdate{GameID: gs.ID, By: byAdminAPI(), End: true}) {
+				writeError(w, http.StatusServiceUnavailable, "the game couldn't be ended right now; try again")
+				return
+			}
+			writeJSON(w, http.StatusAccepted, map[string]string{"ended": gs.ID})
+		case "linkcode":
+			code, ok := guild.rotateLinkCode(gs)
+			if !ok {
+				writeError(w, http.StatusConflict, "a capture is already linked to that game")
+				return
+			}
+			guild.gameLog(gs).Info("Admin API rotated the link code")
+			writeJSON(w, http.StatusOK, map[string]string{"linkCode": code})
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	})
+}
+
+// rotateLinkCode replaces the code captures can link to a game with, so the old one stops working. Games with a
+// capture linked don't have a code to replace
+func (guild *GuildState) rotateLinkCode(gs *GameState) (string, bool) {
+	key := GameKey{GuildID: guild.PersistentGuildData.GuildID, GameID: gs.ID}
+	LinkCodeLock.Lock()
+	if gs.LinkCode == "" {
+		LinkCodeLock.Unlock()
+		return "", false
+	}
+	for code, v := range LinkCodes {
+		if v == key {
+			delete(LinkCodes, code)
+		}
+	}
+	code := generateConnectCode(key.GuildID)
+	LinkCodes[code] = key
+	gs.LinkCode = code
+	LinkCodeLock.Unlock()
+
+	guild.requestStatusEdit(gs)
+	return code, true
+}
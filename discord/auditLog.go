@@ -0,0 +1,187 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// AuditLogWindow is how long audit records are collected before they're posted together
+const AuditLogWindow = 5 * time.Second
+
+// AuditLogMaxMessages is the most messages one batch of records is posted as; the rest wait for the next batch, so
+// a busy guild can't run into discord's rate limits
+const AuditLogMaxMessages = 3
+
+// AuditLogMaxPending is how many records can wait to be posted. Past that, the oldest are dropped
+const AuditLogMaxPending = 500
+
+// AuditLog collects the events of a guild, to be posted to its audit channel in batches
+type AuditLog struct {
+	pending []GuildEvent
+	dropped int
+	wake    chan struct{}
+	lock    sync.Mutex
+}
+
+func MakeAuditLog() *AuditLog {
+	return &AuditLog{
+		pending: []GuildEvent{},
+		wake:    make(chan struct{}, 1),
+		lock:    sync.Mutex{},
+	}
+}
+
+// Record queues an event to be posted. It never blocks
+func (al *AuditLog) Record(event GuildEvent) {
+	al.lock.Lock()
+	if len(al.pending) >= AuditLogMaxPending {
+		al.pending = al.pending[1:]
+		al.dropped++
+	}
+	al.pending = append(al.pending, event)
+	al.lock.Unlock()
+
+	select {
+	case al.wake <- struct{}{}:
+	default:
+		//the poster is already going to flush soon
+	}
+}
+
+func (al *AuditLog) takePending() ([]GuildEvent, int) {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	pending, dropped := al.pending, al.dropped
+	al.pending = []GuildEvent{}
+	al.dropped = 0
+	return pending, dropped
+}
+
+//putBack returns records that weren't posted to the front of the queue
+func (al *AuditLog) putBack(events []GuildEvent) {
+	al.lock.Lock()
+	al.pending = append(events, al.pending...)
+	if over := len(al.pending) - AuditLogMaxPending; over > 0 {
+		al.pending = al.pending[over:]
+		al.dropped += over
+	}
+	al.lock.Unlock()
+
+	select {
+	case al.wake <- struct{}{}:
+	default:
+	}
+}
+
+//auditLoop posts the recorded events to the audit channel, at most one batch per window
+func (guild *GuildState) auditLoop(s *discordgo.Session) {
+	for {
+		select {
+		case <-guild.ctx.Done():
+			return
+		case <-guild.AuditLog.wake:
+		}
+		//give any other events in the burst a chance to arrive
+		if !sleepContext(guild.ctx, AuditLogWindow) {
+			return
+		}
+
+		events, dropped := guild.AuditLog.takePending()
+		channelID := guild.PersistentGuildData.GetAuditChannelID()
+		if channelID == "" {
+			//the audit log was turned off while these were waiting
+			continue
+		}
+
+		messages := 0
+		buf := bytes.NewBuffer([]byte{})
+		if dropped > 0 {
+			buf.WriteString(guild.text("audit.dropped", dropped) + "\n")
+		}
+		for i, event := range events {
+			line := guild.auditLine(event) + "\n"
+			if buf.Len()+len(line) > maxMessageLength {
+				postAuditMessage(s, channelID, buf.String())
+				buf.Reset()
+				messages++
+				if messages == AuditLogMaxMessages {
+					guild.AuditLog.putBack(events[i:])
+					break
+				}
+			}
+			buf.WriteString(line)
+		}
+		if buf.Len() > 0 {
+			postAuditMessage(s, channelID, buf.String())
+		}
+	}
+}
+
+//postAuditMessage posts without pinging anyone that's mentioned
+func postAuditMessage(s *discordgo.Session, channelID, content string) {
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+//auditLine formats an event as one line of the audit log
+func (guild *GuildState) auditLine(event GuildEvent) string {
+	lang := guild.PersistentGuildData.GetLanguage()
+	by := guild.auditTrigger(event.By)
+	var line string
+	switch event.Type {
+	case GameStartEvent:
+		line = guild.text("audit.gameStart", event.GameID, by)
+	case GameEndEvent:
+		line = guild.text("audit.gameEnd", event.GameID, by)
+	case PhaseEvent:
+		line = guild.text("audit.phase", event.GameID, auditPhase(lang, event.PreviousPhase), auditPhase(lang, event.Phase))
+	case ForcedPhaseEvent:
+		line = guild.text("audit.forcedPhase", event.GameID, auditPhase(lang, event.PreviousPhase), auditPhase(lang, event.Phase), by)
+	case LinkEvent:
+		color := locale.ColorName(lang, game.ColorStrings[event.Color])
+		line = guild.text("audit.link", event.GameID, event.UserID, event.Player, color, by)
+	case UnlinkEvent:
+		line = guild.text("audit.unlink", event.GameID, event.UserID, by)
+	case PatchFailedEvent:
+		line = guild.text("audit.patchFailed", event.UserID, event.Detail)
+	default:
+		line = string(event.Type)
+	}
+	return fmt.Sprintf("`%s` %s", event.Time.UTC().Format("15:04:05"), line)
+}
+
+func (guild *GuildState) auditTrigger(by Trigger) string {
+	source := guild.text("audit.source." + string(by.Source))
+	if by.UserID == "" {
+		return source
+	}
+	return guild.text("audit.byUser", by.UserID, source)
+}
+
+func auditPhase(lang, name string) string {
+	for phase, v := range game.PhaseNames {
+		if string(v) == name {
+			return locale.PhaseName(lang, phase)
+		}
+	}
+	return name
+}
+
+func (guild *GuildState) auditResponse() string {
+	if guild.PersistentGuildData.GetAuditChannelID() == "" {
+		return guild.text("reply.auditOff", guild.PersistentGuildData.CommandPrefix)
+	}
+	return guild.text("reply.auditOn", guild.PersistentGuildData.GetAuditChannelID())
+}
@@ -1,37 +1,44 @@
 package discord
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/bus"
 	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
+	"github.com/denverquane/amongusdiscord/metrics"
+	"github.com/denverquane/amongusdiscord/webhook"
 	socketio "github.com/googollee/go-socket.io"
-	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
-// AllConns mapping of socket IDs to guild IDs
-var AllConns = map[string]string{}
+// AllConns holds the game (and guild) each capture is linked to
+var AllConns = MakeCaptureRegistry()
 
-// AllGuilds mapping of guild IDs to GuildState references
-var AllGuilds = map[string]*GuildState{}
+// AllGuilds holds the guilds this process runs
+var AllGuilds = MakeGuildRegistry()
 
-// LinkCodes maps the code to the guildID
-var LinkCodes = map[string]string{}
+// LinkCodes maps the code to the game (and guild) it links to
+var LinkCodes = map[string]GameKey{}
 
 // LinkCodeLock mutex for above
 var LinkCodeLock = sync.RWMutex{}
 
 // GamePhaseUpdateChannels
-var GamePhaseUpdateChannels = make(map[string]*chan game.Phase)
+var GamePhaseUpdateChannels = make(map[string]*chan PhaseUpdate)
 
-var PlayerUpdateChannels = make(map[string]*chan game.Player)
+var PlayerUpdateChannels = make(map[string]*chan PlayerUpdate)
 
 var SocketUpdateChannels = make(map[string]*chan SocketStatus)
 
@@ -42,14 +49,38 @@ const privateChannelID = "760606092643270679";
 
 type SocketStatus struct {
 	GuildID   string
+	GameID    string
 	Connected bool
 }
 
+// PhaseUpdate is a phase change for one of the games in a guild
+type PhaseUpdate struct {
+	GameID string
+	Phase  game.Phase
+	//By is the capture, or whoever forced the phase
+	By Trigger
+	//End ends the game instead of changing its phase, so it's handled in order with the game's other updates
+	End bool
+}
+
+// PlayerUpdate is a player event for one of the games in a guild
+type PlayerUpdate struct {
+	GameID string
+	Player game.Player
+}
+
 // MakeAndStartBot does what it sounds like
-func MakeAndStartBot(token string, port string, emojiGuildID string) {
+func MakeAndStartBot(token string, port string, emojiGuildID string, adminToken string, allowPrivateWebhooks bool, url string, shards Sharding, retention time.Duration) {
+	publicURL = strings.TrimSuffix(url, "/")
+	sharding = shards
+	guildRetention = retention
+	if allowPrivateWebhooks {
+		webhookClient = webhook.NewClient(WebhookTimeout, true)
+	}
+
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
-		log.Println("error creating Discord session,", err)
+		logger.Errorf("Error creating Discord session: %s", err)
 		return
 	}
 
@@ -58,6 +89,15 @@ func MakeAndStartBot(token string, port string, emojiGuildID string) {
 	dg.AddHandler(messageCreate)
 	dg.AddHandler(reactionCreate)
 	dg.AddHandler(newGuild(emojiGuildID))
+	dg.AddHandler(removeGuild)
+	trackGatewayConnection(dg)
+
+	//discord only sends this process the events of the guilds in its shard
+	dg.ShardID = sharding.ID
+	dg.ShardCount = sharding.Count
+	if sharding.Count > 1 {
+		logger.Infof("Running shard %d of %d", sharding.ID, sharding.Count)
+	}
 
 	dg.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuildVoiceStates | discordgo.IntentsGuildMessages | discordgo.IntentsGuilds | discordgo.IntentsGuildMessageReactions)
 
@@ -65,240 +105,368 @@ func MakeAndStartBot(token string, port string, emojiGuildID string) {
 	err = dg.Open()
 
 	if err != nil {
-		log.Println("Could not connect Bot to the Discord Servers with error:", err)
+		logger.Errorf("Could not connect Bot to the Discord Servers with error: %s", err)
 		return
 	}
 
 	// Wait here until CTRL-C or other term signal is received.
-	log.Println("Bot is now running.  Press CTRL-C to exit.")
+	logger.Info("Bot is now running.  Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 
-	go socketioServer(port)
+	//captures connected to any process can reach this process' games once it's listening
+	err = subscribeCaptures(dg)
+	if err != nil {
+		logger.Errorf("Could not listen for capture events: %s", err)
+		return
+	}
+
+	go socketioServer(dg, port, adminToken)
+
+	purgeCtx, stopPurging := context.WithCancel(context.Background())
+	go purgeLoop(purgeCtx)
 
 	<-sc
 
+	logger.Info("Shutting down; restoring every member the bot muted, deafened or renamed")
+	restoreAllGuilds(dg, ShutdownRestoreTimeout)
+
+	stopPurging()
 	dg.Close()
+	sharding.Bus.Close()
 }
 
-func socketioServer(port string) {
+func socketioServer(dg *discordgo.Session, port string, adminToken string) {
 	server, err := socketio.NewServer(nil)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(err)
+		os.Exit(1)
 	}
+	relay := MakeCaptureRelay()
 	server.OnConnect("/", func(s socketio.Conn) error {
 		s.SetContext("")
-		log.Println("connected:", s.ID())
+		logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())}).Info("Capture connected")
 		return nil
 	})
 	server.OnEvent("/", "connect", func(s socketio.Conn, msg string) {
-		log.Println("set connect code:", msg)
-		guildID := ""
+		logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())}).Debugf("Capture sent connect code %s", msg)
+		//the code might be for a guild another process owns; the capture's events go wherever it is
+		relay.Connect(s.ID(), msg)
+		s.Emit("reply", "set guildID successfully")
+	})
+	server.OnEvent("/", "state", func(s socketio.Conn, msg string) {
+		capLog := logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())})
+		capLog.Debugf("Phase received from capture: %s", msg)
+		if !relay.Forward(s.ID(), CaptureState, msg) {
+			capLog.Warn("This capture is not associated with any games")
+		}
+	})
+	server.OnEvent("/", "player", func(s socketio.Conn, msg string) {
+		capLog := logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())})
+		capLog.Debugf("Player received from capture: %s", msg)
+		if !relay.Forward(s.ID(), CapturePlayer, msg) {
+			capLog.Warn("This capture is not associated with any games")
+		}
+	})
+	server.OnError("/", func(s socketio.Conn, e error) {
+		logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())}).Errorf("Socket error: %s", e)
+	})
+	server.OnDisconnect("/", func(s socketio.Conn, reason string) {
+		logger.With(logger.Fields{logger.CaptureKey: captureID(s.ID())}).Infof("Capture connection closed: %s", reason)
+		relay.Disconnect(s.ID())
+	})
+	go server.Serve()
+	defer server.Close()
+
+	http.Handle("/socket.io/", server)
+	http.Handle("/metrics", metrics.Handler())
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.HandleFunc("/overlay/", overlayPageHandler)
+	http.HandleFunc("/overlay/stream", overlayStreamHandler(dg))
+	//other processes forward capture events for this process' guilds here
+	if handler, ok := sharding.Bus.(http.Handler); ok {
+		http.Handle(bus.HTTPPath, handler)
+	}
+	if adminToken != "" {
+		http.Handle("/api/", adminAPIHandler(dg, adminToken))
+	} else {
+		logger.Info("No ADMIN_API_TOKEN provided; the admin API is disabled")
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	atomic.StoreInt32(&socketServerListening, 1)
+	logger.Infof("Serving at localhost:%s...", port)
+	err = http.Serve(listener, nil)
+	atomic.StoreInt32(&socketServerListening, 0)
+	logger.Error(err)
+	os.Exit(1)
+}
+
+// handleCaptureMessage handles an event from a capture linked to one of this process' guilds, whichever process the
+// capture is connected to
+func handleCaptureMessage(dg *discordgo.Session, msg CaptureMessage) {
+	capLog := logger.With(logger.Fields{logger.CaptureKey: msg.CaptureID})
+	switch msg.Event {
+	case CaptureConnect:
+		key := GameKey{}
 		LinkCodeLock.RLock()
-		for code, gid := range LinkCodes {
-			if code == msg {
-				guildID = gid
+		for code, k := range LinkCodes {
+			if code == msg.Data {
+				key = k
 				break
 			}
 		}
 		LinkCodeLock.RUnlock()
-		if guildID == "" {
-			log.Printf("No guild has the current connect code of %s\n", msg)
+		if key.GuildID == "" {
+			capLog.Warnf("No game has the connect code %s", msg.Data)
 		}
-		for gid, guild := range AllGuilds {
-			if gid == guildID {
-				AllConns[s.ID()] = gid
-				guild.LinkCode = ""
-
-				ChannelsMapLock.RLock()
-				*SocketUpdateChannels[gid] <- SocketStatus{
-					GuildID:   gid,
-					Connected: true,
-				}
-				ChannelsMapLock.RUnlock()
+		if guild, ok := AllGuilds.Get(key.GuildID); ok {
+			gid := key.GuildID
+			gs := guild.Games.Get(key.GameID)
+			if gs == nil {
+				capLog.With(logger.Fields{logger.GuildKey: gid, logger.GameKey: key.GameID}).Warn("Game has already ended")
+				break
 			}
+			if previous, ok := AllConns.Link(msg.CaptureID, key); ok {
+				captureConnections.Add(-1, previous.GuildID)
+			}
+			LinkCodeLock.Lock()
+			gs.LinkCode = ""
+			LinkCodeLock.Unlock()
+			captureConnections.Add(1, gid)
+			captureConnectionEvents.Inc(gid, "connect")
+
+			queueSocketUpdate(SocketStatus{
+				GuildID:   gid,
+				GameID:    key.GameID,
+				Connected: true,
+			})
+			capLog.With(logger.Fields{logger.GuildKey: key.GuildID, logger.GameKey: key.GameID}).Infof("Associated capture with game using code %s", msg.Data)
 		}
-
-		log.Printf("Associated websocket id %s with guildID %s using code %s\n", s.ID(), guildID, msg)
-		s.Emit("reply", "set guildID successfully")
-	})
-	server.OnEvent("/", "state", func(s socketio.Conn, msg string) {
-		log.Println("phase received from capture: ", msg)
-		phase, err := strconv.Atoi(msg)
+	case CaptureState:
+		phase, err := strconv.Atoi(msg.Data)
 		if err != nil {
-			log.Println(err)
+			capLog.Warn(err)
 		} else {
-			if v, ok := AllConns[s.ID()]; ok {
-				log.Println("Pushing phase event to channel")
-				ChannelsMapLock.RLock()
-				*GamePhaseUpdateChannels[v] <- game.Phase(phase)
-				ChannelsMapLock.RUnlock()
+			if v, ok := AllConns.Get(msg.CaptureID); ok {
+				capLog.With(logger.Fields{logger.GuildKey: v.GuildID, logger.GameKey: v.GameID}).Debug("Pushing phase event to channel")
+				queuePhaseUpdate(v.GuildID, PhaseUpdate{GameID: v.GameID, Phase: game.Phase(phase), By: byCapture()})
 			} else {
-				log.Println("This websocket is not associated with any guilds")
+				capLog.Warn("This capture is not associated with any games")
 			}
 		}
-
-	})
-	server.OnEvent("/", "player", func(s socketio.Conn, msg string) {
-		log.Println("player received from capture: ", msg)
+	case CapturePlayer:
 		player := game.Player{}
-		err := json.Unmarshal([]byte(msg), &player)
+		err := json.Unmarshal([]byte(msg.Data), &player)
 		if err != nil {
-			log.Println(err)
+			capLog.Warn(err)
 		} else {
-			if v, ok := AllConns[s.ID()]; ok {
-				ChannelsMapLock.RLock()
-				*PlayerUpdateChannels[v] <- player
-				ChannelsMapLock.RUnlock()
+			if v, ok := AllConns.Get(msg.CaptureID); ok {
+				queuePlayerUpdate(v.GuildID, PlayerUpdate{GameID: v.GameID, Player: player})
 			} else {
-				log.Println("This websocket is not associated with any guilds")
+				capLog.Warn("This capture is not associated with any games")
 			}
 		}
-	})
-	server.OnError("/", func(s socketio.Conn, e error) {
-		log.Println("meet error:", e)
-	})
-	server.OnDisconnect("/", func(s socketio.Conn, reason string) {
-		log.Println("Client connection closed: ", reason)
-
-		previousGid := AllConns[s.ID()]
-		AllConns[s.ID()] = "" //deassociate the link between guild and WS
+	case CaptureDisconnect:
+		previousKey, ok := AllConns.Unlink(msg.CaptureID) //deassociate the link between game and WS
+		if !ok {
+			break
+		}
+		captureConnections.Add(-1, previousKey.GuildID)
+		captureConnectionEvents.Inc(previousKey.GuildID, "disconnect")
 		LinkCodeLock.Lock()
 		for i, v := range LinkCodes {
-			//delete the association between the link code and the guild
-			if v == previousGid {
+			//delete the association between the link code and the game
+			if v == previousKey {
 				delete(LinkCodes, i)
 				break
 			}
 		}
 		LinkCodeLock.Unlock()
 
-		for gid, guild := range AllGuilds {
-			if gid == previousGid {
+		if guild, ok := AllGuilds.Get(previousKey.GuildID); ok {
+			gid := previousKey.GuildID
+			gs := guild.Games.Get(previousKey.GameID)
+			if gs == nil {
+				break
+			}
 
-				code := generateConnectCode(gid) //this is unlinked
-				LinkCodeLock.Lock()
-				LinkCodes[code] = guild.PersistentGuildData.GuildID
-				guild.LinkCode = code
-				LinkCodeLock.Unlock()
+			code := generateConnectCode(gid) //this is unlinked
+			LinkCodeLock.Lock()
+			LinkCodes[code] = previousKey
+			gs.LinkCode = code
+			LinkCodeLock.Unlock()
 
-				ChannelsMapLock.RLock()
-				*SocketUpdateChannels[gid] <- SocketStatus{
-					GuildID:   gid,
-					Connected: false,
-				}
-				ChannelsMapLock.RUnlock()
+			queueSocketUpdate(SocketStatus{
+				GuildID:   gid,
+				GameID:    previousKey.GameID,
+				Connected: false,
+			})
 
-				log.Printf("Deassociated websocket id %s with guildID %s\n", s.ID(), gid)
-			}
+			capLog.With(logger.Fields{logger.GuildKey: gid, logger.GameKey: previousKey.GameID}).Info("Deassociated capture from game")
 		}
-	})
-	go server.Serve()
-	defer server.Close()
-
-	http.Handle("/socket.io/", server)
-	log.Printf("Serving at localhost:%s...\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	default:
+		capLog.Warnf("Unknown capture event %s", msg.Event)
+	}
 }
 
-func updatesListener(dg *discordgo.Session, guildID string, socketUpdates *chan SocketStatus, phaseUpdates *chan game.Phase, playerUpdates *chan game.Player) {
+func updatesListener(ctx context.Context, dg *discordgo.Session, guildID string, socketUpdates *chan SocketStatus, phaseUpdates *chan PhaseUpdate, playerUpdates *chan PlayerUpdate) {
 	for {
 		select {
-
-		case phase := <-*phaseUpdates:
-			log.Printf("Received PhaseUpdate message for guild %s\n", guildID)
-			if guild, ok := AllGuilds[guildID]; ok {
+		case <-ctx.Done():
+			logger.With(logger.Fields{logger.GuildKey: guildID}).Debug("Stopped listening for updates")
+			return
+
+		case update := <-*phaseUpdates:
+			refillUpdateQueues(guildID)
+			gameLog := logger.With(logger.Fields{logger.GuildKey: guildID, logger.GameKey: update.GameID})
+			gameLog.Debug("Received phase update")
+			if guild, ok := AllGuilds.Get(guildID); ok {
+				gs := guild.Games.Get(update.GameID)
+				if gs == nil {
+					gameLog.Debug("Game has already ended; ignoring the phase update")
+					break
+				}
+				if update.End {
+					guild.handleGameEndMessage(dg, gs, update.By)
+					if pMessage := guild.PrivateStateMsg.MessageFor(gs.ID); pMessage != nil {
+						deleteMessage(dg, pMessage.ChannelID, pMessage.ID)
+					}
+					break
+				}
+				phase := update.Phase
+				if phase != game.MENU && phase != gs.AmongUsData.GetPhase() {
+					phaseTransitions.Inc(phaseName(gs.AmongUsData.GetPhase()), phaseName(phase))
+				}
+				//forcing the phase a game is already in still gets recorded, since someone asked for it
+				if phase != game.MENU && (phase != gs.AmongUsData.GetPhase() || update.By.Source != SourceCapture) {
+					guild.publish(phaseEvent(gs, gs.AmongUsData.GetPhase(), phase, update.By))
+				}
 				switch phase {
 				case game.MENU:
-					log.Println("Detected transition to Menu; not doing anything about it yet")
+					gameLog.Debug("Detected transition to Menu; not doing anything about it yet")
 				case game.LOBBY:
-					if guild.AmongUsData.GetPhase() == game.LOBBY {
+					if gs.AmongUsData.GetPhase() == game.LOBBY {
 						break
 					}
-					log.Println("Detected transition to Lobby")
+					gameLog.Info("Detected transition to Lobby")
 
-					delay := guild.PersistentGuildData.Delays.GetDelay(guild.AmongUsData.GetPhase(), game.LOBBY)
+					oldPhase := gs.AmongUsData.GetPhase()
+					delay := guild.PersistentGuildData.Delays.GetDelay(oldPhase, game.LOBBY)
+					steps := guild.PersistentGuildData.VoicePriorities.GetSteps(oldPhase, game.LOBBY)
 
-					guild.AmongUsData.SetAllAlive()
-					guild.AmongUsData.SetPhase(phase)
+					gs.AmongUsData.SetAllAlive()
+					gs.AmongUsData.SetPhase(phase)
 
-					//going back to the lobby, we have no preference on who gets applied first
-					guild.handleTrackedMembers(dg, delay, NoPriority)
+					guild.startTransition(dg, gs, delay, steps)
 
-					guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+					guild.requestStatusEdit(gs)
 				case game.TASKS:
-					if guild.AmongUsData.GetPhase() == game.TASKS {
+					if gs.AmongUsData.GetPhase() == game.TASKS {
 						break
 					}
-					log.Println("Detected transition to Tasks")
-					oldPhase := guild.AmongUsData.GetPhase()
+					gameLog.Info("Detected transition to Tasks")
+					oldPhase := gs.AmongUsData.GetPhase()
 					delay := guild.PersistentGuildData.Delays.GetDelay(oldPhase, game.TASKS)
-					//when going from discussion to tasks, we should mute alive players FIRST
-					priority := AlivePriority
+					steps := guild.PersistentGuildData.VoicePriorities.GetSteps(oldPhase, game.TASKS)
 
 					if oldPhase == game.LOBBY {
 						//when we go from lobby to tasks, mark all users as alive to be sure
-						guild.AmongUsData.SetAllAlive()
-						priority = NoPriority
+						gs.AmongUsData.SetAllAlive()
+						gs.Deaths.Reset()
 					}
 
-					guild.AmongUsData.SetPhase(phase)
+					gs.AmongUsData.SetPhase(phase)
 
-					guild.handleTrackedMembers(dg, delay, priority)
+					guild.startTransition(dg, gs, delay, steps)
 
-					guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+					guild.requestStatusEdit(gs)
 				case game.DISCUSS:
-					if guild.AmongUsData.GetPhase() == game.DISCUSS {
+					if gs.AmongUsData.GetPhase() == game.DISCUSS {
 						break
 					}
-					log.Println("Detected transition to Discussion")
+					gameLog.Info("Detected transition to Discussion")
 
-					delay := guild.PersistentGuildData.Delays.GetDelay(guild.AmongUsData.GetPhase(), game.DISCUSS)
+					oldPhase := gs.AmongUsData.GetPhase()
+					delay := guild.PersistentGuildData.Delays.GetDelay(oldPhase, game.DISCUSS)
+					steps := guild.PersistentGuildData.VoicePriorities.GetSteps(oldPhase, game.DISCUSS)
 
-					guild.AmongUsData.SetPhase(phase)
+					gs.AmongUsData.SetPhase(phase)
 
-					//when going from
-					guild.handleTrackedMembers(dg, delay, DeadPriority)
+					guild.startTransition(dg, gs, delay, steps)
 
-					guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+					guild.requestStatusEdit(gs)
 				default:
-					log.Printf("Undetected new state: %d\n", phase)
+					gameLog.Warnf("Undetected new state: %d", phase)
 				}
 			}
 
-			// TODO prevent cases where 2 players are mapped to the same underlying in-game player data
-		case player := <-*playerUpdates:
-			log.Printf("Received PlayerUpdate message for guild %s\n", guildID)
-			if guild, ok := AllGuilds[guildID]; ok {
+		case update := <-*playerUpdates:
+			refillUpdateQueues(guildID)
+			gameLog := logger.With(logger.Fields{logger.GuildKey: guildID, logger.GameKey: update.GameID})
+			gameLog.Debug("Received player update")
+			if guild, ok := AllGuilds.Get(guildID); ok {
+				gs := guild.Games.Get(update.GameID)
+				if gs == nil {
+					gameLog.Debug("Game has already ended; ignoring the player update")
+					break
+				}
+				player := update.Player
+				playerEvents.Inc(playerActionName(player.Action))
 
 				//	this updates the copies in memory
 				//	(player's associations to amongus data are just pointers to these structs)
 				if player.Name != "" {
 					if player.Action == game.EXILED {
-						log.Println("Detected player EXILE event, marking as dead")
+						gameLog.Debugf("Detected exile of %s, marking as dead", player.Name)
 						player.IsDead = true
 					}
-					if player.IsDead == true && guild.AmongUsData.GetPhase() == game.LOBBY {
-						log.Println("Received a dead event, but we're in the Lobby, so I'm ignoring it")
+					if player.IsDead == true && gs.AmongUsData.GetPhase() == game.LOBBY {
+						gameLog.Debug("Received a dead event, but we're in the Lobby, so I'm ignoring it")
 						player.IsDead = false
 					}
 
 					if player.Disconnected {
-						log.Println("I detected that " + player.Name + " disconnected! " +
-							"I'm removing their linked game data; they will need to relink")
+						gameLog.Infof("%s disconnected; removing their linked game data, they will need to relink", player.Name)
+						guild.publish(playerEvent(gs, PlayerLeftEvent, player))
 
-						guild.UserData.ClearPlayerDataByPlayerName(player.Name)
-						guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+						if removed := gs.AmongUsData.RemovePlayer(player); removed != nil {
+							for _, userID := range guild.UserData.ClearPlayerDataByPlayer(gs.ID, removed) {
+								guild.publish(unlinkEvent(gs.ID, userID, byCapture()))
+								guild.releaseMember(dg, userID)
+							}
+						}
+						guild.requestStatusEdit(gs)
 					} else {
-						updated, isAliveUpdated := guild.AmongUsData.ApplyPlayerUpdate(player)
+						updated, isAliveUpdated, replaced := gs.AmongUsData.ApplyPlayerUpdate(player)
+						if replaced != nil {
+							for _, userID := range guild.UserData.ClearPlayerDataByPlayer(gs.ID, replaced) {
+								guild.publish(unlinkEvent(gs.ID, userID, byCapture()))
+								guild.releaseMember(dg, userID)
+							}
+						}
 
 						if updated {
 							//log.Println("Player update received caused an update in cached state")
-							if isAliveUpdated && guild.AmongUsData.GetPhase() == game.TASKS {
-								log.Println("NOT updating the discord status message; would leak info")
-							} else {
-								guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+							if isAliveUpdated && player.IsDead {
+								gs.Deaths.Record(player)
+								if player.Action == game.EXILED {
+									guild.publish(playerEvent(gs, PlayerExiledEvent, player))
+								} else {
+									guild.publish(playerEvent(gs, PlayerDiedEvent, player))
+								}
 							}
+							if player.Action == game.JOINED {
+								guild.publish(playerEvent(gs, PlayerJoinedEvent, player))
+							}
+							//the disclosure policy keeps this from leaking anything; if nothing it may show changed,
+							//the edit is skipped entirely
+							guild.requestStatusEdit(gs)
 						} else {
 							//log.Println("Player update received did not cause an update in cached state")
 						}
@@ -308,9 +476,11 @@ func updatesListener(dg *discordgo.Session, guildID string, socketUpdates *chan
 			}
 			break
 		case socketUpdate := <-*socketUpdates:
-			if guild, ok := AllGuilds[socketUpdate.GuildID]; ok {
+			if guild, ok := AllGuilds.Get(socketUpdate.GuildID); ok {
 				//this automatically updates the game state message on connect or disconnect
-				guild.GameStateMsg.Edit(dg, gameStateResponse(guild))
+				if gs := guild.Games.Get(socketUpdate.GameID); gs != nil {
+					guild.requestStatusEdit(gs)
+				}
 			}
 		}
 	}
@@ -318,37 +488,27 @@ func updatesListener(dg *discordgo.Session, guildID string, socketUpdates *chan
 
 // Gets called whenever a voice state change occurs
 func voiceStateChange(s *discordgo.Session, m *discordgo.VoiceStateUpdate) {
-	for id, socketGuild := range AllGuilds {
-		if id == m.GuildID {
-			socketGuild.voiceStateChange(s, m)
-			break
-		}
+	if socketGuild, ok := AllGuilds.Get(m.GuildID); ok {
+		socketGuild.voiceStateChange(s, m)
 	}
 }
 
 // This function will be called (due to AddHandler above) every time a new
 // message is created on any channel that the authenticated bot has access to.
 func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	for id, socketGuild := range AllGuilds {
-		if id == m.GuildID {
-			socketGuild.handleMessageCreate(s, m)
-			break
-		}
+	if socketGuild, ok := AllGuilds.Get(m.GuildID); ok {
+		socketGuild.handleMessageCreate(s, m)
 	}
 }
 
 //this function is called whenever a reaction is created in a guild
 func reactionCreate(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
 
-	for id, socketGuild := range AllGuilds {
-		if id == m.GuildID {
-			if socketGuild.GameStateMsg.Exists() && socketGuild.GameStateMsg.IsReactionTo(m) {
-				socketGuild.handleReactionGameStartAdd(s, m)
-			} else if m.ChannelID == privateChannelID {
-				socketGuild.handleReactionPrivateUserMessage(s, m);
-			}
-
-			break
+	if socketGuild, ok := AllGuilds.Get(m.GuildID); ok {
+		if gs := socketGuild.Games.FindByReaction(m); gs != nil {
+			socketGuild.handleReactionGameStartAdd(s, gs, m)
+		} else if m.ChannelID == privateChannelID {
+			socketGuild.handleReactionPrivateUserMessage(s, m);
 		}
 	}
 }
@@ -356,62 +516,95 @@ func reactionCreate(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
 func newGuild(emojiGuildID string) func(s *discordgo.Session, m *discordgo.GuildCreate) {
 
 	return func(s *discordgo.Session, m *discordgo.GuildCreate) {
-		filename := fmt.Sprintf("%s_config.json", m.Guild.ID)
+		//guilds that are down come back with another GuildCreate
+		if m.Unavailable {
+			return
+		}
+		//discord sends GuildCreate again when a session reconnects, for guilds that are already running
+		if _, ok := AllGuilds.Get(m.Guild.ID); ok {
+			logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Debug("Guild is already set up")
+			return
+		}
+
+		filename := ConfigFilename(m.Guild.ID)
 		pgd, err := LoadPGDFromFile(filename)
 		if err != nil {
-			log.Printf("Couldn't load config from %s; using default config instead", filename)
-			log.Printf("Exact error: %s", err)
+			logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Warnf("Couldn't load config from %s; using default config instead: %s", filename, err)
 			pgd = PGDDefault(m.Guild.ID)
 			err := pgd.ToFile(filename)
 			if err != nil {
-				log.Println("Using default config, but could not write that default to " + filename + " with error:")
-				log.Println(err)
+				logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Errorf("Using default config, but could not write that default to %s: %s", filename, err)
 			}
 		}
 
-		log.Printf("Added to new Guild, id %s, name %s", m.Guild.ID, m.Guild.Name)
-		AllGuilds[m.ID] = &GuildState{
-			PersistentGuildData: pgd,
+		if pgd.RemovedAt != nil {
+			logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Info("Added back to a guild that had removed the bot; its data won't be purged anymore")
+			pgd.RemovedAt = nil
+			err := pgd.ToFile(filename)
+			if err != nil {
+				logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Error(err)
+			}
+		}
 
-			LinkCode: m.Guild.ID,
+		mm, err := LoadModifiedMembersFromFile(ModifiedMembersFilename(m.Guild.ID))
+		if err != nil {
+			mm = MakeModifiedMembers(m.Guild.ID)
+		}
 
-			UserData:     MakeUserDataSet(),
-			Tracking:     MakeTracking(),
-			GameStateMsg: MakeGameStateMessage(),
+		ctx, stop := context.WithCancel(context.Background())
+		guild := &GuildState{
+			PersistentGuildData: pgd,
+
+			UserData:        MakeUserDataSet(),
+			Games:           MakeGameSet(),
 			PrivateStateMsg: MakePrivateStateMessage(),
 
-			StatusEmojis:  emptyStatusEmojis(),
-			SpecialEmojis: map[string]Emoji{},
+			statusEmojis:  emptyStatusEmojis(),
+			specialEmojis: emptySpecialEmojis(),
+			EmojiGuildID:  emojiGuildID,
 
-			AmongUsData: game.NewAmongUsData(),
-		}
+			ModifiedMembers: mm,
+			StatusEdits:     MakeStatusEdits(),
+			AuditLog:        MakeAuditLog(),
+			Webhooks:        MakeWebhooks(m.Guild.ID, pgd.Webhooks),
+			Overlay:         MakeOverlayStreams(),
+			DryRunReports:   MakeDryRunReports(),
 
-		if emojiGuildID == "" {
-			log.Println("No explicit guildID provided for emojis; using the current guild default")
-			emojiGuildID = m.Guild.ID
+			ctx:  ctx,
+			stop: stop,
 		}
-		allEmojis, err := s.GuildEmojis(emojiGuildID)
-		if err != nil {
-			log.Println(err)
-		} else {
-			AllGuilds[m.Guild.ID].addAllMissingEmojis(s, m.Guild.ID, true, allEmojis)
-
-			AllGuilds[m.Guild.ID].addAllMissingEmojis(s, m.Guild.ID, false, allEmojis)
+		//another GuildCreate for the guild might have been handled at the same time
+		if !AllGuilds.Add(m.Guild.ID, guild) {
+			stop()
+			guild.Webhooks.Close()
+			return
+		}
+		guild.log().Infof("Added to new guild %s", m.Guild.Name)
+		guildLifecycleEvents.Inc("created")
 
-			AllGuilds[m.Guild.ID].addSpecialEmojis(s, m.Guild.ID, allEmojis)
+		if emojiGuildID == "" {
+			logger.With(logger.Fields{logger.GuildKey: m.Guild.ID}).Debug("No explicit guildID provided for emojis; using the current guild")
 		}
+		guild.syncAllEmojis(s, m.Guild.ID, guild.EmojiGuildID)
 
-		socketUpdates := make(chan SocketStatus)
-		playerUpdates := make(chan game.Player)
-		phaseUpdates := make(chan game.Phase)
+		socketUpdates := make(chan SocketStatus, UpdateQueueSize)
+		playerUpdates := make(chan PlayerUpdate, UpdateQueueSize)
+		phaseUpdates := make(chan PhaseUpdate, UpdateQueueSize)
 
 		ChannelsMapLock.Lock()
 		SocketUpdateChannels[m.Guild.ID] = &socketUpdates
 		PlayerUpdateChannels[m.Guild.ID] = &playerUpdates
 		GamePhaseUpdateChannels[m.Guild.ID] = &phaseUpdates
+		UpdateQueueOverflows[m.Guild.ID] = &UpdateQueueOverflow{}
 		ChannelsMapLock.Unlock()
 
-		go updatesListener(s, m.Guild.ID, &socketUpdates, &phaseUpdates, &playerUpdates)
+		guild.startGuild(s, &socketUpdates, &phaseUpdates, &playerUpdates)
+
+		//anyone still recorded as modified was left that way by a previous run of the bot
+		if mm.Size() > 0 {
+			guild.log().Infof("Found %d member(s) left modified by a previous run; restoring them", mm.Size())
+			go guild.restoreModifiedMembers(s)
+		}
 
 	}
 }
@@ -425,30 +618,40 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 
 	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
 	if err != nil {
-		log.Println(err)
+		guild.log().Warn(err)
 	}
 
 	contents := m.Content
 	if strings.HasPrefix(contents, guild.PersistentGuildData.CommandPrefix) {
-		args := strings.Split(contents, " ")[1:]
-		for i, v := range args {
+		//rawArgs keep their original casing, for commands that take free text
+		rawArgs := strings.Split(contents, " ")[1:]
+		args := make([]string, len(rawArgs))
+		for i, v := range rawArgs {
 			args[i] = strings.ToLower(v)
 		}
+		//commands can be aimed at a specific game by putting its ID first, like .au 2 end
+		gameID := ""
+		if len(args) > 0 && isGameID(args[0]) {
+			gameID = args[0]
+			args = args[1:]
+			rawArgs = rawArgs[1:]
+		}
 		if len(args) == 0 {
-			s.ChannelMessageSend(m.ChannelID, helpResponse(guild.PersistentGuildData.CommandPrefix))
+			s.ChannelMessageSend(m.ChannelID, helpResponse(guild.PersistentGuildData.GetLanguage(), guild.PersistentGuildData.CommandPrefix))
 		} else {
+			commandUsage.Inc(commandName(args[0]))
 			switch args[0] {
 			case "help":
 				fallthrough
 			case "h":
-				s.ChannelMessageSend(m.ChannelID, helpResponse(guild.PersistentGuildData.CommandPrefix))
+				s.ChannelMessageSend(m.ChannelID, helpResponse(guild.PersistentGuildData.GetLanguage(), guild.PersistentGuildData.CommandPrefix))
 				break
 			case "track":
 				fallthrough
 			case "t":
 				if len(args[1:]) == 0 {
 					//TODO print usage of this command specifically
-					s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You used this command incorrectly! Please refer to `%s help` for proper command usage", guild.PersistentGuildData.CommandPrefix))
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
 				} else {
 					// have to explicitly check for true. Otherwise, processing the 2-word VC names gets really ugly...
 					forGhosts := false
@@ -462,12 +665,16 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 
 					channels, err := s.GuildChannels(m.GuildID)
 					if err != nil {
-						log.Println(err)
+						guild.log().Error(err)
 					}
 
-					guild.trackChannelResponse(channelName, channels, forGhosts)
+					gs := guild.gameForCommand(s, g, m, gameID)
+					if gs == nil {
+						break
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.trackChannelResponse(gs, channelName, channels, forGhosts))
 
-					guild.GameStateMsg.Edit(s, gameStateResponse(guild))
+					guild.requestStatusEdit(gs)
 				}
 				break
 
@@ -476,11 +683,21 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 			case "l":
 				if len(args[1:]) < 2 {
 					//TODO print usage of this command specifically
-					s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You used this command incorrectly! Please refer to `%s help` for proper command usage", guild.PersistentGuildData.CommandPrefix))
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
 				} else {
-					guild.linkPlayerResponse(args[1:])
+					gs := guild.gameForCommand(s, g, m, gameID)
+					if gs == nil {
+						break
+					}
+					if msg := guild.linkPlayerResponse(gs, args[1:], byCommand(m.Author.ID)); msg != "" {
+						s.ChannelMessageSend(m.ChannelID, msg)
+					}
 
-					guild.GameStateMsg.Edit(s, gameStateResponse(guild))
+					if userID, err := extractUserIDFromMention(args[1]); err == nil {
+						guild.reportNicknameProblem(s, m.ChannelID, userID)
+					}
+
+					guild.requestStatusEdit(gs)
 				}
 				break
 			case "unlink":
@@ -489,23 +706,29 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 				fallthrough
 			case "u":
 				if len(args[1:]) == 0 {
-					s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You used this command incorrectly! Please refer to `%s help` for proper command usage", guild.PersistentGuildData.CommandPrefix))
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
 				} else {
 
 				}
 				userID, err := extractUserIDFromMention(args[1])
 				if err != nil {
-					log.Println(err)
+					guild.log().Error(err)
 				} else {
 
-					log.Printf("Removing player %s", userID)
-					guild.UserData.ClearPlayerData(userID)
+					guild.log().With(logger.Fields{logger.UserKey: userID}).Info("Unlinking player")
+					previousGame := guild.UserData.ClearPlayerData(userID)
+					if previousGame != "" {
+						guild.publish(unlinkEvent(previousGame, userID, byCommand(m.Author.ID)))
+					}
+					guild.releaseMember(s, userID)
 
 					//make sure that any players we remove/unlink get auto-unmuted/undeafened
 					guild.verifyVoiceStateChanges(s)
 
 					//update the state message to reflect the player leaving
-					guild.GameStateMsg.Edit(s, gameStateResponse(guild))
+					if gs := guild.Games.Get(previousGame); gs != nil {
+						guild.requestStatusEdit(gs)
+					}
 				}
 			case "start":
 				fallthrough
@@ -518,17 +741,36 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 
 				initialTracking := TrackingChannel{}
 
+				gs, err := guild.gameToStart(s, g, m.Author.ID, gameID)
+				if err != nil {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.whichGame", guild.errorText(err)))
+					break
+				}
+				if gs == nil {
+					gs = guild.Games.Create(guild.PersistentGuildData.VoiceRules)
+					guild.gameLog(gs).Info("Starting game")
+				} else {
+					//remaking a game; the old status message is replaced by the new one
+					gs.GameStateMsg.Delete(s)
+				}
+				key := GameKey{GuildID: guild.PersistentGuildData.GuildID, GameID: gs.ID}
+
 				//TODO need to send a message to the capture re-questing all the player/game states. Otherwise,
 				//we don't have enough info to go off of when remaking the game...
 				//if !guild.GameStateMsg.Exists() {
 				connectCode := generateConnectCode(guild.PersistentGuildData.GuildID)
-				log.Println(connectCode)
+				guild.gameLog(gs).Debugf("Link code %s", connectCode)
 				LinkCodeLock.Lock()
-				LinkCodes[connectCode] = guild.PersistentGuildData.GuildID
-				guild.LinkCode = connectCode
+				for code, v := range LinkCodes {
+					if v == key {
+						delete(LinkCodes, code)
+					}
+				}
+				LinkCodes[connectCode] = key
+				gs.LinkCode = connectCode
 				LinkCodeLock.Unlock()
 
-				for _, v := range g.VoiceStates {
+				for _, v := range voiceStates(s, g) {
 					//if the user is detected in a voice channel
 					if v.UserID == m.Author.ID {
 						for _, channel := range g.Channels {
@@ -539,14 +781,18 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 									channelName: channel.Name,
 									forGhosts:   false,
 								}
-								log.Printf("User that typed new is in the \"%s\" voice channel; using that for tracking", channel.Name)
+								guild.log().Debugf("User that typed new is in the \"%s\" voice channel; using that for tracking", channel.Name)
 							}
 						}
 					}
 				}
 				//}
-				guild.handleGameStartMessage(s, m, room, region, initialTracking)
-				guild.createPrivateMapMessage(s, m);
+				//don't track a channel another game is already tracking
+				if other := guild.Games.FindByTrackedChannel(initialTracking.channelID); other != nil && other != gs {
+					initialTracking = TrackingChannel{}
+				}
+				guild.handleGameStartMessage(s, m, gs, room, region, initialTracking)
+				guild.createPrivateMapMessage(s, m, gs);
 
 				break
 			case "end":
@@ -554,51 +800,468 @@ func (guild *GuildState) handleMessageCreate(s *discordgo.Session, m *discordgo.
 			case "e":
 				fallthrough
 			case "endgame":
-				guild.handleGameEndMessage(s)
+				gs := guild.gameForCommand(s, g, m, gameID)
+				if gs == nil {
+					break
+				}
+				guild.handleGameEndMessage(s, gs, byCommand(m.Author.ID))
 
-				var pMessage = guild.PrivateStateMsg.message;
+				var pMessage = guild.PrivateStateMsg.MessageFor(gs.ID);
 
 				//have to explicitly delete here, because if we use the default delete below, the channelID
 				//for the game state message doesn't exist anymore...
 				deleteMessage(s, m.ChannelID, m.Message.ID)
-				deleteMessage(s, pMessage.ChannelID, pMessage.ID);
+				if pMessage != nil {
+					deleteMessage(s, pMessage.ChannelID, pMessage.ID);
+				}
 				break
 			case "force":
 				fallthrough
 			case "f":
 				phase := getPhaseFromArgs(args[1:])
 				if phase == game.UNINITIALIZED {
-					s.ChannelMessageSend(m.ChannelID, "Sorry, I didn't understand the game phase you tried to force")
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.unknownPhase"))
 				} else {
+					gs := guild.gameForCommand(s, g, m, gameID)
+					if gs == nil {
+						break
+					}
 					//TODO this is ugly, but only for debug really
-					ChannelsMapLock.RLock()
-					*GamePhaseUpdateChannels[m.GuildID] <- phase
-					ChannelsMapLock.RUnlock()
+					queuePhaseUpdate(m.GuildID, PhaseUpdate{GameID: gs.ID, Phase: phase, By: byCommand(m.Author.ID)})
 				}
 
 				break
+			case "dryrun":
+				fallthrough
+			case "dry":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.dryRunResponse(guild.PersistentGuildData.IsDryRun()))
+					break
+				}
+				switch args[1] {
+				case "on":
+					fallthrough
+				case "true":
+					channelID := m.ChannelID
+					if len(args[2:]) > 0 {
+						channelID, err = extractChannelIDFromMention(args[2])
+						if err != nil {
+							s.ChannelMessageSend(m.ChannelID, guild.text("reply.badChannel", args[2]))
+							return
+						}
+					}
+					guild.PersistentGuildData.SetDryRun(true, channelID)
+				case "off":
+					fallthrough
+				case "false":
+					guild.PersistentGuildData.SetDryRun(false, "")
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					return
+				}
+				err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.dryRunResponse(guild.PersistentGuildData.IsDryRun()))
+			case "audit":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.auditResponse())
+					break
+				}
+				switch args[1] {
+				case "on":
+					fallthrough
+				case "true":
+					channelID := m.ChannelID
+					if len(args[2:]) > 0 {
+						channelID, err = extractChannelIDFromMention(args[2])
+						if err != nil {
+							s.ChannelMessageSend(m.ChannelID, guild.text("reply.badChannel", args[2]))
+							return
+						}
+					}
+					guild.PersistentGuildData.SetAuditChannelID(channelID)
+				case "off":
+					fallthrough
+				case "false":
+					guild.PersistentGuildData.SetAuditChannelID("")
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					return
+				}
+				err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.auditResponse())
+			case "overlay":
+				if len(args[1:]) > 0 && (args[1] == "off" || args[1] == "false") {
+					guild.PersistentGuildData.SetOverlayToken("")
+					guild.Overlay.Notify()
+					err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+					if err != nil {
+						guild.log().Error(err)
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.overlayOff", guild.PersistentGuildData.CommandPrefix))
+					break
+				}
+				reset := len(args[1:]) > 0 && args[1] == "reset"
+				if len(args[1:]) > 0 && !reset {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					break
+				}
+				if reset || guild.PersistentGuildData.GetOverlayToken() == "" {
+					if err := guild.resetOverlayToken(); err != nil {
+						guild.log().Error(err)
+					}
+				}
+				//the link has the token in it, so it's only sent to whoever asked
+				sent := false
+				if dm, err := s.UserChannelCreate(m.Author.ID); err == nil {
+					sent = sendMessage(s, dm.ID, guild.text("reply.overlayLink", guild.overlayURL())) != nil
+				}
+				if !sent {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.overlayNoDM"))
+					break
+				}
+				if reset {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.overlayReset"))
+				} else {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.overlaySent", guild.PersistentGuildData.CommandPrefix))
+				}
+			case "webhooks":
+				fallthrough
+			case "webhook":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.webhooksResponse())
+					break
+				}
+				switch args[1] {
+				case "add":
+					if len(args[2:]) == 0 {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+						break
+					}
+					//the URL could have a token in it; don't leave it sitting in the channel
+					deleteMessage(s, m.ChannelID, m.Message.ID)
+					events, err := parseWebhookEventTypes(args[3:])
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookError", guild.errorText(err)))
+						break
+					}
+					//URLs are case sensitive
+					hook, err := guild.addWebhook(rawArgs[2], events)
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookError", guild.errorText(err)))
+						break
+					}
+					//only whoever added the webhook gets to see its secret
+					sent := false
+					if dm, err := s.UserChannelCreate(m.Author.ID); err == nil {
+						sent = sendMessage(s, dm.ID, guild.text("reply.webhookSecret", hook.ID, webhook.HostOf(hook.URL), hook.Secret)) != nil
+					}
+					if !sent {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookNoDM", hook.ID, guild.PersistentGuildData.CommandPrefix))
+						break
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookAdded", hook.ID, webhook.HostOf(hook.URL), guild.PersistentGuildData.CommandPrefix))
+				case "remove":
+					if len(args[2:]) == 0 {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+						break
+					}
+					removed, err := guild.removeWebhook(args[2])
+					if err != nil {
+						guild.log().Error(err)
+					}
+					if !removed {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookNotFound", args[2]))
+						break
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookRemoved", args[2]))
+				case "test":
+					if len(args[2:]) == 0 {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+						break
+					}
+					result, err := guild.Webhooks.Test(args[2], GuildEvent{
+						Type:    TestEvent,
+						GuildID: guild.PersistentGuildData.GuildID,
+						Time:    time.Now(),
+						By:      byCommand(m.Author.ID),
+					})
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookError", guild.errorText(err)))
+						break
+					}
+					if !result.OK() {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookTestFailed", args[2], result))
+						break
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookTestOK", args[2], result))
+				case "failed":
+					s.ChannelMessageSend(m.ChannelID, guild.webhookDeadLettersResponse())
+				case "retry":
+					queued, err := guild.Webhooks.RetryDeadLetters()
+					if err != nil {
+						guild.log().Error(err)
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.webhookRetried", queued))
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+				}
+			case "nickname":
+				fallthrough
+			case "nick":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.nicknameResponse())
+					break
+				}
+				switch args[1] {
+				case "on":
+					fallthrough
+				case "true":
+					guild.PersistentGuildData.SetApplyNicknames(true)
+				case "off":
+					fallthrough
+				case "false":
+					guild.PersistentGuildData.SetApplyNicknames(false)
+				case "template":
+					//use the original casing of the template
+					template := strings.Join(rawArgs[2:], " ")
+					if !strings.Contains(template, "{ign}") && !strings.Contains(template, "{color}") {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.nicknameTemplate"))
+						return
+					}
+					guild.PersistentGuildData.SetNicknameTemplate(template)
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					return
+				}
+				err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.nicknameResponse())
+			case "priority":
+				fallthrough
+			case "p":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.priorityResponse())
+					break
+				}
+				if args[1] == "reset" {
+					guild.PersistentGuildData.VoicePriorities.Reset()
+				} else {
+					origin := getPhaseFromArgs(args[1:])
+					dest := getPhaseFromArgs(args[2:])
+					if origin == game.UNINITIALIZED || dest == game.UNINITIALIZED || origin == dest {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+						return
+					}
+					steps, err := parsePrioritySteps(args[3:])
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.badOrdering", guild.errorText(err)))
+						return
+					}
+					guild.PersistentGuildData.VoicePriorities.SetSteps(origin, dest, steps)
+				}
+				err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.priorityResponse())
+			case "drift":
+				if len(args[1:]) > 0 && args[1] == "now" {
+					guild.reconcile(s)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.Drift.ToStatusString(guild.PersistentGuildData.GetLanguage()))
+			case "games":
+				fallthrough
+			case "g":
+				s.ChannelMessageSend(m.ChannelID, guild.gamesResponse())
+			case "rules":
+				gs := guild.gameForCommand(s, g, m, gameID)
+				if gs == nil {
+					break
+				}
+				if len(args[1:]) > 0 {
+					if args[1] == DefaultPreset {
+						gs.SetVoiceRules(guild.PersistentGuildData.VoiceRules, "")
+					} else if rules, ok := guild.PersistentGuildData.VoicePresets.Get(args[1]); ok {
+						gs.SetVoiceRules(rules, args[1])
+					} else {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.unknownPreset", args[1], guild.PersistentGuildData.CommandPrefix))
+						return
+					}
+					//apply the new rules right away
+					guild.handleTrackedMembers(context.Background(), s, gs, 0, []PriorityStep{{Group: AnyGroup}})
+					guild.requestStatusEdit(gs)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.rulesResponse(gs))
+			case "preset":
+				fallthrough
+			case "presets":
+				fallthrough
+			case "pr":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.presetsResponse())
+					break
+				}
+				presets := &guild.PersistentGuildData.VoicePresets
+				var err error
+				switch {
+				case args[1] == "create" && len(args[2:]) >= 2:
+					var rules VoiceRules
+					rules, err = parseVoiceRules(args[3:])
+					if err == nil {
+						err = presets.SetCustom(args[2], rules)
+					}
+				case args[1] == "delete" && len(args[2:]) == 1:
+					err = presets.DeleteCustom(args[2])
+				case args[1] == "channel" && len(args[2:]) >= 1:
+					//without a channel name, use the voice channel the author is in
+					channelID := getVoiceChannel(s, g, m.Author.ID)
+					if len(args[3:]) > 0 {
+						channelID = ""
+						channelName := strings.Join(args[3:], " ")
+						for _, c := range g.Channels {
+							if (strings.ToLower(c.Name) == channelName || c.ID == channelName) && c.Type == discordgo.ChannelTypeGuildVoice {
+								channelID = c.ID
+								break
+							}
+						}
+					}
+					if channelID == "" {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.noVoiceChannel"))
+						return
+					}
+					err = presets.AssignChannel(channelID, args[2])
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					return
+				}
+				if err != nil {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.presetError", guild.errorText(err)))
+					return
+				}
+				err = guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				//members in reassigned channels might need different voice states now
+				for _, gs := range guild.Games.All() {
+					guild.handleTrackedMembers(context.Background(), s, gs, 0, []PriorityStep{{Group: AnyGroup}})
+					guild.requestStatusEdit(gs)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.presetsResponse())
+			case "language":
+				fallthrough
+			case "lang":
+				languages := strings.Join(locale.Languages(), ", ")
+				if len(args[1:]) > 0 {
+					if !locale.IsSupported(args[1]) {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.unknownLanguage", args[1], languages))
+						return
+					}
+					guild.PersistentGuildData.SetLanguage(args[1])
+					err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+					if err != nil {
+						guild.log().Error(err)
+					}
+					for _, gs := range guild.Games.All() {
+						guild.requestStatusEdit(gs)
+					}
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.text("reply.language", locale.LanguageName(guild.PersistentGuildData.GetLanguage()), languages))
+			case "template":
+				fallthrough
+			case "tmpl":
+				guild.handleTemplateCommand(s, g, m, gameID, args[1:], rawArgs[1:])
+			case "spoilers":
+				fallthrough
+			case "disclosure":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.disclosureResponse())
+					break
+				}
+				if args[1] == "reset" {
+					guild.PersistentGuildData.Disclosure.Reset()
+				} else {
+					phase := getPhaseFromArgs(args[1:])
+					if phase == game.UNINITIALIZED || len(args[1:]) < 3 || (args[3] != "on" && args[3] != "off") {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+						break
+					}
+					err := guild.PersistentGuildData.Disclosure.SetPart(phase, args[2], args[3] == "on")
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.disclosureError", guild.errorText(err)))
+						break
+					}
+				}
+				err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+				if err != nil {
+					guild.log().Error(err)
+				}
+				for _, gs := range guild.Games.All() {
+					guild.requestStatusEdit(gs)
+				}
+				s.ChannelMessageSend(m.ChannelID, guild.disclosureResponse())
+			case "emojis":
+				if len(args[1:]) == 0 {
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+					break
+				}
+				switch args[1] {
+				case "cleanup":
+					removed, err := guild.removeAllEmojis(s, m.GuildID)
+					if err != nil {
+						guild.log().Error(err)
+						s.ChannelMessageSend(m.ChannelID, guild.text("reply.emojisError", err.Error()))
+						break
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.emojisRemoved", removed, guild.PersistentGuildData.CommandPrefix))
+				case "status":
+					s.ChannelMessageSend(m.ChannelID, guild.emojiStatusResponse())
+				case "sync":
+					guild.syncAllEmojis(s, m.GuildID, guild.EmojiGuildID)
+					for _, gs := range guild.Games.All() {
+						guild.requestStatusEdit(gs)
+					}
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.emojisSynced"))
+				default:
+					s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", guild.PersistentGuildData.CommandPrefix))
+				}
 			case "refresh":
 				fallthrough
 			case "r":
-				guild.GameStateMsg.Delete(s) //delete the old message
+				gs := guild.gameForCommand(s, g, m, gameID)
+				if gs == nil {
+					break
+				}
+				gs.GameStateMsg.Delete(s) //delete the old message
 
 				//create a new instance of the new one
-				guild.GameStateMsg.CreateMessage(s, gameStateResponse(guild), m.ChannelID)
+				gs.GameStateMsg.CreateMessage(s, gameStateResponse(guild, gs), m.ChannelID)
 
 				//add the emojis to the refreshed message
-				for _, e := range guild.StatusEmojis[true] {
-					guild.GameStateMsg.AddReaction(s, e.FormatForReaction())
+				for _, e := range guild.StatusEmojis()[true] {
+					gs.GameStateMsg.AddReaction(s, e.FormatForReaction())
 				}
-				guild.GameStateMsg.AddReaction(s, "❌")
+				gs.GameStateMsg.AddReaction(s, "❌")
 			default:
-				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Sorry, I didn't understand that command! Please see `%s help` for commands", guild.PersistentGuildData.CommandPrefix))
+				s.ChannelMessageSend(m.ChannelID, guild.text("reply.unknownCommand", guild.PersistentGuildData.CommandPrefix))
 
 			}
 		}
 		//Just deletes messages starting with .au
 
-		if guild.GameStateMsg.SameChannel(m.ChannelID) {
-			deleteMessage(s, m.ChannelID, m.Message.ID)
+		for _, gs := range guild.Games.All() {
+			if gs.GameStateMsg.SameChannel(m.ChannelID) {
+				deleteMessage(s, m.ChannelID, m.Message.ID)
+				break
+			}
 		}
 
 	}
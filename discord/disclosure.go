@@ -0,0 +1,174 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+)
+
+// Disclosure is what the status message is allowed to show during one phase
+type Disclosure struct {
+	//AliveStatus shows who is dead. When hidden, everyone is shown as alive
+	AliveStatus bool `json:"aliveStatus"`
+	//Names shows in-game names. When hidden, players are shown by color
+	Names bool `json:"names"`
+	//LinkState shows which discord users are linked to which players, and how many are linked
+	LinkState bool `json:"linkState"`
+	//DeathOrder shows the order players died in
+	DeathOrder bool `json:"deathOrder"`
+}
+
+// DisclosurePolicy controls what the status message reveals in each phase, so it can't spoil the game
+type DisclosurePolicy struct {
+	Phases map[game.PhaseNameString]Disclosure `json:"phases"`
+
+	lock sync.RWMutex
+}
+
+// MakeDefaultDisclosurePolicy never shows who died during tasks. During discussion the game itself shows who is dead,
+// so that's disclosed too, but not the order they died in; the lobby shows everything about the last game
+func MakeDefaultDisclosurePolicy() DisclosurePolicy {
+	return DisclosurePolicy{
+		Phases: map[game.PhaseNameString]Disclosure{
+			game.PhaseNames[game.LOBBY]:   {AliveStatus: true, Names: true, LinkState: true, DeathOrder: true},
+			game.PhaseNames[game.TASKS]:   {AliveStatus: false, Names: true, LinkState: true, DeathOrder: false},
+			game.PhaseNames[game.DISCUSS]: {AliveStatus: true, Names: true, LinkState: true, DeathOrder: false},
+		},
+		lock: sync.RWMutex{},
+	}
+}
+
+// MarshalJSON marshals the policy while nothing can change it
+func (dp *DisclosurePolicy) MarshalJSON() ([]byte, error) {
+	dp.lock.RLock()
+	defer dp.lock.RUnlock()
+	return json.Marshal(struct {
+		Phases map[game.PhaseNameString]Disclosure `json:"phases"`
+	}{dp.Phases})
+}
+
+// Reset goes back to the default policy
+func (dp *DisclosurePolicy) Reset() {
+	dp.lock.Lock()
+	dp.Phases = MakeDefaultDisclosurePolicy().Phases
+	dp.lock.Unlock()
+}
+
+// Get returns what may be shown in a phase. Phases without a policy reveal nothing
+func (dp *DisclosurePolicy) Get(phase game.Phase) Disclosure {
+	dp.lock.RLock()
+	defer dp.lock.RUnlock()
+	return dp.get(phase)
+}
+
+//get is Get, for when the lock is already held
+func (dp *DisclosurePolicy) get(phase game.Phase) Disclosure {
+	if dp.Phases != nil {
+		if v, ok := dp.Phases[game.PhaseNames[phase]]; ok {
+			return v
+		}
+	}
+	if phase == game.LOBBY || phase == game.MENU {
+		return Disclosure{AliveStatus: true, Names: true, LinkState: true, DeathOrder: true}
+	}
+	return Disclosure{}
+}
+
+func (dp *DisclosurePolicy) Set(phase game.Phase, disclosure Disclosure) {
+	dp.lock.Lock()
+	defer dp.lock.Unlock()
+	dp.set(phase, disclosure)
+}
+
+func (dp *DisclosurePolicy) set(phase game.Phase, disclosure Disclosure) {
+	if dp.Phases == nil {
+		dp.Phases = map[game.PhaseNameString]Disclosure{}
+	}
+	dp.Phases[game.PhaseNames[phase]] = disclosure
+}
+
+// SetPart turns one part of the disclosure for a phase on or off
+func (dp *DisclosurePolicy) SetPart(phase game.Phase, part string, show bool) error {
+	dp.lock.Lock()
+	defer dp.lock.Unlock()
+	disclosure := dp.get(phase)
+	switch part {
+	case "alive":
+		disclosure.AliveStatus = show
+	case "names":
+		disclosure.Names = show
+	case "links":
+		disclosure.LinkState = show
+	case "deaths":
+		disclosure.DeathOrder = show
+	default:
+		return locale.Errorf("error.unknownPart", part)
+	}
+	dp.set(phase, disclosure)
+	return nil
+}
+
+//ToString lists the parts shown by the words used to change them, or "nothing" in the language
+func (d Disclosure) ToString(lang string) string {
+	parts := make([]string, 0)
+	for _, v := range []struct {
+		name string
+		show bool
+	}{{"alive", d.AliveStatus}, {"names", d.Names}, {"links", d.LinkState}, {"deaths", d.DeathOrder}} {
+		if v.show {
+			parts = append(parts, v.name)
+		}
+	}
+	if len(parts) == 0 {
+		return locale.Get(lang, "reply.disclosureNothing")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (dp *DisclosurePolicy) ToStatusString(lang string) string {
+	buf := bytes.NewBuffer([]byte{})
+	for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		disclosure := dp.Get(phase)
+		buf.WriteString(locale.Get(lang, "reply.disclosurePhase", locale.PhaseName(lang, phase), disclosure.ToString(lang)) + "\n")
+	}
+	return buf.String()
+}
+
+// DeathLog records the order players died in during a game
+type DeathLog struct {
+	dead []game.PlayerData
+	lock sync.RWMutex
+}
+
+func (dl *DeathLog) Record(player game.Player) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	for _, v := range dl.dead {
+		//nobody shares a color during a game, but names can be shared
+		if v.Color == player.Color && v.Name == player.Name {
+			return
+		}
+	}
+	dl.dead = append(dl.dead, game.PlayerData{Color: player.Color, Name: player.Name, IsAlive: false})
+}
+
+func (dl *DeathLog) Reset() {
+	dl.lock.Lock()
+	dl.dead = nil
+	dl.lock.Unlock()
+}
+
+// GetAll returns the dead, in the order they died
+func (dl *DeathLog) GetAll() []game.PlayerData {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+	return append([]game.PlayerData{}, dl.dead...)
+}
+
+func (guild *GuildState) disclosureResponse() string {
+	return guild.text("reply.disclosure") + "\n" + guild.PersistentGuildData.Disclosure.ToStatusString(guild.PersistentGuildData.GetLanguage())
+}
@@ -0,0 +1,175 @@
+package discord
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+)
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//startDisclosureGame starts a game during tasks where PlayerZed (red, user0) has died and is muted, and PlayerAnn
+//(blue, user1) is alive
+func startDisclosureGame(t *testing.T) (*discordgo.Session, *GuildState, *GameState) {
+	const guildID = "disclosure"
+	s, guild := startTestGuild(t, guildID, 2)
+
+	command(s, guildID, ".au new")
+	gs := guild.Games.Get("1")
+	if gs == nil {
+		t.Fatal("game wasn't started")
+	}
+	handleCaptureMessage(s, CaptureMessage{CaptureID: "capture", Event: CaptureConnect, Data: gs.GetLinkCode()})
+	players := []game.Player{{Action: game.JOINED, Name: "PlayerZed", Color: game.Red}, {Action: game.JOINED, Name: "PlayerAnn", Color: game.Blue}}
+	for _, v := range players {
+		handleCaptureMessage(s, playerMessage("capture", v))
+	}
+	waitFor(t, "the players to join", func() bool { return gs.AmongUsData.NumDetectedPlayers() == len(players) })
+	for i, v := range players {
+		userID := "user" + string(rune('0'+i))
+		voiceStateChange(s, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{GuildID: guildID, UserID: userID, ChannelID: "voice"}})
+		command(s, guildID, ".au link <@"+userID+"> "+game.GetColorStringForInt(v.Color))
+	}
+	if linked := len(guild.UserData.GetLinkedUsers(gs.ID)); linked != len(players) {
+		t.Fatalf("%d players were linked, want %d", linked, len(players))
+	}
+
+	handleCaptureMessage(s, CaptureMessage{CaptureID: "capture", Event: CaptureState, Data: "1"})
+	waitFor(t, "tasks", func() bool { return gs.AmongUsData.GetPhase() == game.TASKS })
+	handleCaptureMessage(s, playerMessage("capture", game.Player{Action: game.DIED, Name: "PlayerZed", Color: game.Red, IsDead: true}))
+	waitFor(t, "PlayerZed to die", func() bool { return len(gs.Deaths.GetAll()) == 1 })
+	s.State.OnInterface(s, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{GuildID: guildID, UserID: "user0", ChannelID: "voice", Mute: true}})
+	return s, guild, gs
+}
+
+// Each part of the disclosure policy is checked on its own, in the status message and in the overlay
+func TestDisclosureHidesEachPart(t *testing.T) {
+	s, guild, gs := startDisclosureGame(t)
+	lang := guild.PersistentGuildData.GetLanguage()
+	defaults := MakeDefaultDisclosurePolicy()
+
+	tests := []struct {
+		name       string
+		disclosure Disclosure
+		deaths     []string
+	}{
+		{"everything", Disclosure{AliveStatus: true, Names: true, LinkState: true, DeathOrder: true}, []string{"PlayerZed"}},
+		{"nothing", Disclosure{}, []string{}},
+		{"default during tasks", defaults.Get(game.TASKS), []string{}},
+		{"alive only", Disclosure{AliveStatus: true}, []string{}},
+		{"names only", Disclosure{Names: true}, []string{}},
+		{"links without alive", Disclosure{LinkState: true}, []string{}},
+		{"links with alive", Disclosure{LinkState: true, AliveStatus: true}, []string{}},
+		{"deaths by name", Disclosure{DeathOrder: true, Names: true}, []string{"PlayerZed"}},
+		{"deaths by color", Disclosure{DeathOrder: true}, []string{"red"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guild.PersistentGuildData.Disclosure.Set(game.TASKS, tt.disclosure)
+			d := tt.disclosure
+
+			data := guild.makeEmbedTemplateData(gs)
+			msg, err := guild.PersistentGuildData.EmbedTemplates.Render(lang, game.TASKS, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			embedJSON, _ := json.Marshal(msg)
+			embed := string(embedJSON)
+			og := guild.overlayGameState(s, gs)
+			overlayJSON, _ := json.Marshal(og)
+			overlay := string(overlayJSON)
+
+			for _, name := range []string{"PlayerZed", "PlayerAnn"} {
+				if strings.Contains(embed, name) != d.Names {
+					t.Errorf("status message shows %s: %v, want %v", name, !d.Names, d.Names)
+				}
+				if strings.Contains(overlay, name) != d.Names {
+					t.Errorf("overlay shows %s: %v, want %v", name, !d.Names, d.Names)
+				}
+			}
+
+			if strings.Contains(embed, "💀") != d.AliveStatus {
+				t.Errorf("status message shows the dead emoji: %v, want %v", !d.AliveStatus, d.AliveStatus)
+			}
+			for _, v := range data.Players {
+				if v.UserID == "user0" && v.Alive == d.AliveStatus {
+					t.Errorf("user0 is shown alive: %v, want %v", v.Alive, !d.AliveStatus)
+				}
+			}
+			red := og.Players[game.GetColorStringForInt(game.Red)]
+			if (red.Alive != nil) != d.AliveStatus || (red.Alive != nil && *red.Alive) {
+				t.Errorf("overlay shows red alive: %v, want hidden: %v", red.Alive, !d.AliveStatus)
+			}
+
+			for _, userID := range []string{"user0", "user1"} {
+				if strings.Contains(embed, userID) != d.LinkState {
+					t.Errorf("status message shows %s: %v, want %v", userID, !d.LinkState, d.LinkState)
+				}
+				if strings.Contains(overlay, userID) != d.LinkState {
+					t.Errorf("overlay shows %s: %v, want %v", userID, !d.LinkState, d.LinkState)
+				}
+			}
+			//who is muted gives away who is dead, so it's only shown along with who is dead
+			showVoice := d.LinkState && d.AliveStatus
+			if (red.Muted != nil) != showVoice || (red.Deafened != nil) != showVoice {
+				t.Errorf("overlay shows red's voice state: %v, want %v", red.Muted != nil, showVoice)
+			} else if showVoice && !*red.Muted {
+				t.Error("overlay shows red unmuted")
+			}
+
+			if !reflect.DeepEqual(data.Deaths, tt.deaths) {
+				t.Errorf("status message deaths are %v, want %v", data.Deaths, tt.deaths)
+			}
+			if len(og.Deaths) != len(tt.deaths) {
+				t.Errorf("overlay deaths are %v, want %v", og.Deaths, tt.deaths)
+			}
+			if strings.Contains(embed, guild.text("field.deaths")) != (len(tt.deaths) > 0) {
+				t.Errorf("status message shows the death order: %v, want %v", len(tt.deaths) == 0, len(tt.deaths) > 0)
+			}
+		})
+	}
+}
+
+// With the default policy nothing about who died, or who is muted because of it, is shown during tasks
+func TestDefaultDisclosureShowsNoDeathsDuringTasks(t *testing.T) {
+	s, guild, gs := startDisclosureGame(t)
+
+	embedJSON, _ := json.Marshal(gameStateResponse(guild, gs))
+	for _, spoiler := range []string{"💀", guild.text("field.deaths")} {
+		if strings.Contains(string(embedJSON), spoiler) {
+			t.Errorf("status message shows %q during tasks", spoiler)
+		}
+	}
+	data := guild.makeEmbedTemplateData(gs)
+	for _, v := range data.Players {
+		if !v.Alive {
+			t.Errorf("%s is shown dead during tasks", v.Name)
+		}
+	}
+	if len(data.Deaths) > 0 {
+		t.Errorf("deaths are shown during tasks: %v", data.Deaths)
+	}
+
+	og := guild.overlayState(s, "").Games[gs.ID]
+	for key, v := range og.Players {
+		if v.Alive != nil || v.Muted != nil || v.Deafened != nil {
+			t.Errorf("overlay shows %s's alive or voice state during tasks", key)
+		}
+	}
+	if len(og.Deaths) > 0 {
+		t.Errorf("overlay shows deaths during tasks: %v", og.Deaths)
+	}
+}
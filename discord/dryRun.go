@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discord rejects messages longer than this
+const maxMessageLength = 2000
+
+// MemberStateDiff holds the current and desired voice/nickname state of a member
+type MemberStateDiff struct {
+	Params UserPatchParameters
+
+	CurrentMute bool
+	CurrentDeaf bool
+	CurrentNick string
+}
+
+// ToString shows only the parts of the state that would actually change
+func (diff *MemberStateDiff) ToString() string {
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(fmt.Sprintf("<@!%s>:", diff.Params.UserID))
+	changed := false
+	if diff.CurrentMute != diff.Params.Mute {
+		buf.WriteString(fmt.Sprintf(" mute `%v` → `%v`", diff.CurrentMute, diff.Params.Mute))
+		changed = true
+	}
+	if diff.CurrentDeaf != diff.Params.Deaf {
+		buf.WriteString(fmt.Sprintf(" deaf `%v` → `%v`", diff.CurrentDeaf, diff.Params.Deaf))
+		changed = true
+	}
+	if diff.Params.Nick != "" && diff.CurrentNick != diff.Params.Nick {
+		buf.WriteString(fmt.Sprintf(" nick `%s` → `%s`", diff.CurrentNick, diff.Params.Nick))
+		changed = true
+	}
+	if !changed {
+		buf.WriteString(" no change")
+	}
+	return buf.String()
+}
+
+// DryRunReports remembers the last change reported for each member, so members that stay drifted aren't reported
+// over and over
+type DryRunReports struct {
+	last map[string]string
+	lock sync.Mutex
+}
+
+func MakeDryRunReports() *DryRunReports {
+	return &DryRunReports{
+		last: map[string]string{},
+		lock: sync.Mutex{},
+	}
+}
+
+// Changed records the change for the member, and is true if it's different to the last one reported for them
+func (dr *DryRunReports) Changed(diff MemberStateDiff) bool {
+	line := diff.ToString()
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	if dr.last[diff.Params.UserID] == line {
+		return false
+	}
+	dr.last[diff.Params.UserID] = line
+	return true
+}
+
+// Forget forgets the last change reported for a member, once they're back how they should be
+func (dr *DryRunReports) Forget(userID string) {
+	dr.lock.Lock()
+	delete(dr.last, userID)
+	dr.lock.Unlock()
+}
+
+//reportDryRun posts the changes the bot would have made to the dry-run channel, instead of applying them
+func (guild *GuildState) reportDryRun(s *discordgo.Session, reason string, diffs []MemberStateDiff) {
+	if len(diffs) == 0 {
+		return
+	}
+	channelID := guild.PersistentGuildData.GetDryRunChannelID()
+	if channelID == "" {
+		guild.log().Warnf("Dry run is enabled, but no channel is set; discarding %d change(s)", len(diffs))
+		return
+	}
+
+	header := guild.text("dryRun.header", reason, len(diffs)) + "\n"
+	buf := bytes.NewBufferString(header)
+	for _, diff := range diffs {
+		line := diff.ToString() + "\n"
+		if buf.Len()+len(line) > maxMessageLength {
+			sendMessage(s, channelID, buf.String())
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		sendMessage(s, channelID, buf.String())
+	}
+}
+
+func (guild *GuildState) dryRunResponse(enabled bool) string {
+	if !enabled {
+		return guild.text("reply.dryRunOff")
+	}
+	return guild.text("reply.dryRunOn", guild.PersistentGuildData.GetDryRunChannelID())
+}
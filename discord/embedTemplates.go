@@ -0,0 +1,658 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+)
+
+// Limits discord puts on the parts of an embed
+const (
+	maxEmbedTitleLength       = 256
+	maxEmbedDescriptionLength = 2048
+	maxEmbedFooterLength      = 2048
+	maxEmbedFieldNameLength   = 256
+	maxEmbedFieldValueLength  = 1024
+	maxEmbedFields            = 25
+)
+
+// EmbedFieldTemplate is a single field of the status embed
+type EmbedFieldTemplate struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// EmbedTemplate holds the text/template strings used to build the status embed in one phase
+type EmbedTemplate struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Footer      string `json:"footer"`
+	Color       int    `json:"color"`
+
+	//Fields replace the room/region/tracking fields; when empty, those default fields are used
+	Fields []EmbedFieldTemplate `json:"fields"`
+}
+
+// EmbedTemplates are the status embed templates of a guild, per phase. Phases without a template of their own use
+//the default template in the guild's language
+type EmbedTemplates struct {
+	Phases map[game.PhaseNameString]EmbedTemplate `json:"phases"`
+
+	//ThumbnailURL is shown in the corner of the status embed. Empty means no thumbnail
+	ThumbnailURL string `json:"thumbnailURL"`
+
+	lock sync.RWMutex
+}
+
+func MakeDefaultEmbedTemplates() EmbedTemplates {
+	return EmbedTemplates{
+		Phases:       map[game.PhaseNameString]EmbedTemplate{},
+		ThumbnailURL: "",
+		lock:         sync.RWMutex{},
+	}
+}
+
+// MarshalJSON marshals the templates while nothing can change them
+func (et *EmbedTemplates) MarshalJSON() ([]byte, error) {
+	et.lock.RLock()
+	defer et.lock.RUnlock()
+	return json.Marshal(struct {
+		Phases       map[game.PhaseNameString]EmbedTemplate `json:"phases"`
+		ThumbnailURL string                                 `json:"thumbnailURL"`
+	}{et.Phases, et.ThumbnailURL})
+}
+
+func defaultEmbedTemplate(lang string, phase game.Phase) EmbedTemplate {
+	switch phase {
+	case game.LOBBY:
+		return EmbedTemplate{
+			Title:       locale.Get(lang, "embed.lobby.title"),
+			Description: locale.Get(lang, "embed.lobby.description"),
+			Footer:      locale.Get(lang, "embed.lobby.footer"),
+			Color:       3066993, //GREEN
+		}
+	case game.TASKS:
+		return EmbedTemplate{
+			Title:       locale.Get(lang, "embed.running.title"),
+			Description: locale.Get(lang, "embed.running.description"),
+			Color:       3447003, //BLUE
+		}
+	case game.DISCUSS:
+		return EmbedTemplate{
+			Title:       locale.Get(lang, "embed.running.title"),
+			Description: locale.Get(lang, "embed.running.description"),
+			Color:       10181046, //PURPLE
+		}
+	default:
+		return EmbedTemplate{
+			Title:       locale.Get(lang, "embed.running.title"),
+			Description: locale.Get(lang, "embed.running.description"),
+			Color:       15158332, //RED
+		}
+	}
+}
+
+// Get returns the template for a phase, falling back to the default one in the language
+func (et *EmbedTemplates) Get(lang string, phase game.Phase) EmbedTemplate {
+	et.lock.RLock()
+	defer et.lock.RUnlock()
+	if et.Phases != nil {
+		if v, ok := et.Phases[game.PhaseNames[phase]]; ok {
+			return v
+		}
+	}
+	return defaultEmbedTemplate(lang, phase)
+}
+
+func (et *EmbedTemplates) Set(phase game.Phase, tmpl EmbedTemplate) {
+	et.lock.Lock()
+	defer et.lock.Unlock()
+	if et.Phases == nil {
+		et.Phases = map[game.PhaseNameString]EmbedTemplate{}
+	}
+	et.Phases[game.PhaseNames[phase]] = tmpl
+}
+
+// Reset makes a phase use the default template again
+func (et *EmbedTemplates) Reset(phase game.Phase) {
+	et.lock.Lock()
+	delete(et.Phases, game.PhaseNames[phase])
+	et.lock.Unlock()
+}
+
+// GetThumbnailURL returns the URL of the thumbnail, or "" if there isn't one
+func (et *EmbedTemplates) GetThumbnailURL() string {
+	et.lock.RLock()
+	defer et.lock.RUnlock()
+	return et.ThumbnailURL
+}
+
+// Replace swaps in templates edited on a Copy, all at once
+func (et *EmbedTemplates) Replace(templates *EmbedTemplates) {
+	templates.lock.RLock()
+	phases, thumbnailURL := templates.Phases, templates.ThumbnailURL
+	templates.lock.RUnlock()
+
+	et.lock.Lock()
+	et.Phases = phases
+	et.ThumbnailURL = thumbnailURL
+	et.lock.Unlock()
+}
+
+// Copy returns templates that can be changed without changing the originals
+func (et *EmbedTemplates) Copy() *EmbedTemplates {
+	et.lock.RLock()
+	defer et.lock.RUnlock()
+	templates := &EmbedTemplates{
+		Phases:       map[game.PhaseNameString]EmbedTemplate{},
+		ThumbnailURL: et.ThumbnailURL,
+		lock:         sync.RWMutex{},
+	}
+	for k, v := range et.Phases {
+		v.Fields = append([]EmbedFieldTemplate{}, v.Fields...)
+		templates.Phases[k] = v
+	}
+	return templates
+}
+
+// EmbedTemplatePlayer is a linked player, as seen by templates
+type EmbedTemplatePlayer struct {
+	Name     string
+	Color    string
+	Alive    bool
+	UserID   string
+	UserName string
+	Mention  string
+	Emoji    string
+
+	//shown instead of Emoji when the disclosure policy hides who is dead
+	aliveEmoji string
+}
+
+// EmbedTemplateData is everything a status embed template can use
+type EmbedTemplateData struct {
+	GameID        string
+	Phase         string
+	Room          string
+	Region        string
+	Tracking      string
+	VoiceRules    string
+	Countdown     string
+	CaptureLinked bool
+	LinkCode      string
+	Alarm         string
+
+	LinkedPlayers   int
+	DetectedPlayers int
+	Players         []EmbedTemplatePlayer
+	//Deaths are the names (or colors, when names are hidden) of the dead, in the order they died
+	Deaths []string
+
+	//the fields used when a template doesn't have any of its own, and the player list
+	defaultFields []*discordgo.MessageEmbedField
+	playerFields  []*discordgo.MessageEmbedField
+}
+
+var embedTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+}
+
+func executeEmbedTemplate(name, text string, data EmbedTemplateData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Funcs(embedTemplateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBuffer([]byte{})
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func checkEmbedLength(part, str string, max int) error {
+	if len([]rune(str)) > max {
+		return locale.Errorf("error.tooLong", part, len([]rune(str)), max)
+	}
+	return nil
+}
+
+// Render builds the status embed from the template for a phase
+func (et *EmbedTemplates) Render(lang string, phase game.Phase, data EmbedTemplateData) (*discordgo.MessageEmbed, error) {
+	tmpl := et.Get(lang, phase)
+
+	title, err := executeEmbedTemplate("title", tmpl.Title, data)
+	if err == nil {
+		err = checkEmbedLength(locale.Get(lang, "template.titlePart"), title, maxEmbedTitleLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+	desc, err := executeEmbedTemplate("description", tmpl.Description, data)
+	if err == nil {
+		err = checkEmbedLength(locale.Get(lang, "template.descriptionPart"), desc, maxEmbedDescriptionLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+	footerText, err := executeEmbedTemplate("footer", tmpl.Footer, data)
+	if err == nil {
+		err = checkEmbedLength(locale.Get(lang, "template.footerPart"), footerText, maxEmbedFooterLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields := data.defaultFields
+	if len(tmpl.Fields) > 0 {
+		fields = make([]*discordgo.MessageEmbedField, 0, len(tmpl.Fields))
+		for i, v := range tmpl.Fields {
+			name, err := executeEmbedTemplate(fmt.Sprintf("field %d name", i+1), v.Name, data)
+			if err == nil {
+				err = checkEmbedLength(locale.Get(lang, "template.fieldNamePart", i+1), name, maxEmbedFieldNameLength)
+			}
+			if err != nil {
+				return nil, err
+			}
+			value, err := executeEmbedTemplate(fmt.Sprintf("field %d value", i+1), v.Value, data)
+			if err == nil {
+				err = checkEmbedLength(locale.Get(lang, "template.fieldValuePart", i+1), value, maxEmbedFieldValueLength)
+			}
+			if err != nil {
+				return nil, err
+			}
+			//discord rejects fields with an empty name or value, so those just aren't shown
+			if strings.TrimSpace(name) == "" || strings.TrimSpace(value) == "" {
+				continue
+			}
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   name,
+				Value:  value,
+				Inline: v.Inline,
+			})
+		}
+	}
+	fields = append(fields, data.playerFields...)
+	if len(fields) > maxEmbedFields {
+		fields = fields[:maxEmbedFields]
+	}
+
+	var footer *discordgo.MessageEmbedFooter
+	if footerText != "" {
+		footer = &discordgo.MessageEmbedFooter{
+			Text:         footerText,
+			IconURL:      "",
+			ProxyIconURL: "",
+		}
+	}
+	var thumbnail *discordgo.MessageEmbedThumbnail
+	if thumbnailURL := et.GetThumbnailURL(); thumbnailURL != "" {
+		thumbnail = &discordgo.MessageEmbedThumbnail{
+			URL: thumbnailURL,
+		}
+	}
+
+	msg := discordgo.MessageEmbed{
+		URL:         "",
+		Type:        "",
+		Title:       title,
+		Description: desc,
+		Timestamp:   "",
+		Footer:      footer,
+		Color:       tmpl.Color,
+		Image:       nil,
+		Thumbnail:   thumbnail,
+		Video:       nil,
+		Provider:    nil,
+		Author:      nil,
+		Fields:      fields,
+	}
+	return &msg, nil
+}
+
+// Validate renders every phase with sample data, to catch mistakes before they break the status message
+func (et *EmbedTemplates) Validate(lang string) error {
+	for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		tmpl := et.Get(lang, phase)
+		if tmpl.Color < 0 || tmpl.Color > 0xFFFFFF {
+			return locale.Errorf("error.templateColor", locale.PhaseName(lang, phase))
+		}
+		if len(tmpl.Fields) > maxEmbedFields {
+			return locale.Errorf("error.tooManyFields", locale.PhaseName(lang, phase), maxEmbedFields)
+		}
+		if _, err := et.Render(lang, phase, sampleEmbedTemplateData(lang, phase)); err != nil {
+			return locale.Errorf("error.inPhase", locale.PhaseName(lang, phase), err)
+		}
+	}
+	if thumbnailURL := et.GetThumbnailURL(); thumbnailURL != "" && !strings.HasPrefix(thumbnailURL, "https://") && !strings.HasPrefix(thumbnailURL, "http://") {
+		return locale.Errorf("error.thumbnailURL")
+	}
+	return nil
+}
+
+// sampleEmbedTemplateData is used to validate and preview templates when there's no game to show
+func sampleEmbedTemplateData(lang string, phase game.Phase) EmbedTemplateData {
+	players := []EmbedTemplatePlayer{
+		{Name: "Bob", Color: locale.ColorName(lang, game.Red), Alive: true, UserID: "0", UserName: "bob123", Mention: "@bob123", Emoji: ":red_circle:", aliveEmoji: ":red_circle:"},
+		{Name: "Alice", Color: locale.ColorName(lang, game.Cyan), Alive: false, UserID: "1", UserName: "alice", Mention: "@alice", Emoji: ":x:", aliveEmoji: ":blue_circle:"},
+	}
+	data := EmbedTemplateData{
+		GameID:          "1",
+		Phase:           locale.PhaseName(lang, phase),
+		Room:            "ABCDEF",
+		Region:          "North America",
+		Tracking:        locale.Get(lang, "tracking.any"),
+		VoiceRules:      DefaultPreset,
+		Countdown:       "",
+		CaptureLinked:   false,
+		LinkCode:        "A1B2C3D4",
+		Alarm:           ":x:",
+		LinkedPlayers:   len(players),
+		DetectedPlayers: 10,
+		Players:         players,
+		Deaths:          []string{"Alice"},
+	}
+	tracking := MakeTracking()
+	data.defaultFields = lobbyMetaEmbedFields(lang, &tracking, data.Room, data.Region, data.DetectedPlayers, data.LinkedPlayers)
+	data.defaultFields = appendDeathsField(lang, data.defaultFields, data.Deaths)
+	data.playerFields = data.playerEmbedFields()
+	return data
+}
+
+// makeEmbedTemplateData collects the state of a game for the status embed templates
+func (guild *GuildState) makeEmbedTemplateData(gs *GameState) EmbedTemplateData {
+	lang := guild.PersistentGuildData.GetLanguage()
+	room, region := gs.AmongUsData.GetRoomRegion()
+	phase := gs.AmongUsData.GetPhase()
+	alarmFormatted := ":x:"
+	if v, ok := guild.SpecialEmojis()["alarm"]; ok {
+		alarmFormatted = v.FormatForInline()
+	}
+
+	linked := guild.UserData.GetLinkedUsers(gs.ID)
+	players := make([]EmbedTemplatePlayer, len(linked))
+	for i, v := range linked {
+		emoji := guild.StatusEmojis().Get(v.IsAlive(), v.GetColor())
+		aliveEmoji := guild.StatusEmojis().Get(true, v.GetColor())
+		players[i] = EmbedTemplatePlayer{
+			Name:       v.GetPlayerName(),
+			Color:      locale.ColorName(lang, v.GetColor()),
+			Alive:      v.IsAlive(),
+			UserID:     v.GetID(),
+			UserName:   v.GetUserName(),
+			Mention:    fmt.Sprintf("<@!%s>", v.GetID()),
+			Emoji:      emoji.FormatForInline(),
+			aliveEmoji: aliveEmoji.FormatForInline(),
+		}
+	}
+	deaths := make([]string, 0)
+	for _, v := range gs.Deaths.GetAll() {
+		deaths = append(deaths, v.Name)
+	}
+
+	data := EmbedTemplateData{
+		GameID:          gs.ID,
+		Phase:           locale.PhaseName(lang, phase),
+		Room:            room,
+		Region:          region,
+		Tracking:        gs.Tracking.ToStatusString(lang),
+		VoiceRules:      guild.voiceRulesStatusString(gs),
+		Countdown:       gs.Countdown.ToStatusString(lang),
+		CaptureLinked:   gs.GetLinkCode() == "",
+		LinkCode:        gs.GetLinkCode(),
+		Alarm:           alarmFormatted,
+		LinkedPlayers:   len(linked),
+		DetectedPlayers: gs.AmongUsData.NumDetectedPlayers(),
+		Players:         players,
+		Deaths:          deaths,
+	}
+
+	//every status message is rendered from this data, so this is the one place anything that could spoil the
+	//game needs to be removed
+	disclosure := guild.PersistentGuildData.Disclosure.Get(phase)
+	data.applyDisclosure(lang, disclosure, gs.Deaths.GetAll())
+	linkedPlayers := data.LinkedPlayers
+	if !disclosure.LinkState {
+		linkedPlayers = -1
+	}
+
+	data.defaultFields = lobbyMetaEmbedFields(lang, &gs.Tracking, room, region, data.DetectedPlayers, linkedPlayers)
+	data.defaultFields = appendVoiceRulesField(data.defaultFields, guild, gs)
+	data.defaultFields = appendCountdownField(lang, data.defaultFields, &gs.Countdown)
+	data.defaultFields = appendDeathsField(lang, data.defaultFields, data.Deaths)
+	data.playerFields = data.playerEmbedFields()
+	return data
+}
+
+// applyDisclosure removes anything the disclosure doesn't allow the status message to show
+func (data *EmbedTemplateData) applyDisclosure(lang string, disclosure Disclosure, dead []game.PlayerData) {
+	for i := range data.Players {
+		player := &data.Players[i]
+		if !disclosure.AliveStatus {
+			player.Alive = true
+			player.Emoji = player.aliveEmoji
+		}
+		if !disclosure.Names {
+			player.Name = ""
+		}
+		if !disclosure.LinkState {
+			player.UserID = ""
+			player.UserName = ""
+			player.Mention = ""
+		}
+	}
+	if !disclosure.LinkState {
+		data.LinkedPlayers = 0
+	}
+
+	data.Deaths = make([]string, 0)
+	if disclosure.DeathOrder {
+		for _, v := range dead {
+			if disclosure.Names {
+				data.Deaths = append(data.Deaths, v.Name)
+			} else {
+				data.Deaths = append(data.Deaths, locale.ColorName(lang, v.Color))
+			}
+		}
+	}
+}
+
+// playerEmbedFields lists the players, by name or by color when names are hidden
+func (data *EmbedTemplateData) playerEmbedFields() []*discordgo.MessageEmbedField {
+	fields := make([]*discordgo.MessageEmbedField, 0, len(data.Players))
+	for _, v := range data.Players {
+		name := v.Name
+		if name == "" {
+			name = v.Color
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   name,
+			Value:  strings.TrimSpace(fmt.Sprintf("%s %s", v.Emoji, v.Mention)),
+			Inline: true,
+		})
+	}
+	return fields
+}
+
+// parseEmbedColor accepts colors like #2ecc71, 0x2ecc71 or 3066993
+func parseEmbedColor(arg string) (int, error) {
+	var color int64
+	var err error
+	switch {
+	case strings.HasPrefix(arg, "#"):
+		color, err = strconv.ParseInt(arg[1:], 16, 32)
+	case strings.HasPrefix(arg, "0x"):
+		color, err = strconv.ParseInt(arg[2:], 16, 32)
+	default:
+		color, err = strconv.ParseInt(arg, 10, 32)
+	}
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, locale.Errorf("error.badColor", arg)
+	}
+	return int(color), nil
+}
+
+func (guild *GuildState) templateResponse(phase game.Phase) string {
+	templates := &guild.PersistentGuildData.EmbedTemplates
+	lang := guild.PersistentGuildData.GetLanguage()
+	tmpl := templates.Get(lang, phase)
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(guild.text("template.header", locale.PhaseName(lang, phase)) + "\n")
+	buf.WriteString(guild.text("template.title", tmpl.Title) + "\n")
+	buf.WriteString(guild.text("template.description", tmpl.Description) + "\n")
+	buf.WriteString(guild.text("template.footer", tmpl.Footer) + "\n")
+	buf.WriteString(guild.text("template.color", tmpl.Color) + "\n")
+	if len(tmpl.Fields) == 0 {
+		buf.WriteString(guild.text("template.defaultFields") + "\n")
+	}
+	for i, v := range tmpl.Fields {
+		buf.WriteString(guild.text("template.field", i+1, v.Name, v.Value) + "\n")
+	}
+	if thumbnailURL := templates.GetThumbnailURL(); thumbnailURL == "" {
+		buf.WriteString(guild.text("template.noThumbnail") + "\n")
+	} else {
+		buf.WriteString(guild.text("template.thumbnail", thumbnailURL) + "\n")
+	}
+	return buf.String()
+}
+
+//handleTemplateCommand shows, changes, previews and resets the status message templates
+func (guild *GuildState) handleTemplateCommand(s *discordgo.Session, g *discordgo.Guild, m *discordgo.MessageCreate, gameID string, args, rawArgs []string) {
+	prefix := guild.PersistentGuildData.CommandPrefix
+	lang := guild.PersistentGuildData.GetLanguage()
+	if len(args) == 0 {
+		for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+			s.ChannelMessageSend(m.ChannelID, guild.templateResponse(phase))
+		}
+		return
+	}
+
+	templates := guild.PersistentGuildData.EmbedTemplates.Copy()
+	switch args[0] {
+	case "preview":
+		phase := getPhaseFromArgs(args[1:])
+		//preview with a real game when there is one, otherwise with made up players
+		var data EmbedTemplateData
+		if gs, err := guild.resolveGame(s, g, m.Author.ID, gameID); err == nil {
+			data = guild.makeEmbedTemplateData(gs)
+			if phase == game.UNINITIALIZED {
+				phase = gs.AmongUsData.GetPhase()
+			} else {
+				data.Phase = locale.PhaseName(lang, phase)
+			}
+		} else {
+			if phase == game.UNINITIALIZED {
+				phase = game.LOBBY
+			}
+			data = sampleEmbedTemplateData(lang, phase)
+		}
+		msg, err := templates.Render(lang, phase, data)
+		if err != nil {
+			s.ChannelMessageSend(m.ChannelID, guild.text("reply.templateBroken", guild.errorText(err)))
+			return
+		}
+		sendMessageEmbed(s, m.ChannelID, msg)
+		return
+	case "reset":
+		if len(args[1:]) == 0 {
+			defaults := MakeDefaultEmbedTemplates()
+			templates = &defaults
+		} else {
+			phase := getPhaseFromArgs(args[1:])
+			if phase == game.UNINITIALIZED {
+				s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", prefix))
+				return
+			}
+			templates.Reset(phase)
+		}
+	case "thumbnail":
+		if len(args[1:]) == 0 {
+			s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", prefix))
+			return
+		}
+		switch args[1] {
+		case "none":
+			templates.ThumbnailURL = ""
+		case "bot":
+			templates.ThumbnailURL = Thumbnail.URL
+		default:
+			templates.ThumbnailURL = strings.Trim(rawArgs[1], "<>")
+		}
+	default:
+		phase := getPhaseFromArgs(args)
+		if phase == game.UNINITIALIZED {
+			s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", prefix))
+			return
+		}
+		if len(args[1:]) == 0 {
+			s.ChannelMessageSend(m.ChannelID, guild.templateResponse(phase))
+			return
+		}
+		tmpl := templates.Get(lang, phase)
+		text := strings.Join(rawArgs[2:], " ")
+		switch args[1] {
+		case "title":
+			tmpl.Title = text
+		case "description":
+			fallthrough
+		case "desc":
+			tmpl.Description = text
+		case "footer":
+			tmpl.Footer = text
+		case "color":
+			color, err := parseEmbedColor(strings.TrimSpace(text))
+			if err != nil {
+				s.ChannelMessageSend(m.ChannelID, guild.errorText(err))
+				return
+			}
+			tmpl.Color = color
+		case "field":
+			//fields are given as name | value
+			parts := strings.SplitN(text, "|", 2)
+			if len(parts) != 2 {
+				s.ChannelMessageSend(m.ChannelID, guild.text("reply.templateField", prefix))
+				return
+			}
+			tmpl.Fields = append(tmpl.Fields, EmbedFieldTemplate{
+				Name:   strings.TrimSpace(parts[0]),
+				Value:  strings.TrimSpace(parts[1]),
+				Inline: true,
+			})
+		case "fields":
+			//the only thing to do with all the fields at once is go back to the default ones
+			tmpl.Fields = nil
+		default:
+			s.ChannelMessageSend(m.ChannelID, guild.text("reply.usage", prefix))
+			return
+		}
+		templates.Set(phase, tmpl)
+	}
+
+	if err := templates.Validate(lang); err != nil {
+		s.ChannelMessageSend(m.ChannelID, guild.text("reply.templateNotSaved", guild.errorText(err)))
+		return
+	}
+	guild.PersistentGuildData.EmbedTemplates.Replace(templates)
+	err := guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+	if err != nil {
+		guild.log().Error(err)
+	}
+	for _, gs := range guild.Games.All() {
+		guild.requestStatusEdit(gs)
+	}
+	s.ChannelMessageSend(m.ChannelID, guild.text("reply.templateSaved", prefix))
+}
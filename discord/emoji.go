@@ -1,220 +1,316 @@
 package discord
 
 import (
+	"bytes"
 	"encoding/base64"
-	"github.com/denverquane/amongusdiscord/game"
-	"io/ioutil"
-	"log"
+	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/assets"
+	"github.com/denverquane/amongusdiscord/game"
 )
 
 // Emoji struct for discord
 type Emoji struct {
 	Name string
 	ID   string
+	//Fallback is the unicode emoji used when the custom emoji couldn't be found or added, like when the guild is out
+	//of emoji slots
+	Fallback string
+}
+
+// IsCustom is true when the custom emoji exists in the guild, and false when the fallback is used instead
+func (e *Emoji) IsCustom() bool {
+	return e.ID != ""
 }
 
 // FormatForReaction does what it sounds like
 func (e *Emoji) FormatForReaction() string {
+	if !e.IsCustom() {
+		return e.Fallback
+	}
 	return "<:" + e.Name + ":" + e.ID
 }
 
 // FormatForInline does what it sounds like
 func (e *Emoji) FormatForInline() string {
+	if !e.IsCustom() {
+		return e.Fallback
+	}
 	return "<:" + e.Name + ":" + e.ID + ">"
 }
 
-// GetDiscordCDNUrl does what it sounds like
-func (e *Emoji) GetDiscordCDNUrl() string {
-	return "https://cdn.discordapp.com/emojis/" + e.ID + ".png"
+// Matches is true when a reaction was made with this emoji
+func (e *Emoji) Matches(reaction discordgo.Emoji) bool {
+	if !e.IsCustom() {
+		return reaction.ID == "" && e.Fallback != "" && reaction.Name == e.Fallback
+	}
+	return reaction.ID == e.ID
 }
 
-// DownloadAndBase64Encode does what it sounds like
-func (e *Emoji) DownloadAndBase64Encode() string {
-	url := e.GetDiscordCDNUrl()
-	response, err := http.Get(url)
-	if err != nil {
-		log.Println(err)
-	}
-	defer response.Body.Close()
-	bytes, err := ioutil.ReadAll(response.Body)
+// imageDataURI encodes an image for uploading to discord, with the mime type of whatever format it is in
+func imageDataURI(image []byte) string {
+	//some of the emoji assets are webp, whatever their extension says
+	return "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image)
+}
+
+// emojiImage returns the image to upload for an emoji, and the hash of the bundled asset it came from. Every emoji
+// the bot adds has an asset, so syncing never needs to download anything
+func emojiImage(emoji Emoji) (string, string, error) {
+	image, err := assets.EmojiImage(emoji.Name)
 	if err != nil {
-		log.Println(err)
+		return "", "", err
 	}
-	encodedStr := base64.StdEncoding.EncodeToString(bytes)
-	return "data:image/png;base64," + encodedStr
+	return imageDataURI(image), assets.Hash(image), nil
 }
 
+// emptyStatusEmojis are the status emojis before any custom emojis are found, so they all use their fallback
 func emptyStatusEmojis() AlivenessEmojis {
 	topMap := make(AlivenessEmojis)
-	topMap[true] = make([]Emoji, 12) //12 colors for alive/dead
-	topMap[false] = make([]Emoji, 12)
+	for alive, emojis := range GlobalAlivenessEmojis {
+		topMap[alive] = make([]Emoji, len(emojis))
+		for i, v := range emojis {
+			topMap[alive][i] = Emoji{Name: v.Name, Fallback: v.Fallback}
+		}
+	}
 	return topMap
 }
 
-func (guild *GuildState) addSpecialEmojis(s *discordgo.Session, guildID string, serverEmojis []*discordgo.Emoji) {
-	for _, emoji := range GlobalSpecialEmojis {
-		alreadyExists := false
-		for _, v := range serverEmojis {
-			if v.Name == emoji.Name {
-				emoji.ID = v.ID
-				guild.SpecialEmojis[v.Name] = emoji
-				alreadyExists = true
-				break
-			}
+// emptySpecialEmojis are the special emojis before any custom emojis are found, so they all use their fallback
+func emptySpecialEmojis() map[string]Emoji {
+	emojis := map[string]Emoji{}
+	for k, v := range GlobalSpecialEmojis {
+		emojis[k] = Emoji{Name: v.Name, Fallback: v.Fallback}
+	}
+	return emojis
+}
+
+// StatusEmojis returns the guild's status emojis, which mustn't be changed
+func (guild *GuildState) StatusEmojis() AlivenessEmojis {
+	guild.emojiLock.RLock()
+	defer guild.emojiLock.RUnlock()
+	return guild.statusEmojis
+}
+
+// SpecialEmojis returns the guild's special emojis, which mustn't be changed
+func (guild *GuildState) SpecialEmojis() map[string]Emoji {
+	guild.emojiLock.RLock()
+	defer guild.emojiLock.RUnlock()
+	return guild.specialEmojis
+}
+
+func (guild *GuildState) setEmojis(status AlivenessEmojis, special map[string]Emoji) {
+	guild.emojiLock.Lock()
+	guild.statusEmojis = status
+	guild.specialEmojis = special
+	guild.emojiLock.Unlock()
+}
+
+// syncEmoji makes sure an emoji exists in the guild and is up to date with its asset, and returns it with the ID it
+// has in the guild. Emojis that live in another guild (see EMOJI_GUILD_ID) are used as they are
+func (guild *GuildState) syncEmoji(s *discordgo.Session, guildID string, emoji Emoji, serverEmojis []*discordgo.Emoji, ownEmojis bool) (Emoji, error) {
+	var existing *discordgo.Emoji
+	for _, v := range serverEmojis {
+		if v.Name == emoji.Name {
+			existing = v
+			break
 		}
-		if !alreadyExists {
-			b64 := emoji.DownloadAndBase64Encode()
-			em, err := s.GuildEmojiCreate(guildID, emoji.Name, b64, nil)
-			if err != nil {
-				log.Println(err)
-			} else {
-				log.Printf("Added emoji %s successfully!\n", emoji.Name)
-				emoji.ID = em.ID
-				guild.SpecialEmojis[em.Name] = emoji
+	}
+	if existing != nil && !ownEmojis {
+		emoji.ID = existing.ID
+		return emoji, nil
+	}
+
+	if existing != nil {
+		image, err := assets.EmojiImage(emoji.Name)
+		knownHash, known := guild.PersistentGuildData.GetEmojiHash(emoji.Name)
+		//emojis without an asset can't have changed, and ones uploaded before hashes were recorded are assumed current
+		if err != nil || !known || knownHash == assets.Hash(image) {
+			if err == nil && !known {
+				guild.PersistentGuildData.SetEmojiHash(emoji.Name, assets.Hash(image))
 			}
+			emoji.ID = existing.ID
+			return emoji, nil
+		}
+		guild.log().Infof("Image of emoji %s has changed; uploading it again", emoji.Name)
+		err = s.GuildEmojiDelete(guildID, existing.ID)
+		if err != nil {
+			//keep using the outdated emoji, rather than none
+			emoji.ID = existing.ID
+			return emoji, err
+		}
+	}
+
+	b64, hash, err := emojiImage(emoji)
+	if err != nil {
+		return emoji, err
+	}
+
+	em, err := s.GuildEmojiCreate(guildID, emoji.Name, b64, nil)
+	if err != nil {
+		return emoji, err
+	}
+	guild.log().Infof("Added emoji %s successfully!", emoji.Name)
+	emoji.ID = em.ID
+	guild.PersistentGuildData.SetEmojiHash(emoji.Name, hash)
+	return emoji, nil
+}
+
+func (guild *GuildState) addSpecialEmojis(s *discordgo.Session, guildID string, special map[string]Emoji, serverEmojis []*discordgo.Emoji, ownEmojis bool) {
+	for key, emoji := range GlobalSpecialEmojis {
+		em, err := guild.syncEmoji(s, guildID, emoji, serverEmojis, ownEmojis)
+		if err != nil {
+			guild.log().Error(err)
+		}
+		if em.ID != "" {
+			special[key] = em
 		}
 	}
 }
 
-func (guild *GuildState) addAllMissingEmojis(s *discordgo.Session, guildID string, alive bool, serverEmojis []*discordgo.Emoji) {
+func (guild *GuildState) addAllMissingEmojis(s *discordgo.Session, guildID string, status AlivenessEmojis, alive bool, serverEmojis []*discordgo.Emoji, ownEmojis bool) {
 	for i, emoji := range GlobalAlivenessEmojis[alive] {
-		alreadyExists := false
-		for _, v := range serverEmojis {
-			if v.Name == emoji.Name {
-				emoji.ID = v.ID
-				guild.StatusEmojis[alive][i] = emoji
-				alreadyExists = true
-				break
-			}
+		em, err := guild.syncEmoji(s, guildID, emoji, serverEmojis, ownEmojis)
+		if err != nil {
+			guild.log().Error(err)
 		}
-		if !alreadyExists {
-			b64 := emoji.DownloadAndBase64Encode()
-			em, err := s.GuildEmojiCreate(guildID, emoji.Name, b64, nil)
-			if err != nil {
-				log.Println(err)
-			} else {
-				log.Printf("Added emoji %s successfully!\n", emoji.Name)
-				emoji.ID = em.ID
-				guild.StatusEmojis[alive][i] = emoji
-			}
+		if em.ID != "" {
+			status[alive][i] = em
 		}
 	}
 }
 
+// syncAllEmojis adds the bot's emojis to the guild, or re-uploads the ones whose image changed, and remembers their IDs
+func (guild *GuildState) syncAllEmojis(s *discordgo.Session, guildID, emojiGuildID string) {
+	if emojiGuildID == "" {
+		emojiGuildID = guildID
+	}
+	allEmojis, err := s.GuildEmojis(emojiGuildID)
+	if err != nil {
+		guild.log().Error(err)
+		return
+	}
+	ownEmojis := emojiGuildID == guildID
+	//emojis that can't be synced stay as they were
+	status, special := AlivenessEmojis{}, map[string]Emoji{}
+	for alive, emojis := range guild.StatusEmojis() {
+		status[alive] = append([]Emoji{}, emojis...)
+	}
+	for k, v := range guild.SpecialEmojis() {
+		special[k] = v
+	}
+	guild.addAllMissingEmojis(s, guildID, status, true, allEmojis, ownEmojis)
+	guild.addAllMissingEmojis(s, guildID, status, false, allEmojis, ownEmojis)
+	guild.addSpecialEmojis(s, guildID, special, allEmojis, ownEmojis)
+	guild.setEmojis(status, special)
+
+	err = guild.PersistentGuildData.ToFile(ConfigFilename(guildID))
+	if err != nil {
+		guild.log().Error(err)
+	}
+}
+
+// removeAllEmojis deletes every emoji the bot added from the guild, and returns how many were deleted
+func (guild *GuildState) removeAllEmojis(s *discordgo.Session, guildID string) (int, error) {
+	names := map[string]bool{}
+	for _, v := range GlobalAlivenessEmojis {
+		for _, emoji := range v {
+			names[emoji.Name] = true
+		}
+	}
+	for _, emoji := range GlobalSpecialEmojis {
+		names[emoji.Name] = true
+	}
+
+	serverEmojis, err := s.GuildEmojis(guildID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, v := range serverEmojis {
+		if !names[v.Name] {
+			continue
+		}
+		err := s.GuildEmojiDelete(guildID, v.ID)
+		if err != nil {
+			guild.log().Error(err)
+			continue
+		}
+		guild.PersistentGuildData.DeleteEmojiHash(v.Name)
+		removed++
+	}
+	guild.setEmojis(emptyStatusEmojis(), emptySpecialEmojis())
+
+	err = guild.PersistentGuildData.ToFile(ConfigFilename(guildID))
+	if err != nil {
+		guild.log().Error(err)
+	}
+	return removed, nil
+}
+
+// emojiStatusResponse lists which of the bot's emojis are custom emojis in the guild, and which use their fallback
+func (guild *GuildState) emojiStatusResponse() string {
+	buf := bytes.NewBuffer([]byte{})
+	custom, total := 0, 0
+	line := func(emoji Emoji) {
+		total++
+		if emoji.IsCustom() {
+			custom++
+			buf.WriteString(fmt.Sprintf("✅ %s %s\n", emoji.FormatForInline(), emoji.Name))
+		} else {
+			buf.WriteString(fmt.Sprintf("⚠️ %s %s\n", emoji.Fallback, emoji.Name))
+		}
+	}
+	statusEmojis := guild.StatusEmojis()
+	for _, alive := range []bool{true, false} {
+		for _, v := range statusEmojis[alive] {
+			line(v)
+		}
+	}
+	specialEmojis := guild.SpecialEmojis()
+	keys := make([]string, 0, len(specialEmojis))
+	for k := range specialEmojis {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line(specialEmojis[k])
+	}
+	return guild.text("reply.emojisStatus", custom, total, guild.PersistentGuildData.CommandPrefix) + "\n" + buf.String()
+}
+
 // GlobalSpecialEmojis map
 var GlobalSpecialEmojis = map[string]Emoji{
 	"alarm": {
-		Name: "aualarm",
-		ID:   "756595863048159323",
+		Name:     "aualarm",
+		Fallback: "🚨",
 	},
 }
 
 // AlivenessEmojis map
 type AlivenessEmojis map[bool][]Emoji
 
-// GlobalAlivenessEmojis keys are IsAlive, Color
-var GlobalAlivenessEmojis = AlivenessEmojis{
-	true: []Emoji{
-		game.Red: {
-			Name: "aured",
-			ID:   "756202732301320325",
-		},
-		game.Blue: {
-			Name: "aublue",
-			ID:   "756201148154642642",
-		},
-		game.Green: {
-			Name: "augreen",
-			ID:   "756202732099993753",
-		},
-		game.Pink: {
-			Name: "aupink",
-			ID:   "756200620049956864",
-		},
-		game.Orange: {
-			Name: "auorange",
-			ID:   "756202732523618435",
-		},
-		game.Yellow: {
-			Name: "auyellow",
-			ID:   "756202732678938624",
-		},
-		game.Black: {
-			Name: "aublack",
-			ID:   "756202732758761522",
-		},
-		game.White: {
-			Name: "auwhite",
-			ID:   "756202732343394386",
-		},
-		game.Purple: {
-			Name: "aupurple",
-			ID:   "756202732624543770",
-		},
-		game.Brown: {
-			Name: "aubrown",
-			ID:   "756202732594921482",
-		},
-		game.Cyan: {
-			Name: "aucyan",
-			ID:   "756202732511297556",
-		},
-		game.Lime: {
-			Name: "aulime",
-			ID:   "756202732360040569",
-		},
-	},
-	false: []Emoji{
-		game.Red: {
-			Name: "aureddead",
-			ID:   "756404218163888200",
-		},
-		game.Blue: {
-			Name: "aubluedead",
-			ID:   "756552864309969057",
-		},
-		game.Green: {
-			Name: "augreendead",
-			ID:   "756552867275604008",
-		},
-		game.Pink: {
-			Name: "aupinkdead",
-			ID:   "756552867413753906",
-		},
-		game.Orange: {
-			Name: "auorangedead",
-			ID:   "756404218436517888",
-		},
-		game.Yellow: {
-			Name: "auyellowdead",
-			ID:   "756404218339786762",
-		},
-		game.Black: {
-			Name: "aublackdead",
-			ID:   "756552864171557035",
-		},
-		game.White: {
-			Name: "auwhitedead",
-			ID:   "756552867200106596",
-		},
-		game.Purple: {
-			Name: "aupurpledead",
-			ID:   "756552866491138159",
-		},
-		game.Brown: {
-			Name: "aubrowndead",
-			ID:   "756552864620347422",
-		},
-		game.Cyan: {
-			Name: "aucyandead",
-			ID:   "756204054698262559",
-		},
-		game.Lime: {
-			Name: "aulimedead",
-			ID:   "756552864847102042",
-		},
-	},
+// Get returns the emoji for a color, or a placeholder for colors the bot doesn't know about
+func (ae AlivenessEmojis) Get(alive bool, color int) Emoji {
+	if color < 0 || color >= len(ae[alive]) {
+		return Emoji{Name: "auunknown", Fallback: "❔"}
+	}
+	return ae[alive][color]
+}
+
+// GlobalAlivenessEmojis keys are IsAlive, Color. There's one emoji per color of the palette, uploaded from the assets
+var GlobalAlivenessEmojis = makeAlivenessEmojis()
+
+func makeAlivenessEmojis() AlivenessEmojis {
+	emojis := AlivenessEmojis{
+		true:  make([]Emoji, game.NumColors),
+		false: make([]Emoji, game.NumColors),
+	}
+	for _, v := range game.Palette {
+		emojis[true][v.ID] = Emoji{Name: "au" + v.Name, Fallback: v.Emoji}
+		emojis[false][v.ID] = Emoji{Name: "au" + v.Name + "dead", Fallback: "💀"}
+	}
+	return emojis
 }
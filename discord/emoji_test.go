@@ -0,0 +1,19 @@
+package discord
+
+import "testing"
+
+// Emojis are uploaded from the bundled assets, so every emoji the bot adds needs one
+func TestEveryEmojiHasAnAsset(t *testing.T) {
+	emojis := []Emoji{}
+	for _, v := range GlobalSpecialEmojis {
+		emojis = append(emojis, v)
+	}
+	for _, v := range GlobalAlivenessEmojis {
+		emojis = append(emojis, v...)
+	}
+	for _, v := range emojis {
+		if _, hash, err := emojiImage(v); err != nil || hash == "" {
+			t.Errorf("%s has no asset: %v", v.Name, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package discord
+
+import (
+	"time"
+
+	"github.com/denverquane/amongusdiscord/game"
+)
+
+// GuildEventType is the kind of thing that happened in a guild
+type GuildEventType string
+
+const (
+	GameStartEvent   GuildEventType = "gameStart"
+	GameEndEvent     GuildEventType = "gameEnd"
+	PhaseEvent       GuildEventType = "phase"
+	ForcedPhaseEvent GuildEventType = "forcedPhase"
+	LinkEvent        GuildEventType = "link"
+	UnlinkEvent      GuildEventType = "unlink"
+	PatchFailedEvent GuildEventType = "patchFailed"
+
+	PlayerJoinedEvent GuildEventType = "playerJoined"
+	PlayerLeftEvent   GuildEventType = "playerLeft"
+	PlayerDiedEvent   GuildEventType = "playerDied"
+	PlayerExiledEvent GuildEventType = "playerExiled"
+
+	//TestEvent is only sent to check a webhook works
+	TestEvent GuildEventType = "test"
+)
+
+// auditedEvents are the events posted to the audit channel; the rest are only sent to webhooks
+var auditedEvents = map[GuildEventType]bool{
+	GameStartEvent:   true,
+	GameEndEvent:     true,
+	PhaseEvent:       true,
+	ForcedPhaseEvent: true,
+	LinkEvent:        true,
+	UnlinkEvent:      true,
+	PatchFailedEvent: true,
+}
+
+// EventSource is where the action behind an event came from
+type EventSource string
+
+const (
+	SourceCommand  EventSource = "command"
+	SourceReaction EventSource = "reaction"
+	SourceCapture  EventSource = "capture"
+	SourceAdminAPI EventSource = "adminAPI"
+	SourceBot      EventSource = "bot"
+)
+
+// Trigger is who, or what, caused an event
+type Trigger struct {
+	Source EventSource `json:"source"`
+	//UserID is the discord user behind commands and reactions
+	UserID string `json:"userID,omitempty"`
+}
+
+func byCapture() Trigger {
+	return Trigger{Source: SourceCapture}
+}
+
+func byBot() Trigger {
+	return Trigger{Source: SourceBot}
+}
+
+func byCommand(userID string) Trigger {
+	return Trigger{Source: SourceCommand, UserID: userID}
+}
+
+func byReaction(userID string) Trigger {
+	return Trigger{Source: SourceReaction, UserID: userID}
+}
+
+func byAdminAPI() Trigger {
+	return Trigger{Source: SourceAdminAPI}
+}
+
+// GuildEvent is a record of something the bot did, or was told to do, in a guild
+type GuildEvent struct {
+	Type    GuildEventType `json:"type"`
+	GuildID string         `json:"guildID"`
+	GameID  string         `json:"gameID,omitempty"`
+	Time    time.Time      `json:"time"`
+	By      Trigger        `json:"by"`
+
+	//UserID is the discord user the event is about, like the one that was linked
+	UserID string `json:"userID,omitempty"`
+	//Player and Color are the in-game player the event is about, or a user was linked to
+	Player string `json:"player,omitempty"`
+	Color  string `json:"color,omitempty"`
+	//Phase and PreviousPhase are set for phase changes
+	Phase         string `json:"phase,omitempty"`
+	PreviousPhase string `json:"previousPhase,omitempty"`
+	//Detail is anything else worth knowing, like why a patch failed
+	Detail string `json:"detail,omitempty"`
+}
+
+func phaseEvent(gs *GameState, previous, phase game.Phase, by Trigger) GuildEvent {
+	eventType := PhaseEvent
+	if by.Source != SourceCapture {
+		eventType = ForcedPhaseEvent
+	}
+	return GuildEvent{
+		Type:          eventType,
+		GameID:        gs.ID,
+		By:            by,
+		Phase:         string(game.PhaseNames[phase]),
+		PreviousPhase: string(game.PhaseNames[previous]),
+	}
+}
+
+func linkEvent(gs *GameState, userID string, playerData *game.PlayerData, by Trigger) GuildEvent {
+	player := playerData.Snapshot()
+	return GuildEvent{
+		Type:   LinkEvent,
+		GameID: gs.ID,
+		By:     by,
+		UserID: userID,
+		Player: player.Name,
+		Color:  game.GetColorStringForInt(player.Color),
+	}
+}
+
+func playerEvent(gs *GameState, eventType GuildEventType, player game.Player) GuildEvent {
+	return GuildEvent{
+		Type:   eventType,
+		GameID: gs.ID,
+		By:     byCapture(),
+		Player: player.Name,
+		Color:  game.GetColorStringForInt(player.Color),
+	}
+}
+
+func unlinkEvent(gameID, userID string, by Trigger) GuildEvent {
+	return GuildEvent{
+		Type:   UnlinkEvent,
+		GameID: gameID,
+		By:     by,
+		UserID: userID,
+	}
+}
+
+//publish records an event that happened in the guild, and sends it to the guild's webhooks
+func (guild *GuildState) publish(event GuildEvent) {
+	event.GuildID = guild.PersistentGuildData.GuildID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if auditedEvents[event.Type] && guild.PersistentGuildData.GetAuditChannelID() != "" {
+		guild.AuditLog.Record(event)
+	}
+	guild.Webhooks.Dispatch(event)
+	guild.Overlay.Notify()
+}
@@ -1,13 +1,19 @@
 package discord
 
 import (
+	"bytes"
+	"encoding/json"
+
 	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/locale"
 	"sync"
 )
 
 type GameStateMessage struct {
 	message *discordgo.Message
-	lock    sync.RWMutex
+	//the last embed sent, to skip edits that wouldn't change anything
+	lastEmbed []byte
+	lock      sync.RWMutex
 }
 
 type PrivateStateMessage struct {
@@ -16,22 +22,105 @@ type PrivateStateMessage struct {
 	idUsernameMap map[string]string
 	printedUsers []string
 	privateChannelID string
+	gameID string
+	//userID is who the message asks to link, so reactions to it link them
+	userID string
+}
+
+// CreateMessage sends the message asking which player a user is
+func (psm *PrivateStateMessage) CreateMessage(s *discordgo.Session, userID string, me *discordgo.MessageEmbed, channelID string) *discordgo.Message  {
+	message := sendMessageEmbed(s, channelID, me)
+	psm.lock.Lock()
+	psm.message = message
+	psm.userID = userID
+	psm.lock.Unlock()
+	return message
+}
+
+// UserID returns who the message asks to link
+func (psm *PrivateStateMessage) UserID() string {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return psm.userID
+}
+
+func (psm *PrivateStateMessage) Message() *discordgo.Message {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return psm.message
+}
+
+func (psm *PrivateStateMessage) SetMessage(message *discordgo.Message) {
+	psm.lock.Lock()
+	psm.message = message
+	psm.lock.Unlock()
+}
+
+// MessageFor returns the message if it's for the game
+func (psm *PrivateStateMessage) MessageFor(gameID string) *discordgo.Message {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	if psm.gameID != gameID {
+		return nil
+	}
+	return psm.message
+}
+
+func (psm *PrivateStateMessage) GameID() string {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return psm.gameID
+}
+
+func (psm *PrivateStateMessage) SetGameID(gameID string) {
+	psm.lock.Lock()
+	psm.gameID = gameID
+	psm.lock.Unlock()
+}
+
+// Users returns a copy of the IDs and names of the users the messages are about
+func (psm *PrivateStateMessage) Users() map[string]string {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	users := make(map[string]string, len(psm.idUsernameMap))
+	for k, v := range psm.idUsernameMap {
+		users[k] = v
+	}
+	return users
 }
 
-func (psm PrivateStateMessage) CreateMessage(s *discordgo.Session, me *discordgo.MessageEmbed, channelID string) *discordgo.Message  {
+func (psm *PrivateStateMessage) SetUsers(users map[string]string) {
 	psm.lock.Lock()
-	psm.message = sendMessageEmbed(s, channelID, me)
+	psm.idUsernameMap = users
 	psm.lock.Unlock()
-	return psm.message;
 }
 
-func (psm PrivateStateMessage) Exists() bool {
+// Printed returns a copy of the IDs of the users that have had a message
+func (psm *PrivateStateMessage) Printed() []string {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return append([]string{}, psm.printedUsers...)
+}
+
+func (psm *PrivateStateMessage) MarkPrinted(userID string) {
+	psm.lock.Lock()
+	psm.printedUsers = append(psm.printedUsers, userID)
+	psm.lock.Unlock()
+}
+
+func (psm *PrivateStateMessage) ChannelID() string {
+	psm.lock.RLock()
+	defer psm.lock.RUnlock()
+	return psm.privateChannelID
+}
+
+func (psm *PrivateStateMessage) Exists() bool {
 	psm.lock.RLock()
 	defer psm.lock.RUnlock()
 	return psm.message != nil
 }
 
-func (psm PrivateStateMessage) IsReactionTo(m *discordgo.MessageReactionAdd) bool {
+func (psm *PrivateStateMessage) IsReactionTo(m *discordgo.MessageReactionAdd) bool {
 	psm.lock.RLock()
 	defer psm.lock.RUnlock()
 	if psm.message == nil {
@@ -49,17 +138,17 @@ func (psm *PrivateStateMessage) AddReaction(s *discordgo.Session, emoji string)
 	psm.lock.Unlock()
 }
 
-func (psm *PrivateStateMessage) privateMapResponse(uID string, uName string) *discordgo.MessageEmbed {
+func (psm *PrivateStateMessage) privateMapResponse(lang string, uID string, uName string) *discordgo.MessageEmbed {
 
 
 	gameInfoFields := make([]*discordgo.MessageEmbedField, 2)
 	gameInfoFields[0] = &discordgo.MessageEmbedField{
-		Name:   "User ID",
+		Name:   locale.Get(lang, "private.userID"),
 		Value:  uID,
 		Inline: false,
 	}
 	gameInfoFields[1] = &discordgo.MessageEmbedField{
-		Name: "Username",
+		Name: locale.Get(lang, "private.username"),
 		Value: uName,
 		Inline: false,
 	}
@@ -71,11 +160,11 @@ func (psm *PrivateStateMessage) privateMapResponse(uID string, uName string) *di
 	msg := discordgo.MessageEmbed{
 		URL:         "",
 		Type:        "",
-		Title:       "What color is " + uName + "?",
+		Title:       locale.Get(lang, "private.title", uName),
 		Description: "",
 		Timestamp:   "",
 		Footer: &discordgo.MessageEmbedFooter{
-			Text:         "React to this message with " + uName + "'s color! (or ❌ to skip)",
+			Text:         locale.Get(lang, "private.footer", uName),
 			IconURL:      "",
 			ProxyIconURL: "",
 		},
@@ -97,8 +186,8 @@ func MakeGameStateMessage() GameStateMessage {
 	}
 }
 
-func MakePrivateStateMessage() PrivateStateMessage {
-	return PrivateStateMessage{
+func MakePrivateStateMessage() *PrivateStateMessage {
+	return &PrivateStateMessage{
 		message: nil,
 		lock:    sync.RWMutex{},
 		idUsernameMap: make(map[string]string),
@@ -131,10 +220,18 @@ func (gsm *GameStateMessage) Delete(s *discordgo.Session) {
 	gsm.lock.Unlock()
 }
 
+// Edit changes the embed of the message, unless it's the same as what the message already shows
 func (gsm *GameStateMessage) Edit(s *discordgo.Session, me *discordgo.MessageEmbed) {
 	gsm.lock.Lock()
 	if gsm.message != nil {
-		editMessageEmbed(s, gsm.message.ChannelID, gsm.message.ID, me)
+		rendered, err := json.Marshal(me)
+		if err != nil || !bytes.Equal(rendered, gsm.lastEmbed) {
+			editMessageEmbed(s, gsm.message.ChannelID, gsm.message.ID, me)
+			gsm.lastEmbed = rendered
+			statusEdits.Inc("sent")
+		} else {
+			statusEdits.Inc("skipped")
+		}
 	}
 	gsm.lock.Unlock()
 }
@@ -142,6 +239,7 @@ func (gsm *GameStateMessage) Edit(s *discordgo.Session, me *discordgo.MessageEmb
 func (gsm *GameStateMessage) CreateMessage(s *discordgo.Session, me *discordgo.MessageEmbed, channelID string) {
 	gsm.lock.Lock()
 	gsm.message = sendMessageEmbed(s, channelID, me)
+	gsm.lastEmbed, _ = json.Marshal(me)
 	gsm.lock.Unlock()
 }
 
@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+)
+
+//reactWith reacts to the private message with the emoji, as the owner
+func reactWith(s *discordgo.Session, guild *GuildState, e Emoji) {
+	reaction := discordgo.Emoji{Name: e.Fallback}
+	if e.IsCustom() {
+		reaction = discordgo.Emoji{ID: e.ID, Name: e.Name}
+	}
+	message := guild.PrivateStateMsg.Message()
+	guild.handleReactionPrivateUserMessage(s, &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    "owner",
+		MessageID: message.ID,
+		ChannelID: message.ChannelID,
+		GuildID:   guild.PersistentGuildData.GuildID,
+		Emoji:     reaction,
+	}})
+}
+
+// The private message's fields are translated, so linking can't depend on what they say
+func TestPrivateMessageLinksInAnyLanguage(t *testing.T) {
+	const guildID = "private"
+	s, guild := startTestGuild(t, guildID, 2)
+	command(s, guildID, ".au language es")
+	if lang := guild.PersistentGuildData.GetLanguage(); lang != "es" {
+		t.Fatalf("the guild's language is %s, want es", lang)
+	}
+	for _, userID := range []string{"user0", "user1"} {
+		s.State.OnInterface(s, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{GuildID: guildID, UserID: userID, ChannelID: voiceChannel}})
+	}
+
+	command(s, guildID, ".au new")
+	gs := guild.Games.Get("1")
+	if gs == nil || !guild.PrivateStateMsg.Exists() {
+		t.Fatal("the game or its private message wasn't made")
+	}
+	handleCaptureMessage(s, CaptureMessage{CaptureID: "capture", Event: CaptureConnect, Data: gs.GetLinkCode()})
+	for _, v := range []game.Player{{Action: game.JOINED, Name: "PlayerZed", Color: game.Red}, {Action: game.JOINED, Name: "PlayerAnn", Color: game.Blue}} {
+		handleCaptureMessage(s, playerMessage("capture", v))
+	}
+	waitFor(t, "the players to join", func() bool { return gs.AmongUsData.NumDetectedPlayers() == 2 })
+
+	//each reaction links the user the message was about, and the next message is about the other user
+	linked := map[string]int{}
+	for _, color := range []int{game.Red, game.Blue} {
+		userID := guild.PrivateStateMsg.UserID()
+		if _, ok := linked[userID]; ok || userID == "" {
+			t.Fatalf("the private message is about %q after %v were linked", userID, linked)
+		}
+		reactWith(s, guild, guild.StatusEmojis()[true][color])
+		linked[userID] = color
+	}
+	for userID, color := range linked {
+		user, err := guild.UserData.GetUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.IsLinked() || user.GetColor() != color {
+			t.Errorf("%s is linked to %s, want %s", userID, game.GetColorStringForInt(user.GetColor()), game.GetColorStringForInt(color))
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package discord
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+)
+
+// GameKey identifies a single game in a single guild
+type GameKey struct {
+	GuildID string
+	GameID  string
+}
+
+// GameState is one of the (possibly many) independent games running in a guild. Each game has its own capture,
+// tracked voice channels, status message and voice rules
+type GameState struct {
+	ID string
+
+	LinkCode string
+
+	Tracking Tracking
+
+	GameStateMsg GameStateMessage
+
+	AmongUsData game.AmongUsData
+
+	//VoiceRules and VoicePreset are changed by commands while the game is running, so they're read and written
+	//with rulesLock held
+	VoiceRules VoiceRules
+	//VoicePreset is the name of the preset VoiceRules came from, or "" for the server's default rules
+	VoicePreset string
+	rulesLock   sync.RWMutex
+
+	//when the next voice changes are going to be applied
+	Countdown VoiceCountdown
+
+	//the order players died in, since the game left the lobby
+	Deaths DeathLog
+
+	transitionsInProgress int32
+
+	//the voice changes for the current phase, while they're being applied
+	transition     *transition
+	transitionLock sync.Mutex
+}
+
+func MakeGameState(id string, rules VoiceRules) *GameState {
+	return &GameState{
+		ID:           id,
+		LinkCode:     "",
+		Tracking:     MakeTracking(),
+		GameStateMsg: MakeGameStateMessage(),
+		AmongUsData:  game.NewAmongUsData(),
+		VoiceRules:   rules,
+	}
+}
+
+// GetLinkCode returns the code a capture can link to the game with, or "" if one is linked. LinkCode is only written
+// with LinkCodeLock held
+func (gs *GameState) GetLinkCode() string {
+	LinkCodeLock.RLock()
+	defer LinkCodeLock.RUnlock()
+	return gs.LinkCode
+}
+
+// GetVoiceRules returns the rules the game's members are muted and deafened by
+func (gs *GameState) GetVoiceRules() VoiceRules {
+	gs.rulesLock.RLock()
+	defer gs.rulesLock.RUnlock()
+	return gs.VoiceRules
+}
+
+// SetVoiceRules changes the game's rules, and the name of the preset they came from ("" for the server's default)
+func (gs *GameState) SetVoiceRules(rules VoiceRules, preset string) {
+	gs.rulesLock.Lock()
+	gs.VoiceRules = rules
+	gs.VoicePreset = preset
+	gs.rulesLock.Unlock()
+}
+
+// GetVoicePresetName returns the name of the preset the game's rules came from
+func (gs *GameState) GetVoicePresetName() string {
+	gs.rulesLock.RLock()
+	defer gs.rulesLock.RUnlock()
+	if gs.VoicePreset == "" {
+		return DefaultPreset
+	}
+	return gs.VoicePreset
+}
+
+// GameSet holds every game running in a guild, keyed by game ID
+type GameSet struct {
+	games  map[string]*GameState
+	lastID int
+	lock   sync.RWMutex
+}
+
+func MakeGameSet() GameSet {
+	return GameSet{
+		games: map[string]*GameState{},
+		lock:  sync.RWMutex{},
+	}
+}
+
+// Get returns nil if there's no game with that ID
+func (gs *GameSet) Get(gameID string) *GameState {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.games[gameID]
+}
+
+// Create makes a new game with the next game ID. IDs are never reused, so a message or capture still referring to
+// a game that ended can't end up pointing at a newer one
+func (gs *GameSet) Create(rules VoiceRules) *GameState {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	gs.lastID++
+	newGame := MakeGameState(strconv.Itoa(gs.lastID), rules)
+	gs.games[newGame.ID] = newGame
+	return newGame
+}
+
+func (gs *GameSet) Remove(gameID string) {
+	gs.lock.Lock()
+	delete(gs.games, gameID)
+	gs.lock.Unlock()
+}
+
+func (gs *GameSet) Size() int {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return len(gs.games)
+}
+
+// All returns every game, ordered by ID
+func (gs *GameSet) All() []*GameState {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+
+	all := make([]*GameState, 0, len(gs.games))
+	for _, v := range gs.games {
+		all = append(all, v)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, _ := strconv.Atoi(all[i].ID)
+		b, _ := strconv.Atoi(all[j].ID)
+		return a < b
+	})
+	return all
+}
+
+// FindByReaction returns the game whose status message was reacted to, or nil
+func (gs *GameSet) FindByReaction(m *discordgo.MessageReactionAdd) *GameState {
+	for _, v := range gs.All() {
+		if v.GameStateMsg.IsReactionTo(m) {
+			return v
+		}
+	}
+	return nil
+}
+
+// FindByTrackedChannel returns the game explicitly tracking a voice channel, or nil
+func (gs *GameSet) FindByTrackedChannel(channelID string) *GameState {
+	if channelID == "" {
+		return nil
+	}
+	for _, v := range gs.All() {
+		if v.Tracking.IsExplicitlyTracked(channelID) {
+			return v
+		}
+	}
+	return nil
+}
+
+func isGameID(arg string) bool {
+	_, err := strconv.Atoi(arg)
+	return err == nil
+}
+
+//voiceStates returns a copy of the guild's voice states. The session's state replaces them as voice events come in,
+//so they're only read with its lock held
+func voiceStates(s *discordgo.Session, g *discordgo.Guild) []*discordgo.VoiceState {
+	if g == nil {
+		return nil
+	}
+	s.State.RLock()
+	defer s.State.RUnlock()
+	return append([]*discordgo.VoiceState{}, g.VoiceStates...)
+}
+
+//getVoiceChannel returns the voice channel a user is in, or "" if they aren't in voice
+func getVoiceChannel(s *discordgo.Session, g *discordgo.Guild, userID string) string {
+	for _, v := range voiceStates(s, g) {
+		if v.UserID == userID {
+			return v.ChannelID
+		}
+	}
+	return ""
+}
+
+//resolveGame figures out which game a command is for. An explicit game ID always wins; otherwise we use the game
+//tracking the voice channel the author is in, then the game the author is linked in, then the only game running
+func (guild *GuildState) resolveGame(s *discordgo.Session, g *discordgo.Guild, authorID, explicitID string) (*GameState, error) {
+	if explicitID != "" {
+		if gs := guild.Games.Get(explicitID); gs != nil {
+			return gs, nil
+		}
+		return nil, locale.Errorf("error.noGameID", explicitID)
+	}
+	if gs := guild.Games.FindByTrackedChannel(getVoiceChannel(s, g, authorID)); gs != nil {
+		return gs, nil
+	}
+	if userData, err := guild.UserData.GetUser(authorID); err == nil && userData.IsLinked() {
+		if gs := guild.Games.Get(userData.GetGameID()); gs != nil {
+			return gs, nil
+		}
+	}
+	all := guild.Games.All()
+	switch len(all) {
+	case 0:
+		return nil, locale.Errorf("error.noGame")
+	case 1:
+		return all[0], nil
+	default:
+		return nil, locale.Errorf("error.manyGames", len(all), guild.PersistentGuildData.CommandPrefix, all[0].ID)
+	}
+}
+
+//gameForCommand resolves the game a command is for, and lets the channel know when it can't
+func (guild *GuildState) gameForCommand(s *discordgo.Session, g *discordgo.Guild, m *discordgo.MessageCreate, explicitID string) *GameState {
+	gs, err := guild.resolveGame(s, g, m.Author.ID, explicitID)
+	if err != nil {
+		s.ChannelMessageSend(m.ChannelID, guild.text("reply.whichGame", guild.errorText(err)))
+		return nil
+	}
+	return gs
+}
+
+//gameToStart picks the game that `new` should restart, or nil if it should start a new game instead. Only a game
+//that's explicitly named, or that's tracking the author's voice channel, is restarted; if the author isn't in voice
+//and there's only one game, that game is restarted like it was before multiple games were supported
+func (guild *GuildState) gameToStart(s *discordgo.Session, g *discordgo.Guild, authorID, explicitID string) (*GameState, error) {
+	if explicitID != "" {
+		return guild.resolveGame(s, g, authorID, explicitID)
+	}
+	channelID := getVoiceChannel(s, g, authorID)
+	if gs := guild.Games.FindByTrackedChannel(channelID); gs != nil {
+		return gs, nil
+	}
+	if all := guild.Games.All(); channelID == "" && len(all) == 1 {
+		return all[0], nil
+	}
+	return nil, nil
+}
+
+func (guild *GuildState) gamesResponse() string {
+	all := guild.Games.All()
+	if len(all) == 0 {
+		return guild.text("reply.noGames")
+	}
+	lang := guild.PersistentGuildData.GetLanguage()
+	buf := bytes.NewBuffer([]byte{})
+	for _, gs := range all {
+		phase := gs.AmongUsData.GetPhase()
+		room, region := gs.AmongUsData.GetRoomRegion()
+		capture := guild.text("reply.captureLinked")
+		if code := gs.GetLinkCode(); code != "" {
+			capture = guild.text("reply.captureNotLinked", code)
+		}
+		buf.WriteString(guild.text("reply.game", gs.ID, locale.PhaseName(lang, phase), room, region, gs.Tracking.ToStatusString(lang), capture, guild.UserData.GetCountLinked(gs.ID)) + "\n")
+	}
+	return buf.String()
+}
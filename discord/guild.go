@@ -1,12 +1,14 @@
 package discord
 
 import (
-	"container/heap"
+	"context"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"github.com/denverquane/amongusdiscord/game"
-	"log"
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -46,18 +48,46 @@ func (gd *GameDelays) GetDelay(origin, dest game.Phase) int {
 type GuildState struct {
 	PersistentGuildData *PersistentGuildData
 
-	LinkCode string
+	//discord users in the guild, and which game/player they're linked to
+	UserData *UserDataSet
 
-	UserData UserDataSet
-	Tracking Tracking
+	//every game running in the guild
+	Games GameSet
 
-	GameStateMsg GameStateMessage
-	PrivateStateMsg PrivateStateMessage
+	PrivateStateMsg *PrivateStateMessage
 
-	StatusEmojis  AlivenessEmojis
-	SpecialEmojis map[string]Emoji
+	//the emojis are replaced as a whole when they're synced or removed, and never changed in place, so readers can
+	//keep using the maps they got from StatusEmojis and SpecialEmojis
+	statusEmojis  AlivenessEmojis
+	specialEmojis map[string]Emoji
+	emojiLock     sync.RWMutex
+	//guild the emojis are looked up in, if not this one
+	EmojiGuildID string
+
+	//every member the bot has muted/deafened/renamed, so they can be restored on shutdown or after a crash
+	ModifiedMembers *ModifiedMembers
+
+	//how far discord's voice state has drifted from what we wanted
+	Drift DriftStats
+
+	//status message edits waiting to be sent
+	StatusEdits *StatusEdits
+
+	//events waiting to be posted to the audit channel
+	AuditLog *AuditLog
 
-	AmongUsData game.AmongUsData
+	//sends events to the guild's webhooks
+	Webhooks *Webhooks
+
+	//stream overlays watching the guild's games
+	Overlay *OverlayStreams
+
+	//the last dry run reported for each member
+	DryRunReports *DryRunReports
+
+	//cancelled when the guild is torn down, which stops everything running for it
+	ctx  context.Context
+	stop context.CancelFunc
 }
 
 type EmojiCollection struct {
@@ -78,65 +108,36 @@ func (guild *GuildState) checkCacheAndAddUser(g *discordgo.Guild, s *discordgo.S
 	//check and see if they're cached first
 	for _, v := range g.Members {
 		if v.User.ID == userID {
-			user := game.MakeUserDataFromDiscordUser(v.User, v.Nick)
-			guild.UserData.AddFullUser(user)
-			return user, true
+			return guild.UserData.AddUser(game.MakeUserDataFromDiscordUser(v.User, v.Nick)), true
 		}
 	}
 	mem, err := s.GuildMember(guild.PersistentGuildData.GuildID, userID)
 	if err != nil {
-		log.Println(err)
+		guild.log().With(logger.Fields{logger.UserKey: userID}).Warn(err)
 		return game.UserData{}, false
 	}
-	user := game.MakeUserDataFromDiscordUser(mem.User, mem.Nick)
-	guild.UserData.AddFullUser(user)
-	return user, true
+	return guild.UserData.AddUser(game.MakeUserDataFromDiscordUser(mem.User, mem.Nick)), true
 }
 
-type HandlePriority int
-
-const (
-	NoPriority    HandlePriority = 0
-	AlivePriority HandlePriority = 1
-	DeadPriority  HandlePriority = 2
-)
-
-type PrioritizedPatchParams struct {
-	priority    int
-	patchParams UserPatchParameters
-}
-
-type PatchPriority []PrioritizedPatchParams
-
-func (h PatchPriority) Len() int { return len(h) }
-
-//NOTE this is inversed so HIGHER numbers are pulled FIRST
-func (h PatchPriority) Less(i, j int) bool { return h[i].priority > h[j].priority }
-func (h PatchPriority) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-func (h *PatchPriority) Push(x interface{}) {
-	// Push and Pop use pointer receivers because they modify the slice's length,
-	// not just its contents.
-	*h = append(*h, x.(PrioritizedPatchParams))
-}
-
-func (h *PatchPriority) Pop() interface{} {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
-}
-
-//handleTrackedMembers moves/mutes players according to the current game state
-func (guild *GuildState) handleTrackedMembers(dg *discordgo.Session, delay int, handlePriority HandlePriority) bool {
+//handleTrackedMembers moves/mutes players according to the current state of a game, applying the changes in the order
+//given by the steps. Nothing more is applied once ctx is cancelled
+func (guild *GuildState) handleTrackedMembers(ctx context.Context, dg *discordgo.Session, gs *GameState, delay int, steps []PriorityStep) bool {
+	//let the reconciler know not to "fix" users we're deliberately delaying
+	atomic.AddInt32(&gs.transitionsInProgress, 1)
+	defer atomic.AddInt32(&gs.transitionsInProgress, -1)
 
 	g := guild.verifyVoiceStateChanges(dg)
 
 	updateMade := false
-	priorityQueue := &PatchPriority{}
-	heap.Init(priorityQueue)
+	if len(steps) == 0 {
+		steps = []PriorityStep{{Group: AnyGroup}}
+	}
+	groups := make([][]UserPatchParameters, len(steps))
 
-	for _, voiceState := range g.VoiceStates {
+	dryRun := guild.PersistentGuildData.IsDryRun()
+	dryRunDiffs := make([]MemberStateDiff, 0)
+
+	for _, voiceState := range voiceStates(dg, g) {
 
 		userData, err := guild.UserData.GetUser(voiceState.UserID)
 		if err != nil {
@@ -147,97 +148,164 @@ func (guild *GuildState) handleTrackedMembers(dg *discordgo.Session, delay int,
 				continue
 			}
 		}
+		//users playing in another game are that game's responsibility
+		if userData.IsLinked() && userData.GetGameID() != gs.ID {
+			continue
+		}
 
-		tracked := guild.Tracking.IsTracked(voiceState.ChannelID)
-		//only actually tracked if we're in a tracked channel AND linked to a player
-		tracked = tracked && userData.IsLinked()
-		shouldMute, shouldDeaf := guild.PersistentGuildData.VoiceRules.GetVoiceState(userData.IsAlive(), tracked, guild.AmongUsData.GetPhase())
+		shouldMute, shouldDeaf := guild.desiredVoiceState(userData, voiceState.ChannelID)
 
-		nick := userData.GetPlayerName()
-		if !guild.PersistentGuildData.ApplyNicknames {
-			nick = ""
-		}
+		nick := guild.nicknameFor(dg, userData)
 
 		//only issue a change if the user isn't in the right state already
-		//nicksmatch can only be false if the in-game data is != nil, so the reference to .audata below is safe
+		//nick can only be non-empty if the user is linked to in-game data
 		//check the userdata is linked here to not accidentally undeafen music bots, for example
-		if userData.IsLinked() && shouldMute != voiceState.Mute || shouldDeaf != voiceState.Deaf || (nick != "" && userData.GetNickName() != userData.GetPlayerName()) {
+		if userData.IsLinked() && (shouldMute != voiceState.Mute || shouldDeaf != voiceState.Deaf || (nick != "" && userData.GetNickName() != nick)) {
+
+			if dryRun {
+				dryRunDiffs = append(dryRunDiffs, MemberStateDiff{
+					Params:      UserPatchParameters{guild.PersistentGuildData.GuildID, voiceState.UserID, shouldDeaf, shouldMute, nick},
+					CurrentMute: voiceState.Mute,
+					CurrentDeaf: voiceState.Deaf,
+					CurrentNick: userData.GetNickName(),
+				})
+				continue
+			}
 
 			//only issue the req to discord if we're not waiting on another one
 			if !userData.IsPendingVoiceUpdate() {
-				//wait until it goes through
-				userData.SetPendingVoiceUpdate(true)
-
-				guild.UserData.UpdateUserData(voiceState.UserID, userData)
-				priority := 0
+				params := UserPatchParameters{guild.PersistentGuildData.GuildID, voiceState.UserID, shouldDeaf, shouldMute, nick}
 
-				if handlePriority != NoPriority {
-					if handlePriority == AlivePriority && userData.IsAlive() {
-						priority++
-					} else if handlePriority == DeadPriority && !userData.IsAlive() {
-						priority++
+				//put the user in the first step of the transition they belong to
+				for i, step := range steps {
+					if step.Matches(userData.IsAlive(), shouldMute || shouldDeaf) {
+						groups[i] = append(groups[i], params)
+						break
 					}
 				}
 
-				params := UserPatchParameters{guild.PersistentGuildData.GuildID, voiceState.UserID, shouldDeaf, shouldMute, nick}
-
-				heap.Push(priorityQueue, PrioritizedPatchParams{
-					priority:    priority,
-					patchParams: params,
-				})
-
 				updateMade = true
 			}
 
 		} else {
 			if shouldMute {
-				log.Printf("Not muting %s because they're already muted\n", userData.GetUserName())
+				guild.log().With(logger.Fields{logger.UserKey: userData.GetID()}).Debugf("Not muting %s because they're already muted", userData.GetUserName())
 			} else {
-				log.Printf("Not unmuting %s because they're already unmuted\n", userData.GetUserName())
+				guild.log().With(logger.Fields{logger.UserKey: userData.GetID()}).Debugf("Not unmuting %s because they're already unmuted", userData.GetUserName())
 			}
 		}
 	}
-	wg := sync.WaitGroup{}
-	waitForHigherPriority := false
+	if dryRun {
+		phase := gs.AmongUsData.GetPhase()
+		guild.reportDryRun(dg, guild.text("dryRun.phase", gs.ID, locale.PhaseName(guild.PersistentGuildData.GetLanguage(), phase), delay), dryRunDiffs)
+		return false
+	}
+	if !updateMade {
+		return false
+	}
 
 	if delay > 0 {
-		log.Printf("Sleeping for %d seconds before applying changes to users\n", delay)
-		time.Sleep(time.Second * time.Duration(delay))
+		guild.gameLog(gs).Debugf("Sleeping for %d seconds before applying changes to users", delay)
+		if !guild.waitWithCountdown(ctx, dg, gs, guild.text("countdown.applying"), time.Second*time.Duration(delay)) {
+			guild.gameLog(gs).Debug("A newer phase arrived; not applying the changes")
+			return false
+		}
 	}
 
-	for priorityQueue.Len() > 0 {
-		p := heap.Pop(priorityQueue).(PrioritizedPatchParams)
-
-		if p.priority > 0 {
-			waitForHigherPriority = true
-			log.Printf("User %s has higher priority: %d\n", p.patchParams.UserID, p.priority)
-		} else if waitForHigherPriority {
-			//wait for all the other users to get muted/unmuted completely, first
-			log.Println("Waiting for high priority user changes first")
-			wg.Wait()
-			waitForHigherPriority = false
+	for i, step := range steps {
+		if len(groups[i]) == 0 {
+			continue
+		}
+		if step.DelayMs > 0 {
+			if !guild.waitWithCountdown(ctx, dg, gs, guild.text("countdown.updating", step.Group), time.Millisecond*time.Duration(step.DelayMs)) {
+				guild.gameLog(gs).Debug("A newer phase arrived; not applying the rest of the changes")
+				return true
+			}
 		}
+		guild.gameLog(gs).Debugf("Applying changes to %d %s user(s)", len(groups[i]), step.Group)
 
-		wg.Add(1)
-		go muteWorker(dg, &wg, p.patchParams)
+		//wait for all the users in this group to get muted/unmuted completely before moving on to the next
+		wg := sync.WaitGroup{}
+		for j, params := range groups[i] {
+			if j > 0 && step.StaggerMs > 0 && !sleepContext(ctx, time.Millisecond*time.Duration(step.StaggerMs)) {
+				break
+			}
+			//wait until it goes through; anyone who got an update of their own while we waited is left to it
+			if !guild.UserData.StartVoiceUpdate(params.UserID) {
+				continue
+			}
+			wg.Add(1)
+			go guild.muteWorker(dg, &wg, params)
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return true
+		}
 	}
-	wg.Wait()
 
 	return updateMade
 }
 
-func muteWorker(s *discordgo.Session, wg *sync.WaitGroup, parameters UserPatchParameters) {
-	guildMemberUpdate(s, parameters)
+//waitWithCountdown sleeps for the duration, showing a countdown in the game's status message while it does. It's
+//false if ctx was cancelled first
+func (guild *GuildState) waitWithCountdown(ctx context.Context, s *discordgo.Session, gs *GameState, label string, duration time.Duration) bool {
+	gs.Countdown.Set(label, duration)
+	defer func() {
+		gs.Countdown.Clear()
+		guild.requestStatusEdit(gs)
+	}()
+
+	deadline := time.Now().Add(duration)
+	for remaining := duration; remaining > 0; remaining = time.Until(deadline) {
+		guild.requestStatusEdit(gs)
+		if remaining > time.Second {
+			remaining = time.Second
+		}
+		if !sleepContext(ctx, remaining) {
+			return false
+		}
+	}
+	return true
+}
+
+//sleepContext sleeps for the duration, and is false if ctx was cancelled first
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+//desiredVoiceState returns if a user in a voice channel should be muted and deafened, given the state of the game
+//they're linked in
+func (guild *GuildState) desiredVoiceState(userData game.UserData, channelID string) (bool, bool) {
+	gs := guild.Games.Get(userData.GetGameID())
+	if gs == nil {
+		return false, false
+	}
+	tracked := gs.Tracking.IsTracked(channelID)
+	//only actually tracked if we're in a tracked channel AND linked to a player
+	tracked = tracked && userData.IsLinked()
+	rules := guild.voiceRulesFor(gs, channelID)
+	return rules.GetVoiceState(userData.IsAlive(), tracked, gs.AmongUsData.GetPhase())
+}
+
+func (guild *GuildState) muteWorker(s *discordgo.Session, wg *sync.WaitGroup, parameters UserPatchParameters) {
+	guild.memberUpdate(s, parameters)
 	wg.Done()
 }
 
 func (guild *GuildState) verifyVoiceStateChanges(s *discordgo.Session) *discordgo.Guild {
 	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
 	if err != nil {
-		log.Println(err)
+		guild.log().Warn(err)
 	}
 
-	for _, voiceState := range g.VoiceStates {
+	for _, voiceState := range voiceStates(s, g) {
 		userData, err := guild.UserData.GetUser(voiceState.UserID)
 
 		if err != nil {
@@ -249,14 +317,9 @@ func (guild *GuildState) verifyVoiceStateChanges(s *discordgo.Session) *discordg
 			}
 		}
 
-		tracked := guild.Tracking.IsTracked(voiceState.ChannelID)
-		//only actually tracked if we're in a tracked channel AND linked to a player
-		tracked = tracked && userData.IsLinked()
-		mute, deaf := guild.PersistentGuildData.VoiceRules.GetVoiceState(userData.IsAlive(), tracked, guild.AmongUsData.GetPhase())
+		mute, deaf := guild.desiredVoiceState(userData, voiceState.ChannelID)
 		if userData.IsPendingVoiceUpdate() && voiceState.Mute == mute && voiceState.Deaf == deaf {
-			userData.SetPendingVoiceUpdate(false)
-
-			guild.UserData.UpdateUserData(voiceState.UserID, userData)
+			guild.UserData.SetPendingVoiceUpdate(voiceState.UserID, false)
 
 			//log.Println("Successfully updated pendingVoice")
 		}
@@ -270,6 +333,9 @@ func (guild *GuildState) verifyVoiceStateChanges(s *discordgo.Session) *discordg
 //relevant discord api requests are fully applied successfully. Otherwise, we can issue multiple requests for
 //the same mute/unmute, erroneously
 func (guild *GuildState) voiceStateChange(s *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+	//overlays show who is muted
+	defer guild.Overlay.Notify()
+
 	g := guild.verifyVoiceStateChanges(s)
 
 	updateMade := false
@@ -280,73 +346,101 @@ func (guild *GuildState) voiceStateChange(s *discordgo.Session, m *discordgo.Voi
 		//the user doesn't exist in our userdata cache; add them
 		userData, _ = guild.checkCacheAndAddUser(g, s, m.UserID)
 	}
-	tracked := guild.Tracking.IsTracked(m.ChannelID)
-	//only actually tracked if we're in a tracked channel AND linked to a player
-	tracked = tracked && userData.IsLinked()
-	mute, deaf := guild.PersistentGuildData.VoiceRules.GetVoiceState(userData.IsAlive(), tracked, guild.AmongUsData.GetPhase())
+	//members we left muted (from a crash, or from being out of voice on shutdown) get restored once they're back in voice
+	if !userData.IsLinked() && m.ChannelID != "" {
+		if member, ok := guild.ModifiedMembers.Get(m.UserID); ok && !guild.PersistentGuildData.IsDryRun() {
+			go guild.restoreMember(s, member, true)
+			return
+		}
+	}
+
+	mute, deaf := guild.desiredVoiceState(userData, m.ChannelID)
 	//check the userdata is linked here to not accidentally undeafen music bots, for example
 	if userData.IsLinked() && !userData.IsPendingVoiceUpdate() && (mute != m.Mute || deaf != m.Deaf) {
-		userData.SetPendingVoiceUpdate(true)
-
-		guild.UserData.UpdateUserData(m.UserID, userData)
+		nick := guild.nicknameFor(s, userData)
+
+		if guild.PersistentGuildData.IsDryRun() {
+			diff := MemberStateDiff{
+				Params:      UserPatchParameters{m.GuildID, m.UserID, deaf, mute, nick},
+				CurrentMute: m.Mute,
+				CurrentDeaf: m.Deaf,
+				CurrentNick: userData.GetNickName(),
+			}
+			//a drifted member sends plenty of voice events; they're only reported again when the drift changes
+			if guild.DryRunReports.Changed(diff) {
+				guild.reportDryRun(s, guild.text("dryRun.voiceState"), []MemberStateDiff{diff})
+			}
+			return
+		}
 
-		nick := userData.GetPlayerName()
-		if !guild.PersistentGuildData.ApplyNicknames {
-			nick = ""
+		//another handler may have started an update since we looked
+		if !guild.UserData.StartVoiceUpdate(m.UserID) {
+			return
 		}
 
-		go guildMemberUpdate(s, UserPatchParameters{m.GuildID, m.UserID, deaf, mute, nick})
+		go guild.memberUpdate(s, UserPatchParameters{m.GuildID, m.UserID, deaf, mute, nick})
 
-		log.Println("Applied deaf/undeaf mute/unmute via voiceStateChange")
+		guild.log().With(logger.Fields{logger.UserKey: m.UserID}).Debug("Applied deaf/undeaf mute/unmute via voiceStateChange")
 
 		updateMade = true
+	} else {
+		guild.DryRunReports.Forget(m.UserID)
 	}
 
 	if updateMade {
-		log.Println("Updating state message")
-		guild.GameStateMsg.Edit(s, gameStateResponse(guild))
+		if gs := guild.Games.Get(userData.GetGameID()); gs != nil {
+			guild.gameLog(gs).Debug("Updating state message")
+			guild.requestStatusEdit(gs)
+		}
 	}
 }
 
-func (guild *GuildState) handleReactionGameStartAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+func (guild *GuildState) handleReactionGameStartAdd(s *discordgo.Session, gs *GameState, m *discordgo.MessageReactionAdd) {
 	//TODO: Add code here to handle reactions in private chat
 
 	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
 	if err != nil {
-		log.Println(err)
+		guild.log().Warn(err)
 	}
 
 
-	if guild.GameStateMsg.Exists() {
+	if gs.GameStateMsg.Exists() {
 
 		//verify that the user is reacting to the state/status message
-		if guild.GameStateMsg.IsReactionTo(m) {
+		if gs.GameStateMsg.IsReactionTo(m) {
 
 
 
 			idMatched := false
-			for color, e := range guild.StatusEmojis[true] {
-				if e.ID == m.Emoji.ID {
+			for color, e := range guild.StatusEmojis()[true] {
+				if e.Matches(m.Emoji) {
 					idMatched = true
-					log.Printf("Player %s reacted with color %s", m.UserID, game.GetColorStringForInt(color))
+					guild.gameLog(gs).With(logger.Fields{logger.UserKey: m.UserID}).Infof("Player reacted with color %s", game.GetColorStringForInt(color))
 					//the user doesn't exist in our userdata cache; add them
 
 					_, added := guild.checkCacheAndAddUser(g, s, m.UserID)
 					if !added {
-						log.Println("No users found in Discord for userID " + m.UserID)
+						guild.log().With(logger.Fields{logger.UserKey: m.UserID}).Warn("No users found in Discord for the user")
 					}
 
-					playerData := guild.AmongUsData.GetByColor(game.GetColorStringForInt(color))
+					playerData := gs.AmongUsData.GetByColor(game.GetColorStringForInt(color))
 					if playerData != nil {
-						guild.UserData.UpdatePlayerData(m.UserID, playerData)
+						err := guild.UserData.UpdatePlayerData(m.UserID, gs.ID, playerData)
+						if err != nil {
+							//like someone else already being linked to that color
+							guild.gameLog(gs).With(logger.Fields{logger.UserKey: m.UserID}).Warn(err)
+						} else {
+							guild.publish(linkEvent(gs, m.UserID, playerData, byReaction(m.UserID)))
+							guild.reportNicknameProblem(s, m.ChannelID, m.UserID)
+						}
 					} else {
-						log.Println("I couldn't find any player data for that color; is your capture linked?")
+						guild.gameLog(gs).Warn("I couldn't find any player data for that color; is your capture linked?")
 					}
 
 					//then remove the player's reaction if we matched, or if we didn't
 					err := s.MessageReactionRemove(m.ChannelID, m.MessageID, e.FormatForReaction(), m.UserID)
 					if err != nil {
-						log.Println(err)
+						guild.log().Warn(err)
 					}
 					break
 				}
@@ -354,19 +448,22 @@ func (guild *GuildState) handleReactionGameStartAdd(s *discordgo.Session, m *dis
 			if !idMatched {
 				//log.Println(m.Emoji.Name)
 				if m.Emoji.Name == "❌" {
-					log.Printf("REACTIONGAMESTART Removing player %s", m.UserID)
-					guild.UserData.ClearPlayerData(m.UserID)
+					guild.gameLog(gs).With(logger.Fields{logger.UserKey: m.UserID}).Info("Removing player after they reacted with ❌")
+					if previousGame := guild.UserData.ClearPlayerData(m.UserID); previousGame != "" {
+						guild.publish(unlinkEvent(previousGame, m.UserID, byReaction(m.UserID)))
+					}
+					guild.releaseMember(s, m.UserID)
 					err := s.MessageReactionRemove(m.ChannelID, m.MessageID, "❌", m.UserID)
 					if err != nil {
-						log.Println(err)
+						guild.log().Warn(err)
 					}
 					idMatched = true
 				}
 			}
 			//make sure to update any voice changes if they occurred
 			if idMatched {
-				guild.handleTrackedMembers(s, 0, NoPriority)
-				guild.GameStateMsg.Edit(s, gameStateResponse(guild))
+				guild.handleTrackedMembers(context.Background(), s, gs, 0, nil)
+				guild.requestStatusEdit(gs)
 			}
 
 		}
@@ -381,75 +478,73 @@ func (guild *GuildState) handleReactionPrivateUserMessage(s *discordgo.Session,
 
 	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
 	if err != nil {
-		log.Println(err)
+		guild.log().Warn(err)
 	}
 
 	if !guild.PrivateStateMsg.Exists() || !guild.PrivateStateMsg.IsReactionTo(m){
 		return
 	}
 
-	log.Printf("Registered reaction in private user message!");
+	gs := guild.Games.Get(guild.PrivateStateMsg.GameID())
+	if gs == nil {
+		guild.log().Debug("The game for the private user message has already ended")
+		return
+	}
+
+	guild.gameLog(gs).With(logger.Fields{logger.UserKey: m.UserID}).Debug("Registered reaction in private user message")
 
 
-	log.Print("Printing printedUsers...");
-	for _, printed := range guild.PrivateStateMsg.printedUsers {
-		log.Print("printed: " + printed);
+	guild.log().Debug("Printing printedUsers...")
+	for _, printed := range guild.PrivateStateMsg.Printed() {
+		guild.log().Debug("printed: " + printed)
 	}
-	log.Print("END Printing printedUsers...");
+	guild.log().Debug("END Printing printedUsers...")
 
 
 	//if (len(guild.PrivateStateMsg.printedUsers) == len(guild.PrivateStateMsg.idUsernameMap)) {
 	//	return;
 	//}
 
-	var first = len(guild.PrivateStateMsg.printedUsers) == 1;
+	var idUsernameMap = guild.PrivateStateMsg.Users();
+	var printedUsers = guild.PrivateStateMsg.Printed();
 
-	var idUsernameMap = guild.PrivateStateMsg.idUsernameMap;
-	var printedUsers = guild.PrivateStateMsg.printedUsers;
-
-	var userId string;
-
-	if (first) {
-		userId = guild.PrivateStateMsg.printedUsers[0];
-	} else {
-
-		var message, _ = s.ChannelMessage(m.ChannelID, m.MessageID);
-		for _, em := range message.Embeds {
-			for _, fi := range em.Fields {
-				if fi.Name == "User ID" {
-					userId = fi.Value;
-				}
-			}
-		}
-	}
+	//the message's fields are in the guild's language, so who it's about is kept when it's sent
+	var userId = guild.PrivateStateMsg.UserID();
 
 
 	idMatched := false
-	for color, e := range guild.StatusEmojis[true] {
-		if e.ID == m.Emoji.ID {
+	for color, e := range guild.StatusEmojis()[true] {
+		if e.Matches(m.Emoji) {
 			idMatched = true
-			log.Printf("The react button %s has been pressed", game.GetColorStringForInt(color))
+			guild.gameLog(gs).Debugf("The react button %s has been pressed", game.GetColorStringForInt(color))
 			//the user doesn't exist in our userdata cache; add them
 
-			log.Print("TargetUserId: " + userId);
-			log.Print("MasterControllerUserId: " + m.UserID);
+			guild.log().Debug("TargetUserId: " + userId)
+			guild.log().Debug("MasterControllerUserId: " + m.UserID)
 
 			_, added := guild.checkCacheAndAddUser(g, s, userId)
 			if !added {
-				log.Println("No users found in Discord for userID " + userId)
+				guild.log().With(logger.Fields{logger.UserKey: userId}).Warn("No users found in Discord for the user")
 			}
 
-			playerData := guild.AmongUsData.GetByColor(game.GetColorStringForInt(color))
+			playerData := gs.AmongUsData.GetByColor(game.GetColorStringForInt(color))
 			if playerData != nil {
-				guild.UserData.UpdatePlayerData(userId, playerData)
+				err := guild.UserData.UpdatePlayerData(userId, gs.ID, playerData)
+				if err != nil {
+					//like someone else already being linked to that color
+					guild.gameLog(gs).With(logger.Fields{logger.UserKey: userId}).Warn(err)
+				} else {
+					guild.publish(linkEvent(gs, userId, playerData, byReaction(m.UserID)))
+					guild.reportNicknameProblem(s, m.ChannelID, userId)
+				}
 			} else {
-				log.Println("I couldn't find any player data for that color; is your capture linked?")
+				guild.gameLog(gs).Warn("I couldn't find any player data for that color; is your capture linked?")
 			}
 
 			//then remove the player's reaction if we matched, or if we didn't
 			err := s.MessageReactionRemove(m.ChannelID, m.MessageID, e.FormatForReaction(), m.UserID)
 			if err != nil {
-				log.Println(err)
+				guild.log().Warn(err)
 			}
 			break
 		}
@@ -457,14 +552,14 @@ func (guild *GuildState) handleReactionPrivateUserMessage(s *discordgo.Session,
 	if !idMatched {
 		//log.Println(m.Emoji.Name)
 		if m.Emoji.Name == "❌" {
-			log.Printf("REACTIONPRIVATE Skipping discord user %s", m.UserID)
+			guild.log().With(logger.Fields{logger.UserKey: m.UserID}).Debug("Skipping discord user in private message")
 		}
 	}
 
-	var previousMessage = guild.PrivateStateMsg.message;
+	var previousMessage = guild.PrivateStateMsg.Message();
 	s.ChannelMessageDelete(previousMessage.ChannelID, previousMessage.ID) // Deletes Message
 
-	if (len(guild.PrivateStateMsg.printedUsers) == len(guild.PrivateStateMsg.idUsernameMap)) {
+	if (len(printedUsers) == len(idUsernameMap)) {
 		return;
 	}
 
@@ -475,20 +570,20 @@ func (guild *GuildState) handleReactionPrivateUserMessage(s *discordgo.Session,
 	for uId, uName := range idUsernameMap {
 
 
-		log.Print("Showing printedUsers again...")
+		guild.log().Debug("Showing printedUsers again...")
 		var shownUsername = false;
 		for _, username := range printedUsers {
-			log.Print("user: " + username);
+			guild.log().Debug("user: " + username)
 			if username == uId {
 				shownUsername = true;
 			}
 		}
 
-		log.Print("uID:" + uId)
+		guild.log().Debug("uID:" + uId)
 
 		if (!shownUsername) {
-			log.Print("Haven't shown this user: " + uName);
-			log.Print("users id: " + uId);
+			guild.log().Debug("Haven't shown this user: " + uName)
+			guild.log().Debug("users id: " + uId)
 			userId = uId;
 			userName = uName;
 			//username = uName;
@@ -496,30 +591,27 @@ func (guild *GuildState) handleReactionPrivateUserMessage(s *discordgo.Session,
 		}
 	}
 
-	var newMessage = guild.PrivateStateMsg.CreateMessage(s, guild.PrivateStateMsg.privateMapResponse(userId, userName), guild.PrivateStateMsg.privateChannelID)
+	var newMessage = guild.PrivateStateMsg.CreateMessage(s, userId, guild.PrivateStateMsg.privateMapResponse(guild.PersistentGuildData.GetLanguage(), userId, userName), guild.PrivateStateMsg.ChannelID())
 
 	if (newMessage == nil) {
-		log.Print("newMessage is nil!")
+		guild.log().Warn("newMessage is nil!")
 		return;
 	}
 
-	guild.PrivateStateMsg.printedUsers = append(guild.PrivateStateMsg.printedUsers, userId);
-
-
-	guild.PrivateStateMsg.message = newMessage;
+	guild.PrivateStateMsg.MarkPrinted(userId);
 
 
-	log.Print("printing reactions:")
+	guild.log().Debug("printing reactions:")
 
-	for _, e := range guild.StatusEmojis[true] {
+	for _, e := range guild.StatusEmojis()[true] {
 		guild.PrivateStateMsg.AddReaction(s, e.FormatForReaction())
-		log.Print("Printed reaction...")
+		guild.log().Debug("Printed reaction...")
 	}
 	guild.PrivateStateMsg.AddReaction(s, "❌")
-	log.Print("Reactions printed")
+	guild.log().Debug("Reactions printed")
 
-	log.Print("Message id: " + guild.PrivateStateMsg.message.ID);
-	log.Print("Content: " + guild.PrivateStateMsg.message.Content);
+	guild.log().Debug("Message id: " + newMessage.ID)
+	guild.log().Debug("Content: " + newMessage.Content)
 
 
 	//Create extra message here?
@@ -530,15 +622,28 @@ func (guild *GuildState) ToString() string {
 	return fmt.Sprintf("%v", guild)
 }
 
-func (guild *GuildState) clearGameTracking(s *discordgo.Session) {
+//clearGameTracking resets a game, and returns the IDs of the users that were linked in it
+func (guild *GuildState) clearGameTracking(s *discordgo.Session, gs *GameState) []string {
 	//clear the discord user links to underlying player data
-	guild.UserData.ClearAllPlayerData()
+	cleared := guild.UserData.ClearAllPlayerData(gs.ID)
 
 	//clears the base-level player data in memory
-	guild.AmongUsData.ClearAllPlayerData()
+	gs.AmongUsData.ClearAllPlayerData()
 
 	//reset all the tracking channels
-	guild.Tracking.Reset()
+	gs.Tracking.Reset()
+
+	gs.GameStateMsg.Delete(s)
+
+	return cleared
+}
+
+// log returns a logger for lines about the guild
+func (guild *GuildState) log() *logger.Logger {
+	return logger.With(logger.Fields{logger.GuildKey: guild.PersistentGuildData.GuildID})
+}
 
-	guild.GameStateMsg.Delete(s)
+// gameLog returns a logger for lines about one of the guild's games
+func (guild *GuildState) gameLog(gs *GameState) *logger.Logger {
+	return guild.log().With(logger.Fields{logger.GameKey: gs.ID})
 }
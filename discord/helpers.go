@@ -5,7 +5,8 @@ import (
 	"encoding/hex"
 	"github.com/bwmarrin/discordgo"
 	"github.com/denverquane/amongusdiscord/game"
-	"log"
+	"github.com/denverquane/amongusdiscord/logger"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,42 +19,83 @@ type UserPatchParameters struct {
 	Nick    string
 }
 
-func guildMemberUpdate(s *discordgo.Session, params UserPatchParameters) {
-	g, err := s.Guild(params.GuildID)
+//patchLog logs about a patch to a member
+func patchLog(params UserPatchParameters) *logger.Logger {
+	return logger.With(logger.Fields{logger.GuildKey: params.GuildID, logger.UserKey: params.UserID})
+}
+
+func guildMemberUpdate(s *discordgo.Session, params UserPatchParameters) error {
+	g, err := s.State.Guild(params.GuildID)
 	if err != nil {
-		log.Println(err)
+		logger.With(logger.Fields{logger.GuildKey: params.GuildID}).Warn(err)
+		g, err = s.Guild(params.GuildID)
+		if err != nil {
+			return err
+		}
 	}
 
 	//we can't nickname the owner, and we shouldn't nickname with an empty string...
 	if params.Nick == "" || g.OwnerID == params.UserID {
-		guildMemberUpdateNoNick(s, params)
+		return guildMemberUpdateNoNick(s, params)
 	} else {
 		newParams := struct {
 			Deaf bool   `json:"deaf"`
 			Mute bool   `json:"mute"`
 			Nick string `json:"nick"`
 		}{params.Deaf, params.Mute, params.Nick}
-		log.Printf("Issuing update request to discord for userID %s with mute=%v deaf=%v nick=%s\n", params.UserID, params.Mute, params.Deaf, params.Nick)
+		patchLog(params).Debugf("Issuing update request to discord with mute=%v deaf=%v nick=%s", params.Mute, params.Deaf, params.Nick)
 
 		_, err := s.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(params.GuildID, params.UserID), newParams, discordgo.EndpointGuildMember(params.GuildID, ""))
 		if err != nil {
-			log.Println("Failed to change nickname for user: move the bot up in your Roles")
-			log.Println(err)
-			guildMemberUpdateNoNick(s, params)
+			patchLog(params).Warnf("Failed to change nickname for user: move the bot up in your Roles: %s", err)
+			voicePatches.Inc("retried")
+			return guildMemberUpdateNoNick(s, params)
 		}
 	}
+	return nil
 }
 
-func guildMemberUpdateNoNick(s *discordgo.Session, params UserPatchParameters) {
-	log.Printf("Issuing update request to discord for userID %s with mute=%v deaf=%v\n", params.UserID, params.Mute, params.Deaf)
+func guildMemberUpdateNoNick(s *discordgo.Session, params UserPatchParameters) error {
+	patchLog(params).Debugf("Issuing update request to discord with mute=%v deaf=%v", params.Mute, params.Deaf)
 	newParams := struct {
 		Deaf bool `json:"deaf"`
 		Mute bool `json:"mute"`
 	}{params.Deaf, params.Mute}
 	_, err := s.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(params.GuildID, params.UserID), newParams, discordgo.EndpointGuildMember(params.GuildID, ""))
 	if err != nil {
-		log.Println(err)
+		patchLog(params).Error(err)
+	}
+	return err
+}
+
+//guildMemberReset unmutes and undeafens a member, and gives them back their original nickname if we changed it
+func guildMemberReset(s *discordgo.Session, guildID string, member ModifiedMember) error {
+	patchLog(UserPatchParameters{GuildID: guildID, UserID: member.UserID}).Debug("Issuing reset request to discord")
+	var newParams interface{}
+	if member.NickChanged {
+		newParams = struct {
+			Deaf bool   `json:"deaf"`
+			Mute bool   `json:"mute"`
+			Nick string `json:"nick"`
+		}{false, false, member.OriginalNick}
+	} else {
+		newParams = struct {
+			Deaf bool `json:"deaf"`
+			Mute bool `json:"mute"`
+		}{false, false}
 	}
+	_, err := s.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, member.UserID), newParams, discordgo.EndpointGuildMember(guildID, ""))
+	return err
+}
+
+//guildMemberResetNick gives a member back their original nickname; unlike mutes, this works when they aren't in voice
+func guildMemberResetNick(s *discordgo.Session, guildID string, member ModifiedMember) error {
+	patchLog(UserPatchParameters{GuildID: guildID, UserID: member.UserID}).Debug("Issuing nickname reset request to discord")
+	newParams := struct {
+		Nick string `json:"nick"`
+	}{member.OriginalNick}
+	_, err := s.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, member.UserID), newParams, discordgo.EndpointGuildMember(guildID, ""))
+	return err
 }
 
 func getPhaseFromArgs(args []string) game.Phase {
@@ -123,11 +165,18 @@ func getRoomAndRegionFromArgs(args []string) (string, string) {
 }
 
 func generateConnectCode(guildID string) string {
-	h := sha256.New()
-	h.Write([]byte(guildID))
-	//add some "randomness" with the current time
-	h.Write([]byte(time.Now().String()))
-	hashed := strings.ToUpper(hex.EncodeToString(h.Sum(nil))[0:6])
-	//TODO replace common problematic characters?
-	return strings.ReplaceAll(strings.ReplaceAll(hashed, "I", "1"), "O", "0")
+	for attempt := 0; ; attempt++ {
+		h := sha256.New()
+		h.Write([]byte(guildID))
+		//add some "randomness" with the current time
+		h.Write([]byte(time.Now().String()))
+		h.Write([]byte(strconv.Itoa(attempt)))
+		hashed := strings.ToUpper(hex.EncodeToString(h.Sum(nil))[0:6])
+		//TODO replace common problematic characters?
+		code := strings.ReplaceAll(strings.ReplaceAll(hashed, "I", "1"), "O", "0")
+		//captures can connect to any process, which finds the guild's shard from the code
+		if codeShard(code) == guildShard(guildID) {
+			return code
+		}
+	}
 }
@@ -0,0 +1,140 @@
+package discord
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// KeepGuildData keeps the data of guilds that removed the bot forever
+const KeepGuildData = time.Duration(-1)
+
+// GuildPurgeInterval is how often the data of guilds that removed the bot is checked for anything to purge
+const GuildPurgeInterval = time.Hour
+
+//guildRetention is how long the data of guilds that removed the bot is kept for, in case they add it back
+var guildRetention = KeepGuildData
+
+//startGuild starts everything that runs in the background for a guild, until it's torn down
+func (guild *GuildState) startGuild(s *discordgo.Session, socketUpdates *chan SocketStatus, phaseUpdates *chan PhaseUpdate, playerUpdates *chan PlayerUpdate) {
+	go updatesListener(guild.ctx, s, guild.PersistentGuildData.GuildID, socketUpdates, phaseUpdates, playerUpdates)
+	go guild.reconcileLoop(s)
+	go guild.statusEditLoop(s)
+	go guild.auditLoop(s)
+}
+
+// removeGuild tears a guild down when discord stops sending its events: when the bot was removed from it, or during
+// an outage. Outages end with another GuildCreate, which sets the guild up again from its files
+func removeGuild(s *discordgo.Session, m *discordgo.GuildDelete) {
+	guild, ok := AllGuilds.Remove(m.ID)
+	if !ok {
+		return
+	}
+	guild.teardown()
+
+	if m.Unavailable {
+		guild.log().Warn("Guild is unavailable because of a discord outage; stopped it until it's back")
+		guildLifecycleEvents.Inc("outage")
+		return
+	}
+	guild.log().Info("Removed from guild")
+	guildLifecycleEvents.Inc("removed")
+
+	if guildRetention == 0 {
+		purgeGuildData(m.ID)
+		return
+	}
+	now := time.Now()
+	guild.PersistentGuildData.SetRemovedAt(&now)
+	err := guild.PersistentGuildData.ToFile(ConfigFilename(m.ID))
+	if err != nil {
+		guild.log().Error(err)
+	}
+}
+
+//teardown stops everything running for the guild. Members it left muted can't be restored while discord isn't
+//sending the guild's events, so they stay recorded, to be restored if the guild comes back
+func (guild *GuildState) teardown() {
+	guildID := guild.PersistentGuildData.GuildID
+	//stops the updatesListener, the background loops and any overlay streams
+	guild.stop()
+
+	ChannelsMapLock.Lock()
+	delete(SocketUpdateChannels, guildID)
+	delete(PlayerUpdateChannels, guildID)
+	delete(GamePhaseUpdateChannels, guildID)
+	delete(UpdateQueueOverflows, guildID)
+	ChannelsMapLock.Unlock()
+
+	for _, gs := range guild.Games.All() {
+		gs.cancelTransition()
+	}
+
+	//captures still connected to the guild's games have to link again once it's back
+	LinkCodeLock.Lock()
+	for code, key := range LinkCodes {
+		if key.GuildID == guildID {
+			delete(LinkCodes, code)
+		}
+	}
+	LinkCodeLock.Unlock()
+	if unlinked := AllConns.UnlinkGuild(guildID); unlinked > 0 {
+		captureConnections.Add(-float64(unlinked), guildID)
+	}
+
+	guild.Webhooks.Close()
+	guild.log().Debug("Stopped everything running for the guild")
+}
+
+//purgeGuildData deletes everything kept on disk for a guild
+func purgeGuildData(guildID string) {
+	guildLog := logger.With(logger.Fields{logger.GuildKey: guildID})
+	for _, filename := range []string{ConfigFilename(guildID), ModifiedMembersFilename(guildID), WebhookDeadLettersFilename(guildID)} {
+		err := os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			guildLog.Error(err)
+		}
+	}
+	guildLog.Info("Purged the data of a guild that removed the bot")
+	guildLifecycleEvents.Inc("purged")
+}
+
+//purgeRemovedGuilds purges the data of this shard's guilds that removed the bot longer ago than the retention period
+func purgeRemovedGuilds() {
+	if guildRetention < 0 {
+		return
+	}
+	filenames, err := filepath.Glob(ConfigFilename("*"))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, filename := range filenames {
+		pgd, err := LoadPGDFromFile(filename)
+		if err != nil || pgd.RemovedAt == nil || time.Since(*pgd.RemovedAt) < guildRetention {
+			continue
+		}
+		//other shards look after their own guilds, and guilds that added the bot back aren't purged
+		if guildShard(pgd.GuildID) != sharding.ID {
+			continue
+		}
+		if _, ok := AllGuilds.Get(pgd.GuildID); ok {
+			continue
+		}
+		purgeGuildData(pgd.GuildID)
+	}
+}
+
+//purgeLoop purges the data of guilds that removed the bot, once they've been gone for the retention period
+func purgeLoop(ctx context.Context) {
+	for {
+		purgeRemovedGuilds()
+		if !sleepContext(ctx, GuildPurgeInterval) {
+			return
+		}
+	}
+}
@@ -2,7 +2,7 @@ package discord
 
 import (
 	"github.com/denverquane/amongusdiscord/game"
-	"log"
+	"github.com/denverquane/amongusdiscord/logger"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -10,35 +10,56 @@ import (
 //const voiceChannel = "758127642661748766"; // Cloaking's Server VoiceChannel
 const voiceChannel = "758099224838668299";
 
-func (guild *GuildState) handleGameEndMessage(s *discordgo.Session) {
-	guild.AmongUsData.SetAllAlive()
-	guild.AmongUsData.SetPhase(game.LOBBY)
+func (guild *GuildState) handleGameEndMessage(s *discordgo.Session, gs *GameState, by Trigger) {
+	guild.publish(GuildEvent{Type: GameEndEvent, GameID: gs.ID, By: by})
+	//whatever was waiting to be applied for the last phase is moot now
+	gs.cancelTransition()
 
-	// apply the unmute/deafen to users who have state linked to them
-	guild.handleTrackedMembers(s, 0, NoPriority)
+	gs.AmongUsData.SetAllAlive()
+	gs.AmongUsData.SetPhase(game.LOBBY)
 
 	//clear the tracking and make sure all users are unlinked
-	guild.clearGameTracking(s)
+	linked := guild.clearGameTracking(s, gs)
+
+	// unmute/undeafen everyone we changed in this game, and give them back their original nicknames
+	for _, userID := range linked {
+		guild.releaseMember(s, userID)
+	}
 
 	// clear any existing game state message
-	guild.AmongUsData.SetRoomRegion("", "")
+	gs.AmongUsData.SetRoomRegion("", "")
+
+	//the game is over; stop accepting captures for it, and free up its ID
+	LinkCodeLock.Lock()
+	for code, key := range LinkCodes {
+		if key.GuildID == guild.PersistentGuildData.GuildID && key.GameID == gs.ID {
+			delete(LinkCodes, code)
+		}
+	}
+	LinkCodeLock.Unlock()
+	guild.Games.Remove(gs.ID)
 }
 
-func (guild *GuildState) handleGameStartMessage(s *discordgo.Session, m *discordgo.MessageCreate, room string, region string, channel TrackingChannel) {
-	guild.AmongUsData.SetRoomRegion(room, region)
+func (guild *GuildState) handleGameStartMessage(s *discordgo.Session, m *discordgo.MessageCreate, gs *GameState, room string, region string, channel TrackingChannel) {
+	gs.AmongUsData.SetRoomRegion(room, region)
+	gs.Deaths.Reset()
 
-	guild.clearGameTracking(s)
+	for _, userID := range guild.clearGameTracking(s, gs) {
+		guild.releaseMember(s, userID)
+	}
 
 	if channel.channelID != "" {
-		guild.Tracking.AddTrackedChannel(channel.channelID, channel.channelName, channel.forGhosts)
+		gs.Tracking.AddTrackedChannel(channel.channelID, channel.channelName, channel.forGhosts)
 	}
 
-	guild.GameStateMsg.CreateMessage(s, gameStateResponse(guild), m.ChannelID)
+	gs.GameStateMsg.CreateMessage(s, gameStateResponse(guild, gs), m.ChannelID)
+	guild.publish(GuildEvent{Type: GameStartEvent, GameID: gs.ID, By: byCommand(m.Author.ID)})
 
-	log.Println("Added self game state message")
+	guild.gameLog(gs).Debug("Added self game state message")
 }
 
-func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discordgo.MessageCreate, gs *GameState) {
+	guild.PrivateStateMsg.SetGameID(gs.ID)
 
 	// Custom Code:
 	var guildId = m.GuildID;
@@ -46,20 +67,19 @@ func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discor
 
 	var idUsernameMap = make(map[string]string);
 
-	for _, vs := range g.VoiceStates {
+	for _, vs := range voiceStates(s, g) {
 		if (vs.ChannelID != voiceChannel) {
 			continue;
 		}
 		var member, err = s.State.Member(guildId, vs.UserID)
 
 		if (err != nil) {
-			log.Println("Error: " + err.Error());
-			log.Println("Falling back to s.GuildMember implementation");
+			guild.log().With(logger.Fields{logger.UserKey: vs.UserID}).Warnf("Falling back to s.GuildMember implementation: %s", err)
 			member, _ = s.GuildMember(guildId, vs.UserID);
 		}
 
 		if (member == nil) {
-			log.Print("Member is nil for vs.UserId: " + vs.UserID);
+			guild.log().With(logger.Fields{logger.UserKey: vs.UserID}).Warn("Member is nil")
 			continue;
 		}
 
@@ -81,7 +101,7 @@ func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discor
 		//log.Print("User: " + targetUsername + "|||||");
 		//log.Print("User ID: " + user.ID);
 		if (targetUsername == "") {
-			log.Print("UserID hasn't been saved before: USERID:" + userID + " NAME:" + username);
+			guild.log().Debug("UserID hasn't been saved before: USERID:" + userID + " NAME:" + username)
 			// user hasn't been saved before.
 			//log.Print("User is equal to an empty string!");
 
@@ -95,8 +115,8 @@ func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discor
 
 			for _, uName := range idUsernameMap {
 				if (uName == username) {
-					log.Print("Found a previous user with the same name as current user: " + username);
-					log.Print("Setting discrimination necessary to true");
+					guild.log().Debug("Found a previous user with the same name as current user: " + username)
+					guild.log().Debug("Setting discrimination necessary to true")
 					//Came across exact same name. Update all with the same name to also include the discriminator for the name
 					discriminationNecessary = true
 					break;
@@ -106,71 +126,71 @@ func (guild *GuildState) createPrivateMapMessage(s *discordgo.Session, m *discor
 			idUsernameMap[userID] = username;
 
 			if (discriminationNecessary) {
-				log.Print("Determined that discrimination necessary is true");
+				guild.log().Debug("Determined that discrimination necessary is true")
 				usernameIdCopy := make(map[string]string);
 
-				log.Print("Looking through the map for similar values.");
+				guild.log().Debug("Looking through the map for similar values.")
 				for uID, uName := range idUsernameMap {
 					var value = uName;
 					if (uName == username) {
-						log.Print("Found a similar value...");
+						guild.log().Debug("Found a similar value...")
 						// Is a duplicate/original of the name. Add descriminator
 						var targetMember, _ = s.State.Member(guildId, uID)
 						value = uName + "#" + targetMember.User.Discriminator;
-						log.Print("Updating name to use discriminator: " + targetMember.User.Discriminator);
+						guild.log().Debug("Updating name to use discriminator: " + targetMember.User.Discriminator)
 					}
 
 					usernameIdCopy[uID] = value;
 				}
-				log.Print("Finished iteration through map for similar values.");
+				guild.log().Debug("Finished iteration through map for similar values.")
 
 				idUsernameMap = usernameIdCopy;
 
-				log.Print("Updated map");
+				guild.log().Debug("Updated map")
 
 
 			}
 		} else {
-			log.Print("User has been saved before: " + user.Username);
+			guild.log().Debug("User has been saved before: " + user.Username)
 			// User has been saved before
 		}
 	}
 
-	guild.PrivateStateMsg.idUsernameMap = idUsernameMap;
+	guild.PrivateStateMsg.SetUsers(idUsernameMap);
 
-	log.Print("Showing Map:")
+	guild.log().Debug("Showing Map:")
 	for uID, uName := range idUsernameMap {
-		log.Print(uID + ": " + uName);
+		guild.log().Debug(uID + ": " + uName)
 	}
-	log.Print("Map Finished:")
+	guild.log().Debug("Map Finished:")
 
 	//var message *discordgo.Message;
 
 	var message *discordgo.Message;
 	for uID, uName := range idUsernameMap {
-		message = guild.PrivateStateMsg.CreateMessage(s, guild.PrivateStateMsg.privateMapResponse(uID, uName), guild.PrivateStateMsg.privateChannelID)
-		guild.PrivateStateMsg.printedUsers = append(guild.PrivateStateMsg.printedUsers, uID);
+		message = guild.PrivateStateMsg.CreateMessage(s, uID, guild.PrivateStateMsg.privateMapResponse(guild.PersistentGuildData.GetLanguage(), uID, uName), guild.PrivateStateMsg.ChannelID())
+		guild.PrivateStateMsg.MarkPrinted(uID);
 		break;
 	}
 
-	guild.PrivateStateMsg.message = message;
+	guild.PrivateStateMsg.SetMessage(message);
 
 
-	log.Print("printing reactions:")
+	guild.log().Debug("printing reactions:")
 
-	for _, e := range guild.StatusEmojis[true] {
+	for _, e := range guild.StatusEmojis()[true] {
 		guild.PrivateStateMsg.AddReaction(s, e.FormatForReaction())
-		log.Print("Printed reaction...")
+		guild.log().Debug("Printed reaction...")
 	}
 	guild.PrivateStateMsg.AddReaction(s, "❌")
-	log.Print("Reactions printed")
+	guild.log().Debug("Reactions printed")
 }
 
 // sendMessage provides a single interface to send a message to a channel via discord
 func sendMessage(s *discordgo.Session, channelID string, message string) *discordgo.Message {
 	msg, err := s.ChannelMessageSend(channelID, message)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 	return msg
 }
@@ -178,7 +198,7 @@ func sendMessage(s *discordgo.Session, channelID string, message string) *discor
 func sendMessageEmbed(s *discordgo.Session, channelID string, message *discordgo.MessageEmbed) *discordgo.Message {
 	msg, err := s.ChannelMessageSendEmbed(channelID, message)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 	return msg
 }
@@ -187,7 +207,7 @@ func sendMessageEmbed(s *discordgo.Session, channelID string, message *discordgo
 func editMessage(s *discordgo.Session, channelID string, messageID string, message string) *discordgo.Message {
 	msg, err := s.ChannelMessageEdit(channelID, messageID, message)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 	return msg
 }
@@ -195,7 +215,7 @@ func editMessage(s *discordgo.Session, channelID string, messageID string, messa
 func editMessageEmbed(s *discordgo.Session, channelID string, messageID string, message *discordgo.MessageEmbed) *discordgo.Message {
 	msg, err := s.ChannelMessageEditEmbed(channelID, messageID, message)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 	return msg
 }
@@ -203,20 +223,20 @@ func editMessageEmbed(s *discordgo.Session, channelID string, messageID string,
 func deleteMessage(s *discordgo.Session, channelID string, messageID string) {
 	err := s.ChannelMessageDelete(channelID, messageID)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 }
 
 func addReaction(s *discordgo.Session, channelID, messageID, emojiID string) {
 	err := s.MessageReactionAdd(channelID, messageID, emojiID)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 }
 
 func removeAllReactions(s *discordgo.Session, channelID, messageID string) {
 	err := s.MessageReactionsRemoveAll(channelID, messageID)
 	if err != nil {
-		log.Println(err)
+		logger.Error(err)
 	}
 }
@@ -0,0 +1,125 @@
+package discord
+
+import (
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/metrics"
+)
+
+var (
+	captureConnections = metrics.NewGauge("amongus_capture_connections",
+		"Captures currently connected, per guild", "guild")
+	captureConnectionEvents = metrics.NewCounter("amongus_capture_connection_events_total",
+		"Captures connecting to and disconnecting from games", "guild", "event")
+	phaseTransitions = metrics.NewCounter("amongus_phase_transitions_total",
+		"Game phase transitions", "from", "to")
+	playerEvents = metrics.NewCounter("amongus_player_events_total",
+		"Player updates received from captures, by action", "action")
+	voicePatches = metrics.NewCounter("amongus_voice_patches_total",
+		"Mute/deafen/nickname requests sent to discord; retried requests are sent again without the nickname, or by reconciliation",
+		"result")
+	voicePatchLatency = metrics.NewHistogram("amongus_voice_patch_duration_seconds",
+		"How long mute/deafen/nickname requests to discord take", metrics.DefaultBuckets)
+	statusEdits = metrics.NewCounter("amongus_status_edits_total",
+		"Status message edits, and edits skipped because nothing shown changed", "result")
+	commandUsage = metrics.NewCounter("amongus_commands_total",
+		"Commands used, by command", "command")
+	webhookAttempts = metrics.NewCounter("amongus_webhook_attempts_total",
+		"Attempts at delivering events to webhooks", "result")
+	webhookDeadLetters = metrics.NewCounter("amongus_webhook_dead_letters_total",
+		"Events given up on, because every attempt failed or the webhook's queue was full", "reason")
+	captureForwards = metrics.NewCounter("amongus_capture_forwards_total",
+		"Capture events handed to the process owning their guild, by whether that's this process, another one, or it failed",
+		"result")
+	overlayStreams = metrics.NewGauge("amongus_overlay_streams",
+		"Overlays currently streaming game state")
+	updateQueueDrops = metrics.NewCounter("amongus_update_queue_drops_total",
+		"Updates dropped because a guild's update queue was full", "guild", "queue")
+	transitionsCancelled = metrics.NewCounter("amongus_transitions_cancelled_total",
+		"Delayed voice changes cancelled because a newer phase arrived, or the game ended")
+	guildLifecycleEvents = metrics.NewCounter("amongus_guild_lifecycle_events_total",
+		"Guilds set up, removed, lost to outages, and purged", "event")
+	_ = metrics.NewGaugeFunc("amongus_guilds",
+		"Guilds this process runs", nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(AllGuilds.Len())}}
+		})
+	_ = metrics.NewGaugeFunc("amongus_update_queue_depth",
+		"Updates waiting in each guild's update queues", []string{"guild", "queue"}, updateQueueDepths)
+)
+
+// playerActionNames label player events in metrics
+var playerActionNames = map[game.PlayerAction]string{
+	game.JOINED:       "joined",
+	game.LEFT:         "left",
+	game.DIED:         "died",
+	game.CHANGECOLOR:  "changecolor",
+	game.FORCEUPDATED: "forceupdated",
+	game.DISCONNECTED: "disconnected",
+	game.EXILED:       "exiled",
+}
+
+func playerActionName(action game.PlayerAction) string {
+	if name, ok := playerActionNames[action]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func phaseName(phase game.Phase) string {
+	if name, ok := game.PhaseNames[phase]; ok {
+		return string(name)
+	}
+	return "UNKNOWN"
+}
+
+// commandNames map every command and alias to the command's name, so metrics aren't labeled by whatever people type
+var commandNames = map[string]string{
+	"help": "help", "h": "help",
+	"track": "track", "t": "track",
+	"link": "link", "l": "link",
+	"unlink": "unlink", "ul": "unlink", "u": "unlink",
+	"start": "new", "s": "new", "new": "new", "n": "new",
+	"end": "end", "e": "end", "endgame": "end",
+	"force": "force", "f": "force",
+	"dryrun": "dryrun", "dry": "dryrun",
+	"audit": "audit",
+	"webhook": "webhook", "webhooks": "webhook",
+	"overlay": "overlay",
+	"nickname": "nickname", "nick": "nickname",
+	"priority": "priority", "p": "priority",
+	"drift": "drift",
+	"games": "games", "g": "games",
+	"rules":  "rules",
+	"preset": "preset", "presets": "preset", "pr": "preset",
+	"language": "language", "lang": "language",
+	"template": "template", "tmpl": "template",
+	"spoilers": "spoilers", "disclosure": "spoilers",
+	"emojis":  "emojis",
+	"refresh": "refresh", "r": "refresh",
+}
+
+func commandName(arg string) string {
+	if name, ok := commandNames[arg]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func updateQueueDepths() []metrics.Sample {
+	ChannelsMapLock.RLock()
+	defer ChannelsMapLock.RUnlock()
+
+	samples := make([]metrics.Sample, 0)
+	for guildID, v := range SocketUpdateChannels {
+		samples = append(samples, metrics.Sample{LabelValues: []string{guildID, "socket"}, Value: float64(len(*v))})
+	}
+	//updates waiting for room in a full queue are still waiting
+	for guildID, v := range PlayerUpdateChannels {
+		_, overflowed := UpdateQueueOverflows[guildID].Len()
+		samples = append(samples, metrics.Sample{LabelValues: []string{guildID, "player"}, Value: float64(len(*v) + overflowed)})
+	}
+	for guildID, v := range GamePhaseUpdateChannels {
+		overflowed, _ := UpdateQueueOverflows[guildID].Len()
+		samples = append(samples, metrics.Sample{LabelValues: []string{guildID, "phase"}, Value: float64(len(*v) + overflowed)})
+	}
+	return samples
+}
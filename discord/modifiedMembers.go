@@ -0,0 +1,256 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// ShutdownRestoreTimeout is how long we wait for members to be restored before exiting anyways
+const ShutdownRestoreTimeout = 10 * time.Second
+
+// ModifiedMember records a member the bot has muted, deafened or renamed, and how to put them back
+type ModifiedMember struct {
+	UserID       string `json:"userID"`
+	OriginalNick string `json:"originalNick"`
+	NickChanged  bool   `json:"nickChanged"`
+}
+
+// ModifiedMembers is the persisted record of every member the bot has changed in a guild. It survives restarts,
+// so members left muted by a crash can be restored on the next startup
+type ModifiedMembers struct {
+	GuildID string                    `json:"guildID"`
+	Members map[string]ModifiedMember `json:"members"`
+
+	lock sync.RWMutex
+}
+
+func MakeModifiedMembers(guildID string) *ModifiedMembers {
+	return &ModifiedMembers{
+		GuildID: guildID,
+		Members: map[string]ModifiedMember{},
+		lock:    sync.RWMutex{},
+	}
+}
+
+// ModifiedMembersFilename returns the filename the modified members of a guild are stored in
+func ModifiedMembersFilename(guildID string) string {
+	return fmt.Sprintf("%s_modified.json", guildID)
+}
+
+func LoadModifiedMembersFromFile(filename string) (*ModifiedMembers, error) {
+	jsonBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	mm := ModifiedMembers{}
+	err = json.Unmarshal(jsonBytes, &mm)
+	if err != nil {
+		return nil, err
+	}
+	if mm.Members == nil {
+		mm.Members = map[string]ModifiedMember{}
+	}
+	return &mm, nil
+}
+
+//toFile must be called with the lock held
+func (mm *ModifiedMembers) toFile() {
+	jsonBytes, err := json.MarshalIndent(mm, "", "    ")
+	if err != nil {
+		logger.With(logger.Fields{logger.GuildKey: mm.GuildID}).Error(err)
+		return
+	}
+	err = ioutil.WriteFile(ModifiedMembersFilename(mm.GuildID), jsonBytes, os.ModePerm)
+	if err != nil {
+		logger.With(logger.Fields{logger.GuildKey: mm.GuildID}).Error(err)
+	}
+}
+
+// Record marks a member as modified by the bot. The first original nickname we see is the one that's kept
+func (mm *ModifiedMembers) Record(userID, originalNick string, nickChanged bool) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if v, ok := mm.Members[userID]; ok {
+		if v.NickChanged || !nickChanged {
+			return
+		}
+		v.NickChanged = true
+		v.OriginalNick = originalNick
+		mm.Members[userID] = v
+	} else {
+		mm.Members[userID] = ModifiedMember{
+			UserID:       userID,
+			OriginalNick: originalNick,
+			NickChanged:  nickChanged,
+		}
+	}
+	mm.toFile()
+}
+
+func (mm *ModifiedMembers) Remove(userID string) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if _, ok := mm.Members[userID]; ok {
+		delete(mm.Members, userID)
+		mm.toFile()
+	}
+}
+
+func (mm *ModifiedMembers) MarkNickRestored(userID string) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if v, ok := mm.Members[userID]; ok {
+		v.NickChanged = false
+		mm.Members[userID] = v
+		mm.toFile()
+	}
+}
+
+func (mm *ModifiedMembers) Get(userID string) (ModifiedMember, bool) {
+	mm.lock.RLock()
+	defer mm.lock.RUnlock()
+
+	v, ok := mm.Members[userID]
+	return v, ok
+}
+
+func (mm *ModifiedMembers) GetAll() []ModifiedMember {
+	mm.lock.RLock()
+	defer mm.lock.RUnlock()
+
+	all := make([]ModifiedMember, 0, len(mm.Members))
+	for _, v := range mm.Members {
+		all = append(all, v)
+	}
+	return all
+}
+
+func (mm *ModifiedMembers) Size() int {
+	mm.lock.RLock()
+	defer mm.lock.RUnlock()
+	return len(mm.Members)
+}
+
+//memberUpdate issues the patch to discord, and keeps the record of modified members up to date
+func (guild *GuildState) memberUpdate(s *discordgo.Session, params UserPatchParameters) {
+	nickChanged := params.Nick != ""
+	if params.Mute || params.Deaf || nickChanged {
+		originalNick := ""
+		if userData, err := guild.UserData.GetUser(params.UserID); err == nil {
+			originalNick = userData.GetOriginalNickName()
+		}
+		guild.ModifiedMembers.Record(params.UserID, originalNick, nickChanged)
+	}
+
+	start := time.Now()
+	err := guildMemberUpdate(s, params)
+	voicePatchLatency.Observe(time.Since(start).Seconds())
+	voicePatches.Inc("issued")
+	if err != nil {
+		voicePatches.Inc("failed")
+		guild.publish(GuildEvent{
+			Type:   PatchFailedEvent,
+			By:     byBot(),
+			UserID: params.UserID,
+			Detail: fmt.Sprintf("mute=%v deaf=%v: %s", params.Mute, params.Deaf, err),
+		})
+		//don't wait on an update that's never going to arrive
+		guild.UserData.SetPendingVoiceUpdate(params.UserID, false)
+	}
+	if err == nil && nickChanged {
+		guild.UserData.UpdateNickName(params.UserID, params.Nick)
+	}
+	if err == nil && !params.Mute && !params.Deaf {
+		if v, ok := guild.ModifiedMembers.Get(params.UserID); ok && !v.NickChanged {
+			guild.ModifiedMembers.Remove(params.UserID)
+		}
+	}
+}
+
+//restoreMember unmutes, undeafens and un-nicknames a member the bot modified. Members that aren't in voice can't be
+//unmuted, so they stay in the record until they rejoin voice
+func (guild *GuildState) restoreMember(s *discordgo.Session, member ModifiedMember, inVoice bool) {
+	if inVoice {
+		err := guildMemberReset(s, guild.PersistentGuildData.GuildID, member)
+		if err != nil {
+			guild.log().With(logger.Fields{logger.UserKey: member.UserID}).Errorf("Failed to restore member: %s", err)
+			return
+		}
+		guild.log().With(logger.Fields{logger.UserKey: member.UserID}).Info("Restored member")
+		guild.ModifiedMembers.Remove(member.UserID)
+		if member.NickChanged {
+			guild.UserData.UpdateNickName(member.UserID, member.OriginalNick)
+		}
+	} else if member.NickChanged {
+		err := guildMemberResetNick(s, guild.PersistentGuildData.GuildID, member)
+		if err != nil {
+			guild.log().With(logger.Fields{logger.UserKey: member.UserID}).Errorf("Failed to restore nickname of member: %s", err)
+			return
+		}
+		guild.ModifiedMembers.MarkNickRestored(member.UserID)
+		guild.UserData.UpdateNickName(member.UserID, member.OriginalNick)
+	}
+}
+
+//restoreModifiedMembers puts every member the bot has changed back to how they were
+func (guild *GuildState) restoreModifiedMembers(s *discordgo.Session) {
+	members := guild.ModifiedMembers.GetAll()
+	if len(members) == 0 {
+		return
+	}
+	guild.log().Infof("Restoring %d member(s) modified by the bot", len(members))
+
+	inVoice := map[string]bool{}
+	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
+	if err != nil {
+		guild.log().Warn(err)
+	} else {
+		for _, v := range voiceStates(s, g) {
+			inVoice[v.UserID] = true
+		}
+	}
+
+	wg := sync.WaitGroup{}
+	for _, member := range members {
+		wg.Add(1)
+		go func(member ModifiedMember) {
+			guild.restoreMember(s, member, inVoice[member.UserID])
+			wg.Done()
+		}(member)
+	}
+	wg.Wait()
+}
+
+//restoreAllGuilds restores the members of every guild, giving up after the timeout
+func restoreAllGuilds(s *discordgo.Session, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg := sync.WaitGroup{}
+		for _, guild := range AllGuilds.All() {
+			wg.Add(1)
+			go func(guild *GuildState) {
+				guild.restoreModifiedMembers(s)
+				wg.Done()
+			}(guild)
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Restored all members modified by the bot")
+	case <-time.After(timeout):
+		logger.Warnf("Timed out after %s restoring members; some may still be muted", timeout)
+	}
+}
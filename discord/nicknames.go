@@ -0,0 +1,135 @@
+package discord
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// DefaultNicknameTemplate just renames users to their in-game name
+const DefaultNicknameTemplate = "{ign}"
+
+// discord rejects nicknames longer than this
+const maxNicknameLength = 32
+
+// formatNickname fills in the {ign}, {color} and {user} placeholders of a nickname template
+func formatNickname(template, ign, color, userName string) string {
+	if template == "" {
+		template = DefaultNicknameTemplate
+	}
+	nick := strings.NewReplacer("{ign}", ign, "{color}", color, "{user}", userName).Replace(template)
+	if len([]rune(nick)) > maxNicknameLength {
+		nick = string([]rune(nick)[:maxNicknameLength])
+	}
+	return strings.TrimSpace(nick)
+}
+
+//nicknameFor returns the nickname a user should have, or "" if they shouldn't be renamed at all
+func (guild *GuildState) nicknameFor(s *discordgo.Session, userData game.UserData) string {
+	if !guild.PersistentGuildData.IsApplyingNicknames() || !userData.IsLinked() {
+		return ""
+	}
+	if guild.checkCanRename(s, userData.GetID()) != nil {
+		return ""
+	}
+	return formatNickname(guild.PersistentGuildData.GetNicknameTemplate(), userData.GetPlayerName(), game.GetColorStringForInt(userData.GetColor()), userData.GetUserName())
+}
+
+//checkCanRename determines if the bot is able to change the nickname of a user, and why not if it can't
+func (guild *GuildState) checkCanRename(s *discordgo.Session, userID string) error {
+	g, err := s.State.Guild(guild.PersistentGuildData.GuildID)
+	if err != nil {
+		return err
+	}
+	if g.OwnerID == userID {
+		return locale.Errorf("error.renameOwner")
+	}
+	botMember, err := s.State.Member(g.ID, s.State.User.ID)
+	if err != nil {
+		return err
+	}
+	member, err := s.State.Member(g.ID, userID)
+	if err != nil {
+		member, err = s.GuildMember(g.ID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	botPosition, botPerms := highestRolePosition(s, g.ID, botMember)
+	if botPerms&(discordgo.PermissionManageNicknames|discordgo.PermissionAdministrator) == 0 {
+		return locale.Errorf("error.renamePermission")
+	}
+	memberPosition, _ := highestRolePosition(s, g.ID, member)
+	if memberPosition >= botPosition {
+		return locale.Errorf("error.renameRole")
+	}
+	return nil
+}
+
+//highestRolePosition returns the position of a member's highest role, and the combined permissions of all their roles
+func highestRolePosition(s *discordgo.Session, guildID string, member *discordgo.Member) (int, int) {
+	position := 0
+	permissions := 0
+	//every member implicitly has the @everyone role, which shares the ID of the guild
+	if role, err := s.State.Role(guildID, guildID); err == nil {
+		permissions |= role.Permissions
+	}
+	for _, roleID := range member.Roles {
+		role, err := s.State.Role(guildID, roleID)
+		if err != nil {
+			continue
+		}
+		permissions |= role.Permissions
+		if role.Position > position {
+			position = role.Position
+		}
+	}
+	return position, permissions
+}
+
+//reportNicknameProblem lets the channel know up front if a user that was just linked can't be renamed
+func (guild *GuildState) reportNicknameProblem(s *discordgo.Session, channelID, userID string) {
+	if !guild.PersistentGuildData.IsApplyingNicknames() {
+		return
+	}
+	if err := guild.checkCanRename(s, userID); err != nil {
+		guild.log().With(logger.Fields{logger.UserKey: userID}).Warnf("Can't rename user: %s", err)
+		sendMessage(s, channelID, guild.text("reply.cantRename", userID, guild.errorText(err)))
+	}
+}
+
+//releaseMember gives a member back their original nickname and voice state, if the bot changed them
+func (guild *GuildState) releaseMember(s *discordgo.Session, userID string) {
+	if guild.PersistentGuildData.IsDryRun() {
+		return
+	}
+	member, ok := guild.ModifiedMembers.Get(userID)
+	if !ok {
+		return
+	}
+	inVoice := false
+	if g, err := s.State.Guild(guild.PersistentGuildData.GuildID); err == nil {
+		for _, v := range voiceStates(s, g) {
+			if v.UserID == userID {
+				inVoice = true
+				break
+			}
+		}
+	}
+	guild.restoreMember(s, member, inVoice)
+}
+
+func (guild *GuildState) nicknameResponse() string {
+	if !guild.PersistentGuildData.IsApplyingNicknames() {
+		return guild.text("reply.nicknamesOff")
+	}
+	template := guild.PersistentGuildData.GetNicknameTemplate()
+	if template == "" {
+		template = DefaultNicknameTemplate
+	}
+	return guild.text("reply.nicknamesOn", template, formatNickname(template, "Bob", "cyan", "bob123"))
+}
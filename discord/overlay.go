@@ -0,0 +1,349 @@
+package discord
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/assets"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/logger"
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+const (
+	// MaxOverlayStreams is how many overlays can be streaming a guild's games at once
+	MaxOverlayStreams = 20
+	// OverlayHeartbeat is how often idle streams are sent a comment, so proxies don't close them
+	OverlayHeartbeat = 15 * time.Second
+)
+
+//publicURL is where the bot's HTTP server can be reached, for the overlay links it hands out
+var publicURL = ""
+
+// OverlayStreams wakes a guild's overlay streams when something they show might have changed
+type OverlayStreams struct {
+	subscribers map[chan struct{}]bool
+	lock        sync.Mutex
+}
+
+func MakeOverlayStreams() *OverlayStreams {
+	return &OverlayStreams{
+		subscribers: map[chan struct{}]bool{},
+		lock:        sync.Mutex{},
+	}
+}
+
+func (ovs *OverlayStreams) subscribe() (chan struct{}, bool) {
+	ovs.lock.Lock()
+	defer ovs.lock.Unlock()
+	if len(ovs.subscribers) >= MaxOverlayStreams {
+		return nil, false
+	}
+	ch := make(chan struct{}, 1)
+	ovs.subscribers[ch] = true
+	overlayStreams.Add(1)
+	return ch, true
+}
+
+func (ovs *OverlayStreams) unsubscribe(ch chan struct{}) {
+	ovs.lock.Lock()
+	defer ovs.lock.Unlock()
+	if ovs.subscribers[ch] {
+		delete(ovs.subscribers, ch)
+		overlayStreams.Add(-1)
+	}
+}
+
+// Notify wakes every stream. It never blocks
+func (ovs *OverlayStreams) Notify() {
+	ovs.lock.Lock()
+	defer ovs.lock.Unlock()
+	for ch := range ovs.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			//the stream hasn't caught up with the last change yet; it'll see this one too
+		}
+	}
+}
+
+type overlayPlayer struct {
+	Color string `json:"color"`
+	Hex   string `json:"hex"`
+	//anything the disclosure policy hides is left out, rather than sent as false
+	Name     string `json:"name,omitempty"`
+	Alive    *bool  `json:"alive,omitempty"`
+	UserName string `json:"userName,omitempty"`
+	Muted    *bool  `json:"muted,omitempty"`
+	Deafened *bool  `json:"deafened,omitempty"`
+}
+
+type overlayGame struct {
+	ID            string `json:"id"`
+	Phase         string `json:"phase"`
+	CaptureLinked bool   `json:"captureLinked"`
+	//players by overlayPlayerKey, so diffs only touch the players that changed
+	Players map[string]overlayPlayer `json:"players"`
+	Deaths  []string                 `json:"deaths,omitempty"`
+}
+
+type overlayState struct {
+	Games map[string]overlayGame `json:"games"`
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+//overlayGameState is what an overlay may show of a game. Room codes are never included, so viewers can't join
+func (guild *GuildState) overlayGameState(s *discordgo.Session, gs *GameState) overlayGame {
+	phase := gs.AmongUsData.GetPhase()
+	disclosure := guild.PersistentGuildData.Disclosure.Get(phase)
+
+	userVoiceStates := map[string]*discordgo.VoiceState{}
+	if g, err := s.State.Guild(guild.PersistentGuildData.GuildID); err == nil {
+		for _, v := range voiceStates(s, g) {
+			userVoiceStates[v.UserID] = v
+		}
+	}
+	linked := guild.UserData.GetLinkedUsers(gs.ID)
+
+	og := overlayGame{
+		ID:            gs.ID,
+		Phase:         string(game.PhaseNames[phase]),
+		CaptureLinked: gs.GetLinkCode() == "",
+		Players:       map[string]overlayPlayer{},
+	}
+	for _, v := range gs.AmongUsData.GetAllPlayers() {
+		color := game.GetColorStringForInt(v.Color)
+		player := overlayPlayer{Color: color}
+		if v.Color >= 0 && v.Color < game.NumColors {
+			player.Hex = fmt.Sprintf("#%06x", game.Palette[v.Color].RGB)
+		}
+		if disclosure.Names {
+			player.Name = v.Name
+		}
+		if disclosure.AliveStatus {
+			player.Alive = boolPtr(v.IsAlive)
+		}
+		if disclosure.LinkState {
+			for _, user := range linked {
+				if user.GetColor() != v.Color || user.GetPlayerName() != v.Name {
+					continue
+				}
+				player.UserName = user.GetUserName()
+				//the bot mutes and deafens the living and the dead differently, so voice states give away who is
+				//dead just as much as showing it would
+				if vs, ok := userVoiceStates[user.GetID()]; ok && disclosure.AliveStatus {
+					player.Muted = boolPtr(vs.Mute || vs.SelfMute)
+					player.Deafened = boolPtr(vs.Deaf || vs.SelfDeaf)
+				}
+				break
+			}
+		}
+		og.Players[overlayPlayerKey(og.Players, color, v.ClientID)] = player
+	}
+	if disclosure.DeathOrder {
+		for _, v := range gs.Deaths.GetAll() {
+			if disclosure.Names {
+				og.Deaths = append(og.Deaths, v.Name)
+			} else {
+				og.Deaths = append(og.Deaths, game.GetColorStringForInt(v.Color))
+			}
+		}
+	}
+	return og
+}
+
+//overlayPlayerKey keys a player by their color and client ID. Lobbies can have two players of the same color for a
+//moment (or for good, with mods), so captures that don't send client IDs get a number after the color instead. Names
+//aren't used, since the key would show them even when the disclosure policy hides them
+func overlayPlayerKey(players map[string]overlayPlayer, color string, clientID int) string {
+	key := color
+	if clientID != 0 {
+		key = color + "-" + strconv.Itoa(clientID)
+	}
+	if _, ok := players[key]; !ok {
+		return key
+	}
+	for i := 2; ; i++ {
+		if _, ok := players[key+"-"+strconv.Itoa(i)]; !ok {
+			return key + "-" + strconv.Itoa(i)
+		}
+	}
+}
+
+//overlayState is what an overlay may show of the guild's games, or of one game if gameID is set
+func (guild *GuildState) overlayState(s *discordgo.Session, gameID string) overlayState {
+	state := overlayState{Games: map[string]overlayGame{}}
+	for _, gs := range guild.Games.All() {
+		if gameID == "" || gs.ID == gameID {
+			state.Games[gs.ID] = guild.overlayGameState(s, gs)
+		}
+	}
+	return state
+}
+
+//toJSONValue turns a value into the maps, slices and values it'd be decoded to from JSON, so it can be diffed
+func toJSONValue(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	json.Unmarshal(b, &out)
+	return out
+}
+
+// mergePatch returns the JSON merge patch (RFC 7386) that turns old into new, and if there's any difference at all
+func mergePatch(old, new interface{}) (interface{}, bool) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if !oldIsMap || !newIsMap {
+		if reflect.DeepEqual(old, new) {
+			return nil, false
+		}
+		return new, true
+	}
+	patch := map[string]interface{}{}
+	for k, ov := range oldMap {
+		nv, ok := newMap[k]
+		if !ok {
+			//null removes the key
+			patch[k] = nil
+			continue
+		}
+		if p, changed := mergePatch(ov, nv); changed {
+			patch[k] = p
+		}
+	}
+	for k, nv := range newMap {
+		if _, ok := oldMap[k]; !ok {
+			patch[k] = nv
+		}
+	}
+	return patch, len(patch) > 0
+}
+
+func writeEvent(w http.ResponseWriter, id int, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, b)
+	return err
+}
+
+// overlayStreamHandler streams the state of a guild's games as server-sent events, for anyone with the guild's
+// overlay token: a "snapshot" event with the whole state, then a "diff" event with a JSON merge patch whenever it
+// changes. Query parameters are guild, token and optionally game
+func overlayStreamHandler(s *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		guild, ok := AllGuilds.Get(query.Get("guild"))
+		//unknown guilds get the same answer as wrong tokens, so guilds can't be discovered
+		if !ok || !guild.checkOverlayToken(query.Get("token")) {
+			writeError(w, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "streaming isn't supported")
+			return
+		}
+		wake, ok := guild.Overlay.subscribe()
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, "too many overlays are streaming this server")
+			return
+		}
+		defer guild.Overlay.unsubscribe(wake)
+		gameID := query.Get("game")
+		streamLog := guild.log().With(logger.Fields{"remote": r.RemoteAddr})
+		streamLog.Debug("Overlay stream opened")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		//stops nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		id := 1
+		last := toJSONValue(guild.overlayState(s, gameID))
+		if writeEvent(w, id, "snapshot", last) != nil {
+			return
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(OverlayHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				streamLog.Debug("Overlay stream closed")
+				return
+			case <-guild.ctx.Done():
+				//the bot was removed from the guild, or discord is having an outage
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-wake:
+				//the token was reset or turned off; anyone still streaming with the old one is cut off
+				if !guild.checkOverlayToken(query.Get("token")) {
+					return
+				}
+				next := toJSONValue(guild.overlayState(s, gameID))
+				patch, changed := mergePatch(last, next)
+				if !changed {
+					break
+				}
+				id++
+				if writeEvent(w, id, "diff", patch) != nil {
+					return
+				}
+				flusher.Flush()
+				last = next
+			}
+		}
+	}
+}
+
+// overlayPageHandler serves the bundled overlay page, which streams from overlayStreamHandler
+func overlayPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(assets.OverlayPage())
+}
+
+func (guild *GuildState) checkOverlayToken(token string) bool {
+	expected := guild.PersistentGuildData.GetOverlayToken()
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+//overlayURL is the link to the guild's overlay, with its token
+func (guild *GuildState) overlayURL() string {
+	query := url.Values{}
+	query.Set("guild", guild.PersistentGuildData.GuildID)
+	query.Set("token", guild.PersistentGuildData.GetOverlayToken())
+	return publicURL + "/overlay/?" + query.Encode()
+}
+
+//resetOverlayToken replaces the guild's overlay token, which cuts off any overlays using the old one
+func (guild *GuildState) resetOverlayToken() error {
+	token, err := webhook.NewSecret()
+	if err != nil {
+		return err
+	}
+	guild.PersistentGuildData.SetOverlayToken(token)
+	guild.Overlay.Notify()
+	return guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+}
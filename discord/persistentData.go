@@ -2,9 +2,13 @@ package discord
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/locale"
 )
 
 type PersistentGuildData struct {
@@ -18,22 +22,223 @@ type PersistentGuildData struct {
 	Delays              GameDelays `json:"delays"`
 	VoiceRules          VoiceRules `json:"voiceRules"`
 	ApplyNicknames      bool       `json:"applyNicknames"`
+	NicknameTemplate    string     `json:"nicknameTemplate"`
+
+	//EmojiHashes are the hashes of the images the bot's emojis were uploaded from, by emoji name, so emojis whose
+	//image has changed since can be uploaded again
+	EmojiHashes map[string]string `json:"emojiHashes"`
+
+	//Disclosure controls what the status message may reveal in each phase
+	Disclosure DisclosurePolicy `json:"disclosure"`
+
+	//Language is the code of the language the bot uses for the guild, like "en"
+	Language string `json:"language"`
+
+	//EmbedTemplates customize the status message of games
+	EmbedTemplates EmbedTemplates `json:"embedTemplates"`
+
+	//VoicePresets are named voice rules, which can be assigned to voice channels
+	VoicePresets VoicePresets `json:"voicePresets"`
+
+	//VoicePriorities is the order, and timing, that voice changes are applied in for each phase transition
+	VoicePriorities VoicePriorities `json:"voicePriorities"`
+
+	//ReconcileIntervalSeconds is how often voice states are checked and corrected. 0 uses the default, < 0 disables it
+	ReconcileIntervalSeconds int `json:"reconcileIntervalSeconds"`
 
+	//DryRun computes voice/nickname changes and posts them to DryRunChannelID instead of applying them
+	DryRun          bool   `json:"dryRun"`
+	DryRunChannelID string `json:"dryRunChannelID"`
+
+	//AuditChannelID is where a record of games, phases, links and failed mutes is posted. Empty disables it
+	AuditChannelID string `json:"auditChannelID"`
+
+	//Webhooks are sent the guild's events
+	Webhooks []Webhook `json:"webhooks"`
+
+	//OverlayToken lets stream overlays watch the guild's games. Empty disables the overlay
+	OverlayToken string `json:"overlayToken"`
+
+	//RemovedAt is when the bot was removed from the guild, so its data can be purged later. Nil while it's in the guild
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+
+	//lock guards the settings commands can change while the guild is running; the voice presets, priorities,
+	//disclosure policy and embed templates have locks of their own
 	lock sync.RWMutex
 }
 
 func PGDDefault(id string) *PersistentGuildData {
 	return &PersistentGuildData{
-		GuildID:               id,
-		CommandPrefix:         ".au",
-		DefaultTrackedChannel: "",
-		AdminUserIDs:          nil,
-		PermissionedRoleIDs:   nil,
-		Delays:                MakeDefaultDelays(),
-		VoiceRules:            MakeMuteAndDeafenRules(),
-		ApplyNicknames:        false,
-		lock:                  sync.RWMutex{},
+		GuildID:                  id,
+		CommandPrefix:            ".au",
+		DefaultTrackedChannel:    "",
+		AdminUserIDs:             nil,
+		PermissionedRoleIDs:      nil,
+		Delays:                   MakeDefaultDelays(),
+		VoiceRules:               MakeMuteAndDeafenRules(),
+		VoicePriorities:          MakeDefaultVoicePriorities(),
+		VoicePresets:             MakeVoicePresets(),
+		EmbedTemplates:           MakeDefaultEmbedTemplates(),
+		Language:                 locale.DefaultLanguage,
+		Disclosure:               MakeDefaultDisclosurePolicy(),
+		EmojiHashes:              map[string]string{},
+		ApplyNicknames:           false,
+		NicknameTemplate:         DefaultNicknameTemplate,
+		ReconcileIntervalSeconds: 0,
+		DryRun:                   false,
+		DryRunChannelID:          "",
+		AuditChannelID:           "",
+		Webhooks:                 []Webhook{},
+		OverlayToken:             "",
+		RemovedAt:                nil,
+		lock:                     sync.RWMutex{},
+	}
+}
+
+// GetLanguage returns the language of the guild, or the default language if it isn't set or isn't supported
+func (pgd *PersistentGuildData) GetLanguage() string {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	if locale.IsSupported(pgd.Language) {
+		return pgd.Language
 	}
+	return locale.DefaultLanguage
+}
+
+func (pgd *PersistentGuildData) SetLanguage(lang string) {
+	pgd.lock.Lock()
+	pgd.Language = lang
+	pgd.lock.Unlock()
+}
+
+// IsDryRun is true if voice and nickname changes are posted instead of applied
+func (pgd *PersistentGuildData) IsDryRun() bool {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.DryRun
+}
+
+// GetDryRunChannelID returns where dry run reports are posted
+func (pgd *PersistentGuildData) GetDryRunChannelID() string {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.DryRunChannelID
+}
+
+// SetDryRun turns dry runs on or off. The channel is only changed when turning them on
+func (pgd *PersistentGuildData) SetDryRun(on bool, channelID string) {
+	pgd.lock.Lock()
+	pgd.DryRun = on
+	if on {
+		pgd.DryRunChannelID = channelID
+	}
+	pgd.lock.Unlock()
+}
+
+// GetAuditChannelID returns where the audit log is posted, or "" if it's off
+func (pgd *PersistentGuildData) GetAuditChannelID() string {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.AuditChannelID
+}
+
+func (pgd *PersistentGuildData) SetAuditChannelID(channelID string) {
+	pgd.lock.Lock()
+	pgd.AuditChannelID = channelID
+	pgd.lock.Unlock()
+}
+
+// IsApplyingNicknames is true if linked members are renamed to their in-game names
+func (pgd *PersistentGuildData) IsApplyingNicknames() bool {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.ApplyNicknames
+}
+
+// GetNicknameTemplate returns the template linked members are renamed with
+func (pgd *PersistentGuildData) GetNicknameTemplate() string {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.NicknameTemplate
+}
+
+func (pgd *PersistentGuildData) SetApplyNicknames(apply bool) {
+	pgd.lock.Lock()
+	pgd.ApplyNicknames = apply
+	pgd.lock.Unlock()
+}
+
+func (pgd *PersistentGuildData) SetNicknameTemplate(template string) {
+	pgd.lock.Lock()
+	pgd.NicknameTemplate = template
+	pgd.lock.Unlock()
+}
+
+// GetOverlayToken returns the token overlays need, or "" if the overlay is off
+func (pgd *PersistentGuildData) GetOverlayToken() string {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.OverlayToken
+}
+
+func (pgd *PersistentGuildData) SetOverlayToken(token string) {
+	pgd.lock.Lock()
+	pgd.OverlayToken = token
+	pgd.lock.Unlock()
+}
+
+// GetWebhooks returns a copy of the guild's webhooks
+func (pgd *PersistentGuildData) GetWebhooks() []Webhook {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return append([]Webhook{}, pgd.Webhooks...)
+}
+
+func (pgd *PersistentGuildData) SetWebhooks(hooks []Webhook) {
+	pgd.lock.Lock()
+	pgd.Webhooks = hooks
+	pgd.lock.Unlock()
+}
+
+// GetEmojiHash returns the hash of the image an emoji was uploaded from
+func (pgd *PersistentGuildData) GetEmojiHash(name string) (string, bool) {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	hash, ok := pgd.EmojiHashes[name]
+	return hash, ok
+}
+
+func (pgd *PersistentGuildData) SetEmojiHash(name, hash string) {
+	pgd.lock.Lock()
+	if pgd.EmojiHashes == nil {
+		pgd.EmojiHashes = map[string]string{}
+	}
+	pgd.EmojiHashes[name] = hash
+	pgd.lock.Unlock()
+}
+
+func (pgd *PersistentGuildData) DeleteEmojiHash(name string) {
+	pgd.lock.Lock()
+	delete(pgd.EmojiHashes, name)
+	pgd.lock.Unlock()
+}
+
+// GetRemovedAt returns when the bot was removed from the guild, or nil if it's in the guild
+func (pgd *PersistentGuildData) GetRemovedAt() *time.Time {
+	pgd.lock.RLock()
+	defer pgd.lock.RUnlock()
+	return pgd.RemovedAt
+}
+
+func (pgd *PersistentGuildData) SetRemovedAt(removedAt *time.Time) {
+	pgd.lock.Lock()
+	pgd.RemovedAt = removedAt
+	pgd.lock.Unlock()
+}
+
+// ConfigFilename returns the filename the config for a guild is stored in
+func ConfigFilename(guildID string) string {
+	return fmt.Sprintf("%s_config.json", guildID)
 }
 
 func (pgd *PersistentGuildData) ToFile(filename string) error {
@@ -42,7 +247,9 @@ func (pgd *PersistentGuildData) ToFile(filename string) error {
 		return err
 	}
 	defer file.Close()
+	pgd.lock.RLock()
 	jsonBytes, err := json.MarshalIndent(pgd, "", "    ")
+	pgd.lock.RUnlock()
 	if err != nil {
 		return err
 	}
@@ -62,7 +269,13 @@ func LoadPGDFromFile(filename string) (*PersistentGuildData, error) {
 		return nil, err
 	}
 
-	pgd := PersistentGuildData{}
+	pgd := PersistentGuildData{
+		VoicePriorities: MakeDefaultVoicePriorities(),
+		VoicePresets:    MakeVoicePresets(),
+		EmbedTemplates:  MakeDefaultEmbedTemplates(),
+		Disclosure:      MakeDefaultDisclosurePolicy(),
+		EmojiHashes:     map[string]string{},
+	}
 	err = json.Unmarshal(jsonBytes, &pgd)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,164 @@
+package discord
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// DefaultReconcileInterval is how often discord's voice state is compared to what we want, when not configured
+const DefaultReconcileInterval = 15 * time.Second
+
+// NicknameCheckRuns is how many reconcile runs pass between asking discord for linked members' current nicknames.
+// Member updates aren't sent to us without the privileged members intent, so this is how renames by the members themselves get noticed
+const NicknameCheckRuns = 4
+
+// PendingVoiceUpdateTimeout is how long we wait on a patch to show up in discord's state before issuing it again
+const PendingVoiceUpdateTimeout = 10 * time.Second
+
+// DriftStats counts how often discord's voice state didn't match what we wanted it to be
+type DriftStats struct {
+	Runs         int64
+	LastRun      time.Time
+	LastDrifted  int
+	TotalDrifted int64
+	TotalTimeout int64
+
+	lock sync.RWMutex
+}
+
+func (ds *DriftStats) record(drifted, timedOut int) {
+	ds.lock.Lock()
+	ds.Runs++
+	ds.LastRun = time.Now()
+	ds.LastDrifted = drifted
+	ds.TotalDrifted += int64(drifted)
+	ds.TotalTimeout += int64(timedOut)
+	ds.lock.Unlock()
+}
+
+//nicknameCheckDue reports if the run about to happen should also check members' nicknames against discord
+func (ds *DriftStats) nicknameCheckDue() bool {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+	return ds.Runs%NicknameCheckRuns == 0
+}
+
+// Snapshot returns a copy of the stats that's safe to read
+func (ds *DriftStats) Snapshot() (runs int64, lastRun time.Time, lastDrifted int, totalDrifted, totalTimeout int64) {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+	return ds.Runs, ds.LastRun, ds.LastDrifted, ds.TotalDrifted, ds.TotalTimeout
+}
+
+func (ds *DriftStats) ToStatusString(lang string) string {
+	runs, lastRun, lastDrifted, totalDrifted, totalTimeout := ds.Snapshot()
+	if runs == 0 {
+		return locale.Get(lang, "reply.driftNotRun")
+	}
+	return locale.Get(lang, "reply.drift", time.Since(lastRun).Round(time.Second), lastDrifted, runs, totalDrifted, totalTimeout)
+}
+
+// GetReconcileInterval returns how often to reconcile, or 0 if the reconciler is disabled
+func (pgd *PersistentGuildData) GetReconcileInterval() time.Duration {
+	if pgd.ReconcileIntervalSeconds < 0 {
+		return 0
+	}
+	if pgd.ReconcileIntervalSeconds == 0 {
+		return DefaultReconcileInterval
+	}
+	return time.Duration(pgd.ReconcileIntervalSeconds) * time.Second
+}
+
+//reconcileLoop periodically converges discord's voice state to the state we want
+func (guild *GuildState) reconcileLoop(s *discordgo.Session) {
+	for {
+		interval := guild.PersistentGuildData.GetReconcileInterval()
+		if interval == 0 {
+			if !sleepContext(guild.ctx, DefaultReconcileInterval) {
+				return
+			}
+			continue
+		}
+		if !sleepContext(guild.ctx, interval) {
+			return
+		}
+		guild.reconcile(s)
+	}
+}
+
+//reconcile re-issues patches for any members whose voice state is out of date, and returns how many it found
+func (guild *GuildState) reconcile(s *discordgo.Session) int {
+	//dry runs never change anything
+	if guild.PersistentGuildData.IsDryRun() {
+		return 0
+	}
+	if _, err := s.State.Guild(guild.PersistentGuildData.GuildID); err != nil {
+		guild.log().Warn(err)
+		return 0
+	}
+	//clears the pending flag on any updates that have already gone through
+	g := guild.verifyVoiceStateChanges(s)
+
+	checkNicknames := guild.PersistentGuildData.IsApplyingNicknames() && guild.Drift.nicknameCheckDue()
+
+	drifted := 0
+	timedOut := 0
+	for _, voiceState := range voiceStates(s, g) {
+		userData, err := guild.UserData.GetUser(voiceState.UserID)
+		if err != nil {
+			continue
+		}
+
+		if !userData.IsLinked() {
+			//anyone unlinked should be back how they were before we touched them
+			if member, ok := guild.ModifiedMembers.Get(voiceState.UserID); ok && (voiceState.Mute || voiceState.Deaf || member.NickChanged) {
+				drifted++
+				go guild.restoreMember(s, member, true)
+			}
+			continue
+		}
+		//we don't want to "fix" members that a transition is deliberately delaying
+		if gs := guild.Games.Get(userData.GetGameID()); gs != nil && atomic.LoadInt32(&gs.transitionsInProgress) > 0 {
+			continue
+		}
+
+		mute, deaf := guild.desiredVoiceState(userData, voiceState.ChannelID)
+		nick := guild.nicknameFor(s, userData)
+		if checkNicknames && nick != "" && nick == userData.GetNickName() {
+			//the member may have renamed themselves since we last set it
+			if member, err := s.GuildMember(guild.PersistentGuildData.GuildID, voiceState.UserID); err != nil {
+				guild.log().With(logger.Fields{logger.UserKey: voiceState.UserID}).Warn(err)
+			} else if member.Nick != nick {
+				guild.UserData.UpdateNickName(voiceState.UserID, member.Nick)
+				userData.SetNickName(member.Nick)
+			}
+		}
+		if mute == voiceState.Mute && deaf == voiceState.Deaf && (nick == "" || nick == userData.GetNickName()) {
+			continue
+		}
+		if userData.IsPendingVoiceUpdate() {
+			if !userData.IsPendingVoiceUpdateTimedOut(PendingVoiceUpdateTimeout) {
+				continue
+			}
+			guild.log().With(logger.Fields{logger.UserKey: voiceState.UserID}).Warn("Voice update timed out; issuing it again")
+			timedOut++
+			voicePatches.Inc("retried")
+		}
+		drifted++
+
+		guild.UserData.SetPendingVoiceUpdate(voiceState.UserID, true)
+
+		go guild.memberUpdate(s, UserPatchParameters{guild.PersistentGuildData.GuildID, voiceState.UserID, deaf, mute, nick})
+	}
+
+	if drifted > 0 {
+		guild.log().Infof("Reconciled %d member(s)", drifted)
+	}
+	guild.Drift.record(drifted, timedOut)
+	return drifted
+}
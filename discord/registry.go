@@ -0,0 +1,115 @@
+package discord
+
+import "sync"
+
+// GuildRegistry holds the state of every guild this process runs. Guilds are only added and removed by the discordgo
+// guild handlers; everything else just looks them up
+type GuildRegistry struct {
+	guilds map[string]*GuildState
+	lock   sync.RWMutex
+}
+
+func MakeGuildRegistry() *GuildRegistry {
+	return &GuildRegistry{
+		guilds: map[string]*GuildState{},
+		lock:   sync.RWMutex{},
+	}
+}
+
+func (gr *GuildRegistry) Get(guildID string) (*GuildState, bool) {
+	gr.lock.RLock()
+	defer gr.lock.RUnlock()
+	guild, ok := gr.guilds[guildID]
+	return guild, ok
+}
+
+// Add adds a guild, and is false if there already was one with the ID, which is kept
+func (gr *GuildRegistry) Add(guildID string, guild *GuildState) bool {
+	gr.lock.Lock()
+	defer gr.lock.Unlock()
+	if _, ok := gr.guilds[guildID]; ok {
+		return false
+	}
+	gr.guilds[guildID] = guild
+	return true
+}
+
+// Remove removes a guild, and returns it if there was one
+func (gr *GuildRegistry) Remove(guildID string) (*GuildState, bool) {
+	gr.lock.Lock()
+	defer gr.lock.Unlock()
+	guild, ok := gr.guilds[guildID]
+	delete(gr.guilds, guildID)
+	return guild, ok
+}
+
+// All returns every guild. Guilds added or removed afterwards aren't reflected in it
+func (gr *GuildRegistry) All() []*GuildState {
+	gr.lock.RLock()
+	defer gr.lock.RUnlock()
+	all := make([]*GuildState, 0, len(gr.guilds))
+	for _, guild := range gr.guilds {
+		all = append(all, guild)
+	}
+	return all
+}
+
+func (gr *GuildRegistry) Len() int {
+	gr.lock.RLock()
+	defer gr.lock.RUnlock()
+	return len(gr.guilds)
+}
+
+// CaptureRegistry holds which game each capture is linked to. Only handleCaptureMessage changes it, as captures link
+// and disconnect, and a guild's teardown
+type CaptureRegistry struct {
+	conns map[string]GameKey
+	lock  sync.RWMutex
+}
+
+func MakeCaptureRegistry() *CaptureRegistry {
+	return &CaptureRegistry{
+		conns: map[string]GameKey{},
+		lock:  sync.RWMutex{},
+	}
+}
+
+// Get returns the game a capture is linked to
+func (cr *CaptureRegistry) Get(captureID string) (GameKey, bool) {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	key, ok := cr.conns[captureID]
+	return key, ok && key.GuildID != ""
+}
+
+// Link links a capture to a game, and returns the game it was linked to before, if any
+func (cr *CaptureRegistry) Link(captureID string, key GameKey) (GameKey, bool) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	previous, ok := cr.conns[captureID]
+	cr.conns[captureID] = key
+	return previous, ok && previous.GuildID != ""
+}
+
+// Unlink forgets a capture, and returns the game it was linked to, if any
+func (cr *CaptureRegistry) Unlink(captureID string) (GameKey, bool) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	previous, ok := cr.conns[captureID]
+	delete(cr.conns, captureID)
+	return previous, ok && previous.GuildID != ""
+}
+
+// UnlinkGuild forgets every capture linked to the guild's games, and returns how many there were
+func (cr *CaptureRegistry) UnlinkGuild(guildID string) int {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	unlinked := 0
+	for captureID, key := range cr.conns {
+		if key.GuildID == guildID {
+			delete(cr.conns, captureID)
+			unlinked++
+		}
+	}
+	return unlinked
+}
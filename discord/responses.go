@@ -4,85 +4,116 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/logger"
+	"github.com/denverquane/amongusdiscord/locale"
 )
 
-func helpResponse(CommandPrefix string) string {
+// helpMessageIDs are the lines of the help message, in order
+var helpMessageIDs = []string{
+	"help.header", "help.support", "help.help", "help.new", "help.refresh", "help.end", "help.track", "help.link",
+	"help.unlink", "help.force", "help.nickname", "help.priority", "help.drift", "help.dryrun", "help.audit", "help.webhook", "help.overlay", "help.games",
+	"help.rules", "help.preset", "help.template", "help.language", "help.spoilers", "help.emojis",
+	"help.gameID",
+}
+
+func helpResponse(lang, CommandPrefix string) string {
 	buf := bytes.NewBuffer([]byte{})
-	buf.WriteString("Among Us Bot command reference:\n")
-	buf.WriteString("Having issues or have suggestions? Join the discord at <https://discord.gg/ZkqZSWF>!\n")
-	buf.WriteString(fmt.Sprintf("`%s help` or `%s h`: Print help info and command usage.\n", CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s new` or `%s n`: Start the game in this text channel. Accepts room code and region as arguments. Ex: `%s new CODE eu`. Also works for restarting.\n", CommandPrefix, CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s refresh` or `%s r`: Remake the bot's status message entirely, in case it ends up too far up in the chat.\n", CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s end` or `%s e`: End the game entirely, and stop tracking players. Unmutes all and resets state.\n", CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s track` or `%s t`: Instruct bot to only use the provided voice channel for automute. Ex: `%s t <vc_name>`\n", CommandPrefix, CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s link` or `%s l`: Manually link a player to their in-game name or color. Ex: `%s l @player cyan` or `%s l @player bob`\n", CommandPrefix, CommandPrefix, CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s unlink` or `%s u`: Manually unlink a player. Ex: `%s u @player`\n", CommandPrefix, CommandPrefix, CommandPrefix))
-	buf.WriteString(fmt.Sprintf("`%s force` or `%s f`: Force a transition to a stage if you encounter a problem in the state. Ex: `%s f task` or `%s f d`(discuss)\n", CommandPrefix, CommandPrefix, CommandPrefix, CommandPrefix))
+	for _, id := range helpMessageIDs {
+		if id == "help.header" || id == "help.support" {
+			buf.WriteString(locale.Get(lang, id) + "\n")
+		} else {
+			buf.WriteString(locale.Get(lang, id, CommandPrefix) + "\n")
+		}
+	}
 
 	return buf.String()
 }
 
-func (guild *GuildState) trackChannelResponse(channelName string, allChannels []*discordgo.Channel, forGhosts bool) string {
+// text returns a message in the language of the guild
+func (guild *GuildState) text(id string, args ...interface{}) string {
+	return locale.Get(guild.PersistentGuildData.GetLanguage(), id, args...)
+}
+
+// errorText returns the message of an error in the language of the guild
+func (guild *GuildState) errorText(err error) string {
+	return locale.ErrorText(guild.PersistentGuildData.GetLanguage(), err)
+}
+
+func (guild *GuildState) trackChannelResponse(gs *GameState, channelName string, allChannels []*discordgo.Channel, forGhosts bool) string {
 	for _, c := range allChannels {
 		if (strings.ToLower(c.Name) == strings.ToLower(channelName) || c.ID == channelName) && c.Type == 2 {
 
-			guild.Tracking.AddTrackedChannel(c.ID, c.Name, forGhosts)
+			if other := guild.Games.FindByTrackedChannel(c.ID); other != nil && other != gs {
+				return guild.text("reply.trackAlreadyTaken", c.Name, other.ID)
+			}
+			gs.Tracking.AddTrackedChannel(c.ID, c.Name, forGhosts)
 
-			log.Println(fmt.Sprintf("Now tracking \"%s\" Voice Channel for Automute (for ghosts? %v)!", c.Name, forGhosts))
-			return fmt.Sprintf("Now tracking \"%s\" Voice Channel for Automute (for ghosts? %v)!", c.Name, forGhosts)
+			guild.gameLog(gs).Infof("Now tracking \"%s\" Voice Channel for Automute (for ghosts? %v)!", c.Name, forGhosts)
+			return guild.text("reply.trackNow", c.Name, forGhosts)
 		}
 	}
-	return fmt.Sprintf("No channel found by the name %s!\n", channelName)
+	return guild.text("reply.trackNotFound", channelName)
 }
 
-func (guild *GuildState) linkPlayerResponse(args []string) {
+// linkPlayerResponse links a user to a player by color or name, and returns what went wrong, if anything
+func (guild *GuildState) linkPlayerResponse(gs *GameState, args []string, by Trigger) string {
 
 	userID, err := extractUserIDFromMention(args[0])
 	if err != nil {
-		log.Printf("Invalid mention format for \"%s\"", args[0])
+		guild.log().Warnf("Invalid mention format for \"%s\"", args[0])
 	}
 
 	combinedArgs := strings.ToLower(strings.Join(args[1:], ""))
 
-	if game.IsColorString(combinedArgs) {
-		playerData := guild.AmongUsData.GetByColor(combinedArgs)
-		if playerData != nil {
-			found := guild.UserData.UpdatePlayerData(userID, playerData)
-			if found {
-				log.Printf("Successfully linked %s to a color\n", userID)
-			} else {
-				log.Printf("No player was found with id %s\n", userID)
-			}
-		}
-		return
+	var playerData *game.PlayerData
+	//colors can be given in the guild's language, or in english
+	if color, ok := locale.ParseColor(guild.PersistentGuildData.GetLanguage(), combinedArgs); ok {
+		playerData = gs.AmongUsData.GetByColor(game.GetColorStringForInt(color))
 	} else {
-		playerData := guild.AmongUsData.GetByName(combinedArgs)
-		if playerData != nil {
-			found := guild.UserData.UpdatePlayerData(userID, playerData)
-			if found {
-				log.Printf("Successfully linked %s by name\n", userID)
-			} else {
-				log.Printf("No player was found with id %s\n", userID)
-			}
+		players := gs.AmongUsData.GetAllByName(combinedArgs)
+		if len(players) > 1 {
+			return guild.text("reply.ambiguousName", strings.Join(args[1:], " "))
 		}
+		if len(players) == 1 {
+			playerData = players[0]
+		}
+	}
+	if playerData == nil {
+		return ""
+	}
+
+	err = guild.UserData.UpdatePlayerData(userID, gs.ID, playerData)
+	if linked, ok := err.(ErrAlreadyLinked); ok {
+		return guild.text("reply.alreadyLinked", linked.UserID, guild.PersistentGuildData.CommandPrefix)
 	}
+	if err != nil {
+		guild.gameLog(gs).Error(err)
+	} else {
+		linked := playerData.Snapshot()
+		guild.gameLog(gs).With(logger.Fields{logger.UserKey: userID}).Infof("Successfully linked to %s", linked.ToString())
+		guild.publish(linkEvent(gs, userID, playerData, by))
+	}
+	return ""
 }
 
-// TODO:
-func gameStateResponse(guild *GuildState) *discordgo.MessageEmbed {
-	// we need to generate the messages based on the state of the game
-	messages := map[game.Phase]func(guild *GuildState) *discordgo.MessageEmbed{
-		game.LOBBY:   lobbyMessage,
-		game.TASKS:   gamePlayMessage,
-		game.DISCUSS: gamePlayMessage,
+// gameStateResponse renders the status message of a game from the guild's templates
+func gameStateResponse(guild *GuildState, gs *GameState) *discordgo.MessageEmbed {
+	lang := guild.PersistentGuildData.GetLanguage()
+	phase := gs.AmongUsData.GetPhase()
+	data := guild.makeEmbedTemplateData(gs)
+	msg, err := guild.PersistentGuildData.EmbedTemplates.Render(lang, phase, data)
+	if err != nil {
+		//a broken template shouldn't break the status message; fall back to the defaults
+		guild.gameLog(gs).Errorf("Error rendering status message template: %s", err)
+		defaults := MakeDefaultEmbedTemplates()
+		msg, _ = defaults.Render(lang, phase, data)
 	}
-	return messages[guild.AmongUsData.GetPhase()](guild)
+	return msg
 }
 
 
@@ -97,33 +128,78 @@ func gameStateResponse(guild *GuildState) *discordgo.MessageEmbed {
 //
 //const PaddedLen = 20
 
-func lobbyMetaEmbedFields(tracking *Tracking, room, region string, playerCount int, linkedPlayers int) []*discordgo.MessageEmbedField {
-	str := tracking.ToStatusString()
+func lobbyMetaEmbedFields(lang string, tracking *Tracking, room, region string, playerCount int, linkedPlayers int) []*discordgo.MessageEmbedField {
+	str := tracking.ToStatusString(lang)
 	gameInfoFields := make([]*discordgo.MessageEmbedField, 4)
 	gameInfoFields[0] = &discordgo.MessageEmbedField{
-		Name:   "Room Code",
+		Name:   locale.Get(lang, "field.room"),
 		Value:  fmt.Sprintf("%s", room),
 		Inline: true,
 	}
 	gameInfoFields[1] = &discordgo.MessageEmbedField{
-		Name:   "Region",
+		Name:   locale.Get(lang, "field.region"),
 		Value:  fmt.Sprintf("%s", region),
 		Inline: true,
 	}
 	gameInfoFields[2] = &discordgo.MessageEmbedField{
-		Name:   "Tracking",
+		Name:   locale.Get(lang, "field.tracking"),
 		Value:  str,
 		Inline: true,
 	}
+	linkedStr := fmt.Sprintf("%v/%v", linkedPlayers, playerCount)
+	if linkedPlayers < 0 {
+		//the disclosure policy hides how many players are linked
+		linkedStr = fmt.Sprintf("?/%v", playerCount)
+	}
 	gameInfoFields[3] = &discordgo.MessageEmbedField{
-		Name:   "Players Linked",
-		Value:  fmt.Sprintf("%v/%v", linkedPlayers, playerCount),
+		Name:   locale.Get(lang, "field.linked"),
+		Value:  linkedStr,
 		Inline: false,
 	}
 
 	return gameInfoFields
 }
 
+func appendCountdownField(lang string, fields []*discordgo.MessageEmbedField, countdown *VoiceCountdown) []*discordgo.MessageEmbedField {
+	str := countdown.ToStatusString(lang)
+	if str == "" {
+		return fields
+	}
+	return append(fields, &discordgo.MessageEmbedField{
+		Name:   locale.Get(lang, "field.voiceChanges"),
+		Value:  str,
+		Inline: false,
+	})
+}
+
+func appendDeathsField(lang string, fields []*discordgo.MessageEmbedField, deaths []string) []*discordgo.MessageEmbedField {
+	if len(deaths) == 0 {
+		return fields
+	}
+	return append(fields, &discordgo.MessageEmbedField{
+		Name:   locale.Get(lang, "field.deaths"),
+		Value:  strings.Join(deaths, " → "),
+		Inline: false,
+	})
+}
+
+func appendVoiceRulesField(fields []*discordgo.MessageEmbedField, guild *GuildState, gs *GameState) []*discordgo.MessageEmbedField {
+	return append(fields, &discordgo.MessageEmbedField{
+		Name:   guild.text("field.voiceRules"),
+		Value:  guild.voiceRulesStatusString(gs),
+		Inline: true,
+	})
+}
+
+func (guild *GuildState) rulesResponse(gs *GameState) string {
+	rules := gs.GetVoiceRules()
+	return guild.text("reply.rules", gs.ID, gs.GetVoicePresetName(), rules.ToString(guild.PersistentGuildData.GetLanguage()))
+}
+
+func (guild *GuildState) priorityResponse() string {
+	return guild.text("reply.priorities") + "\n" + guild.PersistentGuildData.VoicePriorities.ToStatusString(guild.PersistentGuildData.GetLanguage())
+}
+
 // Thumbnail for the bot
 var Thumbnail = discordgo.MessageEmbedThumbnail{
 	URL:      "https://github.com/denverquane/amongusdiscord/blob/master/assets/botProfilePicture.jpg?raw=true",
@@ -132,107 +208,6 @@ var Thumbnail = discordgo.MessageEmbedThumbnail{
 	Height:   200,
 }
 
-func lobbyMessage(g *GuildState) *discordgo.MessageEmbed {
-	//buf.WriteString("Lobby is open!\n")
-	//if g.LinkCode != "" {
-	//	alarmFormatted := ":x:"
-	//	if v, ok := g.SpecialEmojis["alarm"]; ok {
-	//		alarmFormatted = v.FormatForInline()
-	//	}
-	//
-	//	buf.WriteString(fmt.Sprintf("%s **No capture is linked! Use the guildID %s to connect!** %s\n", alarmFormatted, g.LinkCode, alarmFormatted))
-	//}
-	//buf.WriteString(fmt.Sprintf("\n%s %s\n", padToLength("room Code", PaddedLen), padToLength("region", PaddedLen))) // maybe this is a toggle?
-	//uf.WriteString(fmt.Sprintf("**%s** **%s**\n", padToLength(g.room, PaddedLen), padToLength(g.region, PaddedLen)))
-
-	//gameInfoFields[2] = &discordgo.MessageEmbedField{
-	//	Name:   "\u200B",
-	//	Value:  "\u200B",
-	//	Inline: false,
-	//}
-	room, region := g.AmongUsData.GetRoomRegion()
-	gameInfoFields := lobbyMetaEmbedFields(&g.Tracking, room, region, g.AmongUsData.NumDetectedPlayers(), g.UserData.GetCountLinked())
-
-	listResp := g.UserData.ToEmojiEmbedFields(g.StatusEmojis)
-	listResp = append(gameInfoFields, listResp...)
-	//if len(listResp) > 0 {
-	//	buf.WriteString(fmt.Sprintf("\nTracked Player List:\n"))
-	//	buf.WriteString(listResp)
-	//}
-
-	alarmFormatted := ":x:"
-	if v, ok := g.SpecialEmojis["alarm"]; ok {
-		alarmFormatted = v.FormatForInline()
-	}
-	desc := ""
-	if g.LinkCode == "" {
-		desc = "Successfully linked to capture!"
-	} else {
-		desc = fmt.Sprintf("%s**No capture linked! Enter the code `%s` in your capture to connect!**%s", alarmFormatted, g.LinkCode, alarmFormatted)
-	}
-
-	msg := discordgo.MessageEmbed{
-		URL:         "",
-		Type:        "",
-		Title:       "Lobby is Open!",
-		Description: desc,
-		Timestamp:   "",
-		Footer: &discordgo.MessageEmbedFooter{
-			Text:         "React to this message with your in-game color! (or ❌ to leave)",
-			IconURL:      "",
-			ProxyIconURL: "",
-		},
-		Color:     3066993, //GREEN
-		Image:     nil,
-		Thumbnail: nil,
-		Video:     nil,
-		Provider:  nil,
-		Author:    nil,
-		Fields:    listResp,
-	}
-	return &msg
-}
-
-func gamePlayMessage(guild *GuildState) *discordgo.MessageEmbed {
-	// add the player list
-	//guild.UserDataLock.Lock()
-	room, region := guild.AmongUsData.GetRoomRegion()
-	gameInfoFields := lobbyMetaEmbedFields(&guild.Tracking, room, region, guild.AmongUsData.NumDetectedPlayers(), guild.UserData.GetCountLinked())
-	listResp := guild.UserData.ToEmojiEmbedFields(guild.StatusEmojis)
-	listResp = append(gameInfoFields, listResp...)
-	//guild.UserDataLock.Unlock()
-	var color int
-
-	phase := guild.AmongUsData.GetPhase()
-
-	switch phase {
-	case game.TASKS:
-		color = 3447003 //BLUE
-	case game.DISCUSS:
-		color = 10181046 //PURPLE
-	default:
-		color = 15158332 //RED
-	}
-
-	msg := discordgo.MessageEmbed{
-		URL:         "",
-		Type:        "",
-		Title:       "Game is Running",
-		Description: fmt.Sprintf("Current Phase: %s", phase.ToString()),
-		Timestamp:   "",
-		Color:       color,
-		Footer:      nil,
-		Image:       nil,
-		Thumbnail:   nil,
-		Video:       nil,
-		Provider:    nil,
-		Author:      nil,
-		Fields:      listResp,
-	}
-
-	return &msg
-}
-
 func extractUserIDFromMention(mention string) (string, error) {
 	//nickname format
 	if strings.HasPrefix(mention, "<@!") && strings.HasSuffix(mention, ">") {
@@ -244,3 +219,11 @@ func extractUserIDFromMention(mention string) (string, error) {
 		return "", errors.New("mention does not conform to the correct format")
 	}
 }
+
+func extractChannelIDFromMention(mention string) (string, error) {
+	if strings.HasPrefix(mention, "<#") && strings.HasSuffix(mention, ">") {
+		return mention[2 : len(mention)-1], nil
+	} else {
+		return "", errors.New("channel mention does not conform to the correct format")
+	}
+}
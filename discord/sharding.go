@@ -0,0 +1,152 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/bus"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// Sharding is which of the bot's shards this process runs, and how it reaches the others
+type Sharding struct {
+	ID    int
+	Count int
+	//Bus forwards capture events to the process that owns the capture's guild
+	Bus bus.Bus
+}
+
+//sharding is this process' shard; a bot that isn't sharded is shard 0 of 1
+var sharding = Sharding{ID: 0, Count: 1, Bus: bus.NewMemory()}
+
+//guildShard is the shard discord sends a guild's events to
+func guildShard(guildID string) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil || sharding.Count < 2 {
+		return 0
+	}
+	return int((id >> 22) % uint64(sharding.Count))
+}
+
+//codeShard is the shard of the guild a link code was made for, so captures can link from any process
+func codeShard(code string) int {
+	n, err := strconv.ParseUint(code, 16, 64)
+	if err != nil || sharding.Count < 2 {
+		return 0
+	}
+	return int(n % uint64(sharding.Count))
+}
+
+// ShardTopic is the bus topic of the process running a shard
+func ShardTopic(shardID int) string {
+	return fmt.Sprintf("shard.%d", shardID)
+}
+
+// Events captures send, as forwarded to the process that owns the capture's guild
+const (
+	CaptureConnect    = "connect"
+	CaptureState      = "state"
+	CapturePlayer     = "player"
+	CaptureDisconnect = "disconnect"
+)
+
+// CaptureMessage is an event from a capture, forwarded over the bus
+type CaptureMessage struct {
+	//CaptureID is unique across processes
+	CaptureID string `json:"captureID"`
+	Event     string `json:"event"`
+	Data      string `json:"data"`
+}
+
+// CaptureRelay remembers which shard each capture connected to this process is linked to, so its events can be
+// forwarded there
+type CaptureRelay struct {
+	shards map[string]int
+	lock   sync.Mutex
+}
+
+func MakeCaptureRelay() *CaptureRelay {
+	return &CaptureRelay{
+		shards: map[string]int{},
+		lock:   sync.Mutex{},
+	}
+}
+
+//captureID makes a socket's ID unique across processes
+func captureID(socketID string) string {
+	return fmt.Sprintf("%d/%s", sharding.ID, socketID)
+}
+
+// Connect forwards a capture's link code to the shard the code was made for
+func (cr *CaptureRelay) Connect(socketID, code string) {
+	shard := codeShard(code)
+	cr.lock.Lock()
+	previous, linked := cr.shards[socketID]
+	cr.shards[socketID] = shard
+	cr.lock.Unlock()
+
+	//the game the capture was linked to has to hear it's gone, if it's on another shard
+	if linked && previous != shard {
+		cr.forward(previous, CaptureMessage{CaptureID: captureID(socketID), Event: CaptureDisconnect})
+	}
+	cr.forward(shard, CaptureMessage{CaptureID: captureID(socketID), Event: CaptureConnect, Data: code})
+}
+
+// Forward forwards an event from a capture to the shard it's linked to, and is false if it isn't linked to any
+func (cr *CaptureRelay) Forward(socketID, event, data string) bool {
+	cr.lock.Lock()
+	shard, ok := cr.shards[socketID]
+	cr.lock.Unlock()
+	if !ok {
+		return false
+	}
+	cr.forward(shard, CaptureMessage{CaptureID: captureID(socketID), Event: event, Data: data})
+	return true
+}
+
+// Disconnect tells the shard a capture was linked to that it's gone
+func (cr *CaptureRelay) Disconnect(socketID string) {
+	cr.lock.Lock()
+	shard, ok := cr.shards[socketID]
+	delete(cr.shards, socketID)
+	cr.lock.Unlock()
+	if ok {
+		cr.forward(shard, CaptureMessage{CaptureID: captureID(socketID), Event: CaptureDisconnect})
+	}
+}
+
+func (cr *CaptureRelay) forward(shard int, msg CaptureMessage) {
+	capLog := logger.With(logger.Fields{logger.CaptureKey: msg.CaptureID, "shard": shard})
+	b, err := json.Marshal(msg)
+	if err != nil {
+		capLog.Error(err)
+		return
+	}
+	err = sharding.Bus.Publish(ShardTopic(shard), b)
+	if err != nil {
+		captureForwards.Inc("error")
+		capLog.Errorf("Couldn't forward the capture's %s event: %s", msg.Event, err)
+		return
+	}
+	if shard == sharding.ID {
+		captureForwards.Inc("local")
+	} else {
+		captureForwards.Inc("forwarded")
+	}
+}
+
+//subscribeCaptures handles the capture events for this process' guilds, wherever the captures connected
+func subscribeCaptures(dg *discordgo.Session) error {
+	return sharding.Bus.Subscribe(ShardTopic(sharding.ID), func(data []byte) {
+		msg := CaptureMessage{}
+		err := json.Unmarshal(data, &msg)
+		if err != nil {
+			logger.Warnf("Couldn't read a capture event from the bus: %s", err)
+			return
+		}
+		handleCaptureMessage(dg, msg)
+	})
+}
@@ -0,0 +1,182 @@
+package discord
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/bus"
+)
+
+//useSharding runs the rest of the test as one shard of several
+func useSharding(t *testing.T, s Sharding) {
+	previous := sharding
+	sharding = s
+	t.Cleanup(func() {
+		sharding = previous
+	})
+}
+
+//shardInbox collects the capture messages forwarded to a shard
+type shardInbox struct {
+	messages []CaptureMessage
+	lock     sync.Mutex
+}
+
+func subscribeShard(t *testing.T, b bus.Bus, shard int) *shardInbox {
+	in := &shardInbox{}
+	err := b.Subscribe(ShardTopic(shard), func(data []byte) {
+		msg := CaptureMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		in.lock.Lock()
+		in.messages = append(in.messages, msg)
+		in.lock.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+//take returns the messages forwarded since it was last called
+func (in *shardInbox) take() []CaptureMessage {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	out := in.messages
+	in.messages = nil
+	return out
+}
+
+func checkForwarded(t *testing.T, shard int, got []CaptureMessage, want ...CaptureMessage) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("shard %d got %+v, want %+v", shard, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("shard %d got %+v, want %+v", shard, got, want)
+		}
+	}
+}
+
+func TestGuildShard(t *testing.T) {
+	tests := []struct {
+		guildID string
+		shards  []int
+	}{
+		{"41771983423143937", []int{0, 0, 2, 6}},
+		{"81384788778295296", []int{1, 1, 1, 5}},
+		{"175928847299117063", []int{0, 2, 0, 4}},
+		{"613425648706519061", []int{1, 1, 1, 13}},
+		{"not a snowflake", []int{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		for i, count := range []int{2, 3, 4, 16} {
+			useSharding(t, Sharding{Count: count})
+			if got := guildShard(tt.guildID); got != tt.shards[i] {
+				t.Errorf("guild %s is on shard %d of %d, want %d", tt.guildID, got, count, tt.shards[i])
+			}
+		}
+	}
+}
+
+func TestConnectCodesAreForTheGuildsShard(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 5, 16} {
+		useSharding(t, Sharding{Count: count})
+		for _, guildID := range []string{"41771983423143937", "81384788778295296", "613425648706519061"} {
+			for i := 0; i < 10; i++ {
+				code := generateConnectCode(guildID)
+				if _, err := strconv.ParseUint(code, 16, 64); err != nil || len(code) != 6 {
+					t.Fatalf("%s isn't a 6 digit hex code", code)
+				}
+				if codeShard(code) != guildShard(guildID) {
+					t.Errorf("code %s is for shard %d of %d, but guild %s is on shard %d", code, codeShard(code), count, guildID, guildShard(guildID))
+				}
+			}
+		}
+	}
+}
+
+func TestCaptureRelay(t *testing.T) {
+	memory := bus.NewMemory()
+	useSharding(t, Sharding{ID: 0, Count: 2, Bus: memory})
+	shards := []*shardInbox{subscribeShard(t, memory, 0), subscribeShard(t, memory, 1)}
+	check := func(want0, want1 []CaptureMessage) {
+		t.Helper()
+		checkForwarded(t, 0, shards[0].take(), want0...)
+		checkForwarded(t, 1, shards[1].take(), want1...)
+	}
+	//the codes are for shards 1, 0 and 0
+	const code1, code0, otherCode0 = "00000B", "00000C", "00000E"
+	relay := MakeCaptureRelay()
+
+	if relay.Forward("socket", CaptureState, "1") {
+		t.Error("an event from a capture that never connected was forwarded")
+	}
+	check(nil, nil)
+
+	relay.Connect("socket", code1)
+	relay.Forward("socket", CaptureState, "1")
+	check(nil, []CaptureMessage{
+		{CaptureID: "0/socket", Event: CaptureConnect, Data: code1},
+		{CaptureID: "0/socket", Event: CaptureState, Data: "1"},
+	})
+
+	//linking to a game on another shard tells the first one the capture is gone
+	relay.Connect("socket", code0)
+	relay.Forward("socket", CapturePlayer, "{}")
+	check([]CaptureMessage{
+		{CaptureID: "0/socket", Event: CaptureConnect, Data: code0},
+		{CaptureID: "0/socket", Event: CapturePlayer, Data: "{}"},
+	}, []CaptureMessage{
+		{CaptureID: "0/socket", Event: CaptureDisconnect},
+	})
+
+	//linking to another game on the same shard is left to that shard
+	relay.Connect("socket", otherCode0)
+	check([]CaptureMessage{{CaptureID: "0/socket", Event: CaptureConnect, Data: otherCode0}}, nil)
+
+	//each capture is routed on its own
+	relay.Connect("other", code1)
+	relay.Disconnect("socket")
+	relay.Disconnect("socket")
+	if relay.Forward("socket", CaptureState, "2") || !relay.Forward("other", CaptureState, "2") {
+		t.Error("events were routed by the wrong capture")
+	}
+	check([]CaptureMessage{{CaptureID: "0/socket", Event: CaptureDisconnect}}, []CaptureMessage{
+		{CaptureID: "0/other", Event: CaptureConnect, Data: code1},
+		{CaptureID: "0/other", Event: CaptureState, Data: "2"},
+	})
+}
+
+func TestCaptureRelayOverHTTP(t *testing.T) {
+	peers := map[string]string{}
+	shard0 := bus.NewHTTP(peers, "secret", time.Second)
+	shard1 := bus.NewHTTP(peers, "secret", time.Second)
+	for shard, b := range []*bus.HTTP{shard0, shard1} {
+		mux := http.NewServeMux()
+		mux.Handle(bus.HTTPPath, b)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		peers[ShardTopic(shard)] = server.URL
+	}
+	//the capture connected to shard 0, and its guild is on shard 1
+	useSharding(t, Sharding{ID: 0, Count: 2, Bus: shard0})
+	remote := subscribeShard(t, shard1, 1)
+	relay := MakeCaptureRelay()
+
+	relay.Connect("socket", "00000B")
+	relay.Forward("socket", CaptureState, "1")
+	relay.Disconnect("socket")
+	checkForwarded(t, 1, remote.take(),
+		CaptureMessage{CaptureID: "0/socket", Event: CaptureConnect, Data: "00000B"},
+		CaptureMessage{CaptureID: "0/socket", Event: CaptureState, Data: "1"},
+		CaptureMessage{CaptureID: "0/socket", Event: CaptureDisconnect},
+	)
+}
@@ -0,0 +1,79 @@
+package discord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// StatusEditWindow is how long edits to status messages are collected, before each message is edited once
+const StatusEditWindow = 500 * time.Millisecond
+
+// StatusEdits collects the games whose status message needs to be edited, so bursts of updates (like everyone
+// reacting at the start of a game) turn into a single edit per message
+type StatusEdits struct {
+	pending map[string]*GameState
+	wake    chan struct{}
+	lock    sync.Mutex
+}
+
+func MakeStatusEdits() *StatusEdits {
+	return &StatusEdits{
+		pending: map[string]*GameState{},
+		wake:    make(chan struct{}, 1),
+		lock:    sync.Mutex{},
+	}
+}
+
+// Request queues an edit of a game's status message. It never blocks
+func (se *StatusEdits) Request(gs *GameState) {
+	se.lock.Lock()
+	se.pending[gs.ID] = gs
+	se.lock.Unlock()
+
+	select {
+	case se.wake <- struct{}{}:
+	default:
+		//the renderer is already going to flush soon
+	}
+}
+
+func (se *StatusEdits) takePending() map[string]*GameState {
+	se.lock.Lock()
+	defer se.lock.Unlock()
+	pending := se.pending
+	se.pending = map[string]*GameState{}
+	return pending
+}
+
+//requestStatusEdit queues an edit of the status message of a game, with whatever its state is by the time it's sent
+func (guild *GuildState) requestStatusEdit(gs *GameState) {
+	guild.StatusEdits.Request(gs)
+	//anything worth editing the status message for is worth showing on overlays
+	guild.Overlay.Notify()
+}
+
+//statusEditLoop renders and edits the status messages that were requested, at most once per window
+func (guild *GuildState) statusEditLoop(s *discordgo.Session) {
+	for {
+		select {
+		case <-guild.ctx.Done():
+			return
+		case <-guild.StatusEdits.wake:
+		}
+		//give any other updates in the burst a chance to arrive
+		if !sleepContext(guild.ctx, StatusEditWindow) {
+			return
+		}
+
+		for _, gs := range guild.StatusEdits.takePending() {
+			//the game might have ended while the edit was waiting
+			if guild.Games.Get(gs.ID) != gs {
+				continue
+			}
+			//rendered now, not when requested, so the message always shows the latest state
+			gs.GameStateMsg.Edit(s, gameStateResponse(guild, gs))
+		}
+	}
+}
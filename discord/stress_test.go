@@ -0,0 +1,240 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+//fakeDiscord answers every REST call the bot makes, so a session can be used without a connection to discord
+type fakeDiscord struct{}
+
+func (fakeDiscord) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := "{}"
+	switch {
+	case r.Method == http.MethodGet && (strings.HasSuffix(r.URL.Path, "/emojis") || strings.HasSuffix(r.URL.Path, "/channels")):
+		body = "[]"
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+		body = `{"id": "message", "channel_id": "text", "author": {"id": "bot"}}`
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/members/"):
+		userID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		body = fmt.Sprintf(`{"user": {"id": "%s", "username": "%s"}}`, userID, userID)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+//startTestGuild sets up a guild the way a GuildCreate does, with players in a voice channel, and tears it down when
+//the test ends. Its files are written to a temporary directory
+func startTestGuild(t *testing.T, guildID string, players int) (*discordgo.Session, *GuildState) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	logger.Configure(logger.ErrorLevel+1, logger.TextFormat, ioutil.Discard)
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &http.Client{Transport: fakeDiscord{}}
+	s.State.User = &discordgo.User{ID: "bot", Username: "bot"}
+
+	g := &discordgo.Guild{
+		ID:      guildID,
+		Name:    guildID,
+		OwnerID: "owner",
+		Channels: []*discordgo.Channel{
+			{ID: "text", GuildID: guildID, Name: "text", Type: discordgo.ChannelTypeGuildText},
+			{ID: "voice", GuildID: guildID, Name: "voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{GuildID: guildID, User: &discordgo.User{ID: "bot", Username: "bot"}},
+			{GuildID: guildID, User: &discordgo.User{ID: "owner", Username: "owner"}},
+		},
+		VoiceStates: []*discordgo.VoiceState{{GuildID: guildID, UserID: "owner", ChannelID: "voice"}},
+	}
+	for i := 0; i < players; i++ {
+		userID := fmt.Sprintf("user%d", i)
+		g.Members = append(g.Members, &discordgo.Member{GuildID: guildID, User: &discordgo.User{ID: userID, Username: userID}})
+		g.VoiceStates = append(g.VoiceStates, &discordgo.VoiceState{GuildID: guildID, UserID: userID, ChannelID: "voice"})
+	}
+	if err := s.State.GuildAdd(g); err != nil {
+		t.Fatal(err)
+	}
+	newGuild("")(s, &discordgo.GuildCreate{Guild: g})
+	guild, ok := AllGuilds.Get(guildID)
+	if !ok {
+		t.Fatal("guild wasn't set up")
+	}
+
+	t.Cleanup(func() {
+		//an outage only tears the guild down, so its files are left alone
+		removeGuild(s, &discordgo.GuildDelete{Guild: &discordgo.Guild{ID: guildID, Unavailable: true}})
+		os.Chdir(dir)
+	})
+	return s, guild
+}
+
+func command(s *discordgo.Session, guildID, content string) {
+	messageCreate(s, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "command",
+		ChannelID: "text",
+		GuildID:   guildID,
+		Content:   content,
+		Author:    &discordgo.User{ID: "owner", Username: "owner"},
+	}})
+}
+
+func playerMessage(captureID string, player game.Player) CaptureMessage {
+	data, _ := json.Marshal(player)
+	return CaptureMessage{CaptureID: captureID, Event: CapturePlayer, Data: string(data)}
+}
+
+// Captures, commands, voice events, renders and the admin API all touch the same guild at once; run with -race
+func TestGuildHandlesConcurrentEvents(t *testing.T) {
+	const guildID = "stress"
+	const players = 8
+	s, guild := startTestGuild(t, guildID, players)
+
+	command(s, guildID, ".au new")
+	gs := guild.Games.Get("1")
+	if gs == nil {
+		t.Fatal("game wasn't started")
+	}
+	handleCaptureMessage(s, CaptureMessage{CaptureID: "capture", Event: CaptureConnect, Data: gs.GetLinkCode()})
+	for i := 0; i < players; i++ {
+		handleCaptureMessage(s, playerMessage("capture", game.Player{Action: game.JOINED, Name: fmt.Sprintf("p%d", i), Color: i}))
+	}
+	//the listener links nobody until it has seen the players
+	deadline := time.Now().Add(2 * time.Second)
+	for gs.AmongUsData.NumDetectedPlayers() < players && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < players; i++ {
+		//users are only known to the bot once it has seen them in voice
+		voiceStateChange(s, &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{GuildID: guildID, UserID: fmt.Sprintf("user%d", i), ChannelID: "voice"}})
+		command(s, guildID, fmt.Sprintf(".au link <@user%d> %s", i, game.GetColorStringForInt(i)))
+	}
+	if linked := len(guild.UserData.GetLinkedUsers(gs.ID)); linked != players {
+		t.Fatalf("%d players were linked, want %d", linked, players)
+	}
+
+	const setTemplate = ".au template tasks title Game {{.GameID}}"
+	commands := []string{
+		".au rules deafen",
+		".au rules default",
+		".au dryrun on",
+		".au dryrun off",
+		".au lang es",
+		".au lang en",
+		".au spoilers tasks alive on",
+		".au spoilers reset",
+		".au priority tasks discuss dead any",
+		".au priority reset",
+		".au preset create quiet tasks:all:mute",
+		".au preset channel quiet voice",
+		".au preset delete quiet",
+		setTemplate,
+		".au template reset",
+		".au nick on",
+		".au nick off",
+		".au audit on <#text>",
+		".au audit off",
+		".au emojis sync",
+		".au emojis cleanup",
+		".au drift",
+		".au games",
+	}
+	const rounds = 30
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		phases := []game.Phase{game.TASKS, game.DISCUSS, game.TASKS, game.LOBBY}
+		for i := 0; i < rounds; i++ {
+			handleCaptureMessage(s, CaptureMessage{CaptureID: "capture", Event: CaptureState, Data: fmt.Sprint(phases[i%len(phases)])})
+			p := i % players
+			handleCaptureMessage(s, playerMessage("capture", game.Player{Action: game.DIED, Name: fmt.Sprintf("p%d", p), Color: p, IsDead: true}))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			command(s, guildID, commands[i%len(commands)])
+			//the template has to really change for rendering to race with it
+			if commands[i%len(commands)] == setTemplate {
+				if title := guild.PersistentGuildData.EmbedTemplates.Get(guild.PersistentGuildData.GetLanguage(), game.TASKS).Title; title != "Game {{.GameID}}" {
+					t.Errorf("the tasks title is %q after %s", title, setTemplate)
+				}
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			update := &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{
+				GuildID:   guildID,
+				UserID:    fmt.Sprintf("user%d", i%players),
+				ChannelID: "voice",
+				Mute:      i%2 == 0,
+				Deaf:      i%3 == 0,
+			}}
+			s.State.OnInterface(s, update)
+			voiceStateChange(s, update)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			gameStateResponse(guild, gs)
+			guild.overlayState(s, "")
+			toAPIGuild(s, guild, true)
+			guild.reconcile(s)
+		}
+	}()
+	wg.Wait()
+
+	api := httptest.NewServer(adminAPIHandler(s, "token"))
+	defer api.Close()
+	req, err := http.NewRequest(http.MethodPost, api.URL+"/api/guilds/"+guildID+"/games/1/end", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("ending the game returned %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	//the game is ended by the guild's listener, after whatever updates were queued before it
+	deadline = time.Now().Add(5 * time.Second)
+	for guild.Games.Get("1") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the game wasn't ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+
+	"github.com/denverquane/amongusdiscord/locale"
 )
 
 // Tracking struct
@@ -41,12 +43,21 @@ func (tracking *Tracking) IsTracked(channelID string) bool {
 	return false
 }
 
-func (tracking *Tracking) ToStatusString() string {
+// IsExplicitlyTracked is like IsTracked, but doesn't count "no channels tracked" as tracking every channel
+func (tracking *Tracking) IsExplicitlyTracked(channelID string) bool {
+	tracking.lock.RLock()
+	defer tracking.lock.RUnlock()
+
+	_, ok := tracking.tracking[channelID]
+	return ok
+}
+
+func (tracking *Tracking) ToStatusString(lang string) string {
 	tracking.lock.RLock()
 	defer tracking.lock.RUnlock()
 
 	if len(tracking.tracking) == 0 {
-		return "Any Voice Channel"
+		return locale.Get(lang, "tracking.any")
 	}
 
 	buf := bytes.NewBuffer([]byte{})
@@ -54,16 +65,28 @@ func (tracking *Tracking) ToStatusString() string {
 	for _, v := range tracking.tracking {
 		buf.WriteString(fmt.Sprintf("%s ", v.channelName))
 		if v.forGhosts {
-			buf.WriteString(" (ghosts) ")
+			buf.WriteString(" " + locale.Get(lang, "tracking.ghosts") + " ")
 		}
 		if i < len(tracking.tracking)-1 {
-			buf.WriteString("or ")
+			buf.WriteString(locale.Get(lang, "tracking.or") + " ")
 		}
 		i++
 	}
 	return buf.String()
 }
 
+// GetAll returns every tracked channel
+func (tracking *Tracking) GetAll() []TrackingChannel {
+	tracking.lock.RLock()
+	defer tracking.lock.RUnlock()
+
+	channels := make([]TrackingChannel, 0, len(tracking.tracking))
+	for _, v := range tracking.tracking {
+		channels = append(channels, v)
+	}
+	return channels
+}
+
 func (tracking *Tracking) Reset() {
 	tracking.lock.Lock()
 	tracking.tracking = map[string]TrackingChannel{}
@@ -0,0 +1,64 @@
+package discord
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// transition is a game's voice changes for a phase, which might be waiting out a delay
+type transition struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//startTransition applies the voice changes for the game's current phase in the background, so the guild's updates
+//keep flowing while it waits out the delay. Changes still waiting for an older phase are cancelled; the new
+//transition starts once the old one has stopped, so nothing it was in the middle of is applied after the new changes
+func (guild *GuildState) startTransition(dg *discordgo.Session, gs *GameState, delay int, steps []PriorityStep) {
+	ctx, cancel := context.WithCancel(context.Background())
+	next := &transition{cancel: cancel, done: make(chan struct{})}
+
+	gs.transitionLock.Lock()
+	previous := gs.transition
+	gs.transition = next
+	gs.transitionLock.Unlock()
+	previous.stop()
+
+	go func() {
+		defer close(next.done)
+		defer cancel()
+		if previous != nil {
+			<-previous.done
+		}
+		guild.handleTrackedMembers(ctx, dg, gs, delay, steps)
+
+		gs.transitionLock.Lock()
+		if gs.transition == next {
+			gs.transition = nil
+		}
+		gs.transitionLock.Unlock()
+	}()
+}
+
+//cancelTransition cancels any voice changes still waiting to be applied to the game
+func (gs *GameState) cancelTransition() {
+	gs.transitionLock.Lock()
+	previous := gs.transition
+	gs.transition = nil
+	gs.transitionLock.Unlock()
+	previous.stop()
+}
+
+func (t *transition) stop() {
+	if t == nil {
+		return
+	}
+	select {
+	case <-t.done:
+		//it already finished
+	default:
+		transitionsCancelled.Inc()
+		t.cancel()
+	}
+}
@@ -0,0 +1,229 @@
+package discord
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/logger"
+)
+
+// UpdateQueueSize is how many updates of each kind can wait in a guild's queues. Updates that don't fit wait in its
+// UpdateQueueOverflow instead, so whoever sends an update never waits, and a busy guild can't stall a capture's socket
+const UpdateQueueSize = 64
+
+// UpdateQueueOverflow holds the updates that didn't fit in a guild's full queues, oldest first, until its
+// updatesListener makes room for them. Its lock serializes sends to the queues, so coalescing them can't reorder them
+type UpdateQueueOverflow struct {
+	phases  []PhaseUpdate
+	players []PlayerUpdate
+	lock    sync.Mutex
+}
+
+// Len returns how many phase and player updates are waiting for room
+func (o *UpdateQueueOverflow) Len() (int, int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return len(o.phases), len(o.players)
+}
+
+// UpdateQueueOverflows are each guild's overflowed updates. Like the channels, it's guarded by ChannelsMapLock
+var UpdateQueueOverflows = map[string]*UpdateQueueOverflow{}
+
+// queuePhaseUpdate queues a phase update for a guild, and never blocks. If the queue is full, the updates waiting
+// are coalesced to the latest phase of each game, so one game's updates never push out another's, and whatever still
+// doesn't fit waits in the guild's overflow
+func queuePhaseUpdate(guildID string, update PhaseUpdate) bool {
+	ChannelsMapLock.RLock()
+	ch, ok := GamePhaseUpdateChannels[guildID]
+	overflow := UpdateQueueOverflows[guildID]
+	ChannelsMapLock.RUnlock()
+	if !ok {
+		return false
+	}
+	overflow.lock.Lock()
+	defer overflow.lock.Unlock()
+
+	//updates can't skip ahead of the ones still waiting for room
+	if len(overflow.phases) == 0 {
+		select {
+		case *ch <- update:
+			return true
+		default:
+		}
+	}
+
+	//the listener may still be taking updates while we drain, which is fine; it only ever takes the oldest
+	waiting := []PhaseUpdate{}
+	for drained := false; !drained; {
+		select {
+		case u := <-*ch:
+			waiting = append(waiting, u)
+		default:
+			drained = true
+		}
+	}
+	waiting = append(waiting, overflow.phases...)
+	waiting = append(waiting, update)
+	coalesced := coalescePhaseUpdates(waiting)
+	if dropped := len(waiting) - len(coalesced); dropped > 0 {
+		updateQueueDrops.Add(float64(dropped), guildID, "phase")
+		logger.With(logger.Fields{logger.GuildKey: guildID}).Warnf("Phase update queue is full; skipped %d phase(s) that newer ones replaced", dropped)
+	}
+	overflow.phases = fillPhaseQueue(*ch, coalesced)
+	return true
+}
+
+//fillPhaseQueue sends updates to the queue until it's full, and returns the ones that didn't fit
+func fillPhaseQueue(ch chan PhaseUpdate, updates []PhaseUpdate) []PhaseUpdate {
+	for i, u := range updates {
+		select {
+		case ch <- u:
+		default:
+			return updates[i:]
+		}
+	}
+	return nil
+}
+
+//coalescePhaseUpdates keeps only the latest update of each game, in the order the games' latest updates came in.
+//Ending a game is never skipped, since no phase replaces it
+func coalescePhaseUpdates(updates []PhaseUpdate) []PhaseUpdate {
+	latest := map[string]int{}
+	for i, u := range updates {
+		latest[u.GameID] = i
+	}
+	coalesced := make([]PhaseUpdate, 0, len(latest))
+	for i, u := range updates {
+		if u.End || latest[u.GameID] == i {
+			coalesced = append(coalesced, u)
+		}
+	}
+	return coalesced
+}
+
+// queuePlayerUpdate queues a player update for a guild, and never blocks. If the queue is full, the updates waiting
+// that only carry a player's state are coalesced to the latest one for each player; joins, deaths, exiles and
+// disconnects are never dropped, and wait in the guild's overflow if they still don't fit
+func queuePlayerUpdate(guildID string, update PlayerUpdate) bool {
+	ChannelsMapLock.RLock()
+	ch, ok := PlayerUpdateChannels[guildID]
+	overflow := UpdateQueueOverflows[guildID]
+	ChannelsMapLock.RUnlock()
+	if !ok {
+		return false
+	}
+	overflow.lock.Lock()
+	defer overflow.lock.Unlock()
+
+	if len(overflow.players) == 0 {
+		select {
+		case *ch <- update:
+			return true
+		default:
+		}
+	}
+
+	waiting := []PlayerUpdate{}
+	for drained := false; !drained; {
+		select {
+		case u := <-*ch:
+			waiting = append(waiting, u)
+		default:
+			drained = true
+		}
+	}
+	waiting = append(waiting, overflow.players...)
+	waiting = append(waiting, update)
+	coalesced := coalescePlayerUpdates(waiting)
+	if dropped := len(waiting) - len(coalesced); dropped > 0 {
+		updateQueueDrops.Add(float64(dropped), guildID, "player")
+		logger.With(logger.Fields{logger.GuildKey: guildID, logger.GameKey: update.GameID}).Warnf("Player update queue is full; skipped %d player update(s) that newer ones replaced", dropped)
+	}
+	overflow.players = fillPlayerQueue(*ch, coalesced)
+	return true
+}
+
+//fillPlayerQueue sends updates to the queue until it's full, and returns the ones that didn't fit
+func fillPlayerQueue(ch chan PlayerUpdate, updates []PlayerUpdate) []PlayerUpdate {
+	for i, u := range updates {
+		select {
+		case ch <- u:
+		default:
+			return updates[i:]
+		}
+	}
+	return nil
+}
+
+// refillUpdateQueues moves a guild's overflowed updates into its queues, as far as there's room. The updatesListener
+// calls it after taking each update, so a queue is never left empty while updates wait for room
+func refillUpdateQueues(guildID string) {
+	ChannelsMapLock.RLock()
+	phases, ok := GamePhaseUpdateChannels[guildID]
+	players := PlayerUpdateChannels[guildID]
+	overflow := UpdateQueueOverflows[guildID]
+	ChannelsMapLock.RUnlock()
+	if !ok {
+		return
+	}
+	overflow.lock.Lock()
+	defer overflow.lock.Unlock()
+	if len(overflow.phases) > 0 {
+		overflow.phases = fillPhaseQueue(*phases, overflow.phases)
+	}
+	if len(overflow.players) > 0 {
+		overflow.players = fillPlayerQueue(*players, overflow.players)
+	}
+}
+
+//isPlayerEvent is true for updates that mean something happened, rather than just carrying the player's latest state
+func isPlayerEvent(player game.Player) bool {
+	switch player.Action {
+	case game.JOINED, game.DIED, game.EXILED, game.DISCONNECTED:
+		return true
+	}
+	return player.Disconnected
+}
+
+//playerUpdateKey identifies the player an update is about, within a guild
+func playerUpdateKey(update PlayerUpdate) string {
+	if update.Player.ClientID != 0 {
+		return update.GameID + "/" + strconv.Itoa(update.Player.ClientID)
+	}
+	return update.GameID + "/" + update.Player.Name
+}
+
+//coalescePlayerUpdates drops state-only updates that a later update of the same player replaces. Every update
+//carries the player's whole state, so nothing is lost by skipping to the latest one
+func coalescePlayerUpdates(updates []PlayerUpdate) []PlayerUpdate {
+	latest := map[string]int{}
+	for i, u := range updates {
+		latest[playerUpdateKey(u)] = i
+	}
+	coalesced := make([]PlayerUpdate, 0, len(updates))
+	for i, u := range updates {
+		if isPlayerEvent(u.Player) || latest[playerUpdateKey(u)] == i {
+			coalesced = append(coalesced, u)
+		}
+	}
+	return coalesced
+}
+
+// queueSocketUpdate queues a capture (dis)connecting for a guild, and is false if the queue is full and it was dropped
+func queueSocketUpdate(update SocketStatus) bool {
+	ChannelsMapLock.RLock()
+	defer ChannelsMapLock.RUnlock()
+	ch, ok := SocketUpdateChannels[update.GuildID]
+	if !ok {
+		return false
+	}
+	select {
+	case *ch <- update:
+		return true
+	default:
+		updateQueueDrops.Inc(update.GuildID, "socket")
+		logger.With(logger.Fields{logger.GuildKey: update.GuildID, logger.GameKey: update.GameID}).Warn("Socket update queue is full; dropped the update")
+		return false
+	}
+}
@@ -0,0 +1,181 @@
+package discord
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/game"
+)
+
+//withQueues registers queues for a guild that nothing is listening to, and removes them when the test ends
+func withQueues(t *testing.T, guildID string) (chan PhaseUpdate, chan PlayerUpdate) {
+	phaseUpdates := make(chan PhaseUpdate, UpdateQueueSize)
+	playerUpdates := make(chan PlayerUpdate, UpdateQueueSize)
+	ChannelsMapLock.Lock()
+	GamePhaseUpdateChannels[guildID] = &phaseUpdates
+	PlayerUpdateChannels[guildID] = &playerUpdates
+	UpdateQueueOverflows[guildID] = &UpdateQueueOverflow{}
+	ChannelsMapLock.Unlock()
+	t.Cleanup(func() {
+		ChannelsMapLock.Lock()
+		delete(GamePhaseUpdateChannels, guildID)
+		delete(PlayerUpdateChannels, guildID)
+		delete(UpdateQueueOverflows, guildID)
+		ChannelsMapLock.Unlock()
+	})
+	return phaseUpdates, playerUpdates
+}
+
+//takePhaseUpdates takes every update waiting for a guild, the way its updatesListener does
+func takePhaseUpdates(guildID string, ch chan PhaseUpdate) []PhaseUpdate {
+	taken := []PhaseUpdate{}
+	for len(ch) > 0 {
+		taken = append(taken, <-ch)
+		refillUpdateQueues(guildID)
+	}
+	return taken
+}
+
+//takePlayerUpdates takes every update waiting for a guild, the way its updatesListener does
+func takePlayerUpdates(guildID string, ch chan PlayerUpdate) []PlayerUpdate {
+	taken := []PlayerUpdate{}
+	for len(ch) > 0 {
+		taken = append(taken, <-ch)
+		refillUpdateQueues(guildID)
+	}
+	return taken
+}
+
+func TestQueuePhaseUpdateKeepsEveryGamesLatestPhase(t *testing.T) {
+	phaseUpdates, _ := withQueues(t, "phase-guild")
+
+	//game 1 fills the queue, then game 2 changes phase once
+	for i := 0; i < UpdateQueueSize; i++ {
+		phase := game.TASKS
+		if i%2 == 1 {
+			phase = game.DISCUSS
+		}
+		if !queuePhaseUpdate("phase-guild", PhaseUpdate{GameID: "1", Phase: phase}) {
+			t.Fatalf("update %d wasn't queued", i)
+		}
+	}
+	if !queuePhaseUpdate("phase-guild", PhaseUpdate{GameID: "2", Phase: game.LOBBY}) {
+		t.Fatal("game 2's update wasn't queued")
+	}
+
+	latest := map[string]game.Phase{}
+	for _, u := range takePhaseUpdates("phase-guild", phaseUpdates) {
+		latest[u.GameID] = u.Phase
+	}
+	if latest["1"] != game.DISCUSS {
+		t.Errorf("game 1 ended up in phase %d, want %d", latest["1"], game.DISCUSS)
+	}
+	if latest["2"] != game.LOBBY {
+		t.Errorf("game 2 ended up in phase %d, want %d", latest["2"], game.LOBBY)
+	}
+}
+
+func TestQueuePlayerUpdateNeverDropsDeaths(t *testing.T) {
+	_, playerUpdates := withQueues(t, "player-guild")
+
+	deaths := map[string]bool{}
+	for i := 0; i < UpdateQueueSize*3; i++ {
+		player := game.Player{Action: game.CHANGECOLOR, Name: "red", Color: i % game.NumColors}
+		if i%10 == 0 {
+			player = game.Player{Action: game.DIED, Name: "p" + string(rune('a'+i/10)), IsDead: true}
+			deaths[player.Name] = true
+		}
+		if !queuePlayerUpdate("player-guild", PlayerUpdate{GameID: "1", Player: player}) {
+			t.Fatalf("update %d wasn't queued", i)
+		}
+	}
+
+	lastColor := -1
+	for _, u := range takePlayerUpdates("player-guild", playerUpdates) {
+		if u.Player.Action == game.DIED {
+			delete(deaths, u.Player.Name)
+		} else {
+			lastColor = u.Player.Color
+		}
+	}
+	if len(deaths) > 0 {
+		t.Errorf("deaths were dropped: %v", deaths)
+	}
+	if want := (UpdateQueueSize*3 - 1) % game.NumColors; lastColor != want {
+		t.Errorf("red's latest color was %d, want %d", lastColor, want)
+	}
+}
+
+// A guild that stopped taking updates mustn't hold up whoever sends them, like a capture's socket
+func TestQueuePlayerUpdateNeverBlocks(t *testing.T) {
+	_, playerUpdates := withQueues(t, "blocked-guild")
+
+	const count = UpdateQueueSize * 4
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		player := game.Player{Action: game.DIED, Name: "p" + strconv.Itoa(i), IsDead: true}
+		if !queuePlayerUpdate("blocked-guild", PlayerUpdate{GameID: "1", Player: player}) {
+			t.Fatalf("update %d wasn't queued", i)
+		}
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("queueing %d deaths took %s", count, took)
+	}
+	if waiting, overflowed := len(playerUpdates), count-UpdateQueueSize; waiting != UpdateQueueSize {
+		t.Errorf("%d updates are queued, want %d with %d overflowed", waiting, UpdateQueueSize, overflowed)
+	}
+
+	//once the listener takes them, every death comes out in order
+	taken := takePlayerUpdates("blocked-guild", playerUpdates)
+	if len(taken) != count {
+		t.Fatalf("took %d updates, want %d", len(taken), count)
+	}
+	for i, u := range taken {
+		if u.Player.Name != "p"+strconv.Itoa(i) {
+			t.Fatalf("update %d was %s's death, want p%d's", i, u.Player.Name, i)
+		}
+	}
+}
+
+// Ending a game waits behind the updates before it, rather than blocking the caller or being dropped
+func TestQueuePhaseUpdateKeepsEndsInOrder(t *testing.T) {
+	phaseUpdates, _ := withQueues(t, "ending-guild")
+
+	for i := 0; i < UpdateQueueSize*2; i++ {
+		gameID := strconv.Itoa(i)
+		queuePhaseUpdate("ending-guild", PhaseUpdate{GameID: gameID, Phase: game.TASKS})
+		queuePhaseUpdate("ending-guild", PhaseUpdate{GameID: gameID, End: true})
+	}
+	//a phase can be skipped for the end that came after it, but every game ends, in the order they ended
+	ended := []string{}
+	for _, u := range takePhaseUpdates("ending-guild", phaseUpdates) {
+		if u.End {
+			ended = append(ended, u.GameID)
+		}
+	}
+	if len(ended) != UpdateQueueSize*2 {
+		t.Fatalf("%d games ended, want %d", len(ended), UpdateQueueSize*2)
+	}
+	for i, gameID := range ended {
+		if gameID != strconv.Itoa(i) {
+			t.Fatalf("game %s ended in place %d", gameID, i)
+		}
+	}
+}
+
+func TestCoalescePlayerUpdatesKeepsOrder(t *testing.T) {
+	updates := []PlayerUpdate{
+		{GameID: "1", Player: game.Player{Action: game.CHANGECOLOR, Name: "a", Color: 1}},
+		{GameID: "1", Player: game.Player{Action: game.DIED, Name: "b", IsDead: true}},
+		{GameID: "2", Player: game.Player{Action: game.CHANGECOLOR, Name: "a", Color: 3}},
+		{GameID: "1", Player: game.Player{Action: game.CHANGECOLOR, Name: "a", Color: 2}},
+	}
+	coalesced := coalescePlayerUpdates(updates)
+	if len(coalesced) != 3 {
+		t.Fatalf("got %d updates, want 3", len(coalesced))
+	}
+	if coalesced[0].Player.Action != game.DIED || coalesced[1].GameID != "2" || coalesced[2].Player.Color != 2 {
+		t.Errorf("updates were coalesced out of order: %+v", coalesced)
+	}
+}
@@ -3,20 +3,22 @@ package discord
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/denverquane/amongusdiscord/game"
 )
 
+// UserDataSet owns the guild's UserData. It's only changed through its methods, which change it in place; everything
+// it hands out is a copy, so a stale copy can never be written back over a newer change
 type UserDataSet struct {
-	userDataSet map[string]game.UserData
+	userDataSet map[string]*game.UserData
 	lock        sync.RWMutex
 }
 
-func MakeUserDataSet() UserDataSet {
-	return UserDataSet{
-		userDataSet: map[string]game.UserData{},
+func MakeUserDataSet() *UserDataSet {
+	return &UserDataSet{
+		userDataSet: map[string]*game.UserData{},
 		lock:        sync.RWMutex{},
 	}
 }
@@ -27,71 +29,129 @@ func (uds *UserDataSet) Size() int {
 	return len(uds.userDataSet)
 }
 
-func (uds *UserDataSet) GetCountLinked() int {
+func (uds *UserDataSet) GetCountLinked(gameID string) int {
 	uds.lock.RLock()
 	defer uds.lock.RUnlock()
 
 	LinkedPlayerCount := 0
 
 	for _, v := range uds.userDataSet {
-		if v.IsLinked() {
+		if v.IsLinked() && v.GetGameID() == gameID {
 			LinkedPlayerCount++
 		}
 	}
 	return LinkedPlayerCount
 }
 
-func (uds *UserDataSet) AddFullUser(user game.UserData) {
+// AddUser adds a user, unless they were already added, and returns a copy of whichever is kept
+func (uds *UserDataSet) AddUser(user game.UserData) game.UserData {
 	uds.lock.Lock()
-	uds.userDataSet[user.GetID()] = user
+	defer uds.lock.Unlock()
+	if v, ok := uds.userDataSet[user.GetID()]; ok {
+		return *v
+	}
+	uds.userDataSet[user.GetID()] = &user
+	return user
+}
+
+// ErrAlreadyLinked is returned when linking a user to a player another user is linked to
+type ErrAlreadyLinked struct {
+	UserID string
+}
+
+func (e ErrAlreadyLinked) Error() string {
+	return fmt.Sprintf("that player is already linked to user %s", e.UserID)
+}
+
+// UpdatePlayerData links a user to a player. A player can only be linked to one user; the other user has to be
+// unlinked first
+func (uds *UserDataSet) UpdatePlayerData(userID, gameID string, data *game.PlayerData) error {
+	uds.lock.Lock()
+	defer uds.lock.Unlock()
+
+	if data != nil {
+		for i, v := range uds.userDataSet {
+			if i != userID && v.IsLinkedTo(data) {
+				return ErrAlreadyLinked{UserID: i}
+			}
+		}
+	}
+	if v, ok := uds.userDataSet[userID]; ok {
+		v.SetPlayerData(gameID, data)
+		return nil
+	}
+	return fmt.Errorf("no user found with ID %s", userID)
+}
+
+func (uds *UserDataSet) UpdateNickName(userID, nick string) {
+	uds.lock.Lock()
+	if v, ok := uds.userDataSet[userID]; ok {
+		v.SetNickName(nick)
+	}
 	uds.lock.Unlock()
 }
 
-func (uds *UserDataSet) UpdateUserData(userID string, data game.UserData) {
+func (uds *UserDataSet) SetPendingVoiceUpdate(userID string, is bool) {
 	uds.lock.Lock()
-	uds.userDataSet[userID] = data
+	if v, ok := uds.userDataSet[userID]; ok {
+		v.SetPendingVoiceUpdate(is)
+	}
 	uds.lock.Unlock()
 }
 
-func (uds *UserDataSet) UpdatePlayerData(userID string, data *game.PlayerData) bool {
+// StartVoiceUpdate marks a user as waiting on a voice update, and is false if they already were, or don't exist
+func (uds *UserDataSet) StartVoiceUpdate(userID string) bool {
 	uds.lock.Lock()
 	defer uds.lock.Unlock()
-
-	if v, ok := uds.userDataSet[userID]; ok {
-		v.SetPlayerData(data)
-		uds.userDataSet[userID] = v
+	if v, ok := uds.userDataSet[userID]; ok && !v.IsPendingVoiceUpdate() {
+		v.SetPendingVoiceUpdate(true)
 		return true
 	}
 	return false
 }
 
-func (uds *UserDataSet) ClearPlayerData(userID string) {
+// ClearPlayerData unlinks a user, and returns the game they were linked in, if they were
+func (uds *UserDataSet) ClearPlayerData(userID string) string {
 	uds.lock.Lock()
+	defer uds.lock.Unlock()
+	gameID := ""
 	if v, ok := uds.userDataSet[userID]; ok {
-		v.SetPlayerData(nil)
-		uds.userDataSet[userID] = v
+		if v.IsLinked() {
+			gameID = v.GetGameID()
+		}
+		v.SetPlayerData("", nil)
 	}
-	uds.lock.Unlock()
+	return gameID
 }
 
-func (uds *UserDataSet) ClearPlayerDataByPlayerName(playerName string) {
+// ClearPlayerDataByPlayer unlinks any users linked to the player, and returns their IDs
+func (uds *UserDataSet) ClearPlayerDataByPlayer(gameID string, player *game.PlayerData) []string {
 	uds.lock.Lock()
+	defer uds.lock.Unlock()
+
+	cleared := make([]string, 0)
 	for i, v := range uds.userDataSet {
-		if v.GetPlayerName() == playerName {
-			v.SetPlayerData(nil)
-			uds.userDataSet[i] = v
+		if v.GetGameID() == gameID && v.IsLinkedTo(player) {
+			v.SetPlayerData("", nil)
+			cleared = append(cleared, i)
 		}
 	}
-	uds.lock.Unlock()
+	return cleared
 }
 
-func (uds *UserDataSet) ClearAllPlayerData() {
+// ClearAllPlayerData unlinks every user linked in the game, and returns their IDs
+func (uds *UserDataSet) ClearAllPlayerData(gameID string) []string {
 	uds.lock.Lock()
+	defer uds.lock.Unlock()
+
+	cleared := make([]string, 0)
 	for i, v := range uds.userDataSet {
-		v.SetPlayerData(nil)
-		uds.userDataSet[i] = v
+		if v.IsLinked() && v.GetGameID() == gameID {
+			v.SetPlayerData("", nil)
+			cleared = append(cleared, i)
+		}
 	}
-	uds.lock.Unlock()
+	return cleared
 }
 
 func (uds *UserDataSet) GetUser(userID string) (game.UserData, error) {
@@ -99,35 +159,24 @@ func (uds *UserDataSet) GetUser(userID string) (game.UserData, error) {
 	defer uds.lock.RUnlock()
 
 	if v, ok := uds.userDataSet[userID]; ok {
-		return v, nil
+		return *v, nil
 	}
 	return game.UserData{}, errors.New(fmt.Sprintf("No user found with ID %s", userID))
 }
 
-func (uds *UserDataSet) ToEmojiEmbedFields(emojis AlivenessEmojis) []*discordgo.MessageEmbedField {
+// GetLinkedUsers returns copies of the users linked in a game, ordered by color
+func (uds *UserDataSet) GetLinkedUsers(gameID string) []game.UserData {
 	uds.lock.RLock()
 	defer uds.lock.RUnlock()
 
-	unsorted := make([]*discordgo.MessageEmbedField, 12)
-	num := 0
-	for _, player := range uds.userDataSet {
-		if player.IsLinked() {
-			emoji := emojis[player.IsAlive()][player.GetColor()]
-			unsorted[player.GetColor()] = &discordgo.MessageEmbedField{
-				Name:   fmt.Sprintf("%s", player.GetPlayerName()),
-				Value:  fmt.Sprintf("%s <@!%s>", emoji.FormatForInline(), player.GetID()),
-				Inline: true,
-			}
-			num++
-		}
-	}
-	sorted := make([]*discordgo.MessageEmbedField, num)
-	num = 0
-	for i := 0; i < 12; i++ {
-		if unsorted[i] != nil {
-			sorted[num] = unsorted[i]
-			num++
+	linked := make([]game.UserData, 0)
+	for _, v := range uds.userDataSet {
+		if v.IsLinked() && v.GetGameID() == gameID {
+			linked = append(linked, *v)
 		}
 	}
-	return sorted
+	sort.Slice(linked, func(i, j int) bool {
+		return linked[i].GetColor() < linked[j].GetColor()
+	})
+	return linked
 }
@@ -0,0 +1,306 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+)
+
+// Names of the built-in voice rule presets
+const (
+	DeafenPreset = "deafen" //alive players are deafened during tasks, the dead are muted during discussion
+	MutePreset   = "mute"   //nobody is ever deafened, everyone is muted during tasks
+	OpenPreset   = "open"   //nobody is ever muted or deafened, like a casual/proximity chat channel
+)
+
+// DefaultPreset isn't a real preset; using it means "whatever the game uses"
+const DefaultPreset = "default"
+
+var builtinPresetNames = []string{DeafenPreset, MutePreset, OpenPreset}
+
+func builtinPreset(name string) (VoiceRules, bool) {
+	switch name {
+	case DeafenPreset:
+		return MakeMuteAndDeafenRules(), true
+	case MutePreset:
+		return MakeMuteOnlyRules(), true
+	case OpenPreset:
+		return MakeOpenRules(), true
+	}
+	return VoiceRules{}, false
+}
+
+// MakeOpenRules never mutes or deafens anyone
+func MakeOpenRules() VoiceRules {
+	rules := VoiceRules{
+		MuteRules: map[game.PhaseNameString]map[string]bool{},
+		DeafRules: map[game.PhaseNameString]map[string]bool{},
+	}
+	for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		rules.MuteRules[game.PhaseNames[phase]] = map[string]bool{"alive": false, "dead": false}
+		rules.DeafRules[game.PhaseNames[phase]] = map[string]bool{"alive": false, "dead": false}
+	}
+	return rules
+}
+
+// VoicePresets are the custom voice rule presets of a guild, and which preset each voice channel uses
+type VoicePresets struct {
+	Custom map[string]VoiceRules `json:"custom"`
+
+	//Channels maps voice channel IDs to the name of the preset used for members in that channel
+	Channels map[string]string `json:"channels"`
+
+	lock sync.RWMutex
+}
+
+func MakeVoicePresets() VoicePresets {
+	return VoicePresets{
+		Custom:   map[string]VoiceRules{},
+		Channels: map[string]string{},
+		lock:     sync.RWMutex{},
+	}
+}
+
+// MarshalJSON marshals the presets while nothing can change them
+func (vp *VoicePresets) MarshalJSON() ([]byte, error) {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	return json.Marshal(struct {
+		Custom   map[string]VoiceRules `json:"custom"`
+		Channels map[string]string     `json:"channels"`
+	}{vp.Custom, vp.Channels})
+}
+
+// Get returns the rules of a built-in or custom preset
+func (vp *VoicePresets) Get(name string) (VoiceRules, bool) {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	return vp.get(name)
+}
+
+//get is Get, for when the lock is already held
+func (vp *VoicePresets) get(name string) (VoiceRules, bool) {
+	if rules, ok := builtinPreset(name); ok {
+		return rules, true
+	}
+	rules, ok := vp.Custom[name]
+	return rules, ok
+}
+
+// Names returns the built-in presets, followed by the custom ones in alphabetical order
+func (vp *VoicePresets) Names() []string {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+
+	custom := make([]string, 0, len(vp.Custom))
+	for name := range vp.Custom {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return append(append([]string{}, builtinPresetNames...), custom...)
+}
+
+func (vp *VoicePresets) SetCustom(name string, rules VoiceRules) error {
+	if _, ok := builtinPreset(name); ok || name == DefaultPreset {
+		return locale.Errorf("error.builtinPreset", name)
+	}
+	if isGameID(name) {
+		return locale.Errorf("error.numericPreset")
+	}
+	vp.lock.Lock()
+	defer vp.lock.Unlock()
+	if vp.Custom == nil {
+		vp.Custom = map[string]VoiceRules{}
+	}
+	vp.Custom[name] = rules
+	return nil
+}
+
+// DeleteCustom removes a custom preset, and any channel assignments that used it
+func (vp *VoicePresets) DeleteCustom(name string) error {
+	vp.lock.Lock()
+	defer vp.lock.Unlock()
+	if _, ok := vp.Custom[name]; !ok {
+		return locale.Errorf("error.noCustomPreset", name)
+	}
+	delete(vp.Custom, name)
+	for channelID, v := range vp.Channels {
+		if v == name {
+			delete(vp.Channels, channelID)
+		}
+	}
+	return nil
+}
+
+// AssignChannel makes members in a voice channel use a preset. The default preset clears the assignment
+func (vp *VoicePresets) AssignChannel(channelID, name string) error {
+	vp.lock.Lock()
+	defer vp.lock.Unlock()
+	if vp.Channels == nil {
+		vp.Channels = map[string]string{}
+	}
+	if name == DefaultPreset {
+		delete(vp.Channels, channelID)
+		return nil
+	}
+	if _, ok := vp.get(name); !ok {
+		return locale.Errorf("error.noPreset", name)
+	}
+	vp.Channels[channelID] = name
+	return nil
+}
+
+// ChannelPreset returns the preset assigned to a voice channel, if there is one
+func (vp *VoicePresets) ChannelPreset(channelID string) (string, VoiceRules, bool) {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	name, ok := vp.Channels[channelID]
+	if !ok {
+		return "", VoiceRules{}, false
+	}
+	rules, ok := vp.get(name)
+	return name, rules, ok
+}
+
+// ChannelAssignments returns a copy of which preset each voice channel uses
+func (vp *VoicePresets) ChannelAssignments() map[string]string {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	channels := make(map[string]string, len(vp.Channels))
+	for channelID, name := range vp.Channels {
+		channels[channelID] = name
+	}
+	return channels
+}
+
+// ToString describes who is muted/deafened in each phase in the language, like "TASKS: alive deafened, dead muted"
+func (rules *VoiceRules) ToString(lang string) string {
+	phases := make([]string, 0)
+	for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		groups := make([]string, 0)
+		for _, group := range []string{"alive", "dead"} {
+			mute, deaf := rules.GetVoiceState(group == "alive", true, phase)
+			switch {
+			case deaf:
+				groups = append(groups, locale.Get(lang, "rules."+group+"Deafened"))
+			case mute:
+				groups = append(groups, locale.Get(lang, "rules."+group+"Muted"))
+			}
+		}
+		if len(groups) > 0 {
+			phases = append(phases, fmt.Sprintf("%s: %s", locale.PhaseName(lang, phase), strings.Join(groups, ", ")))
+		}
+	}
+	if len(phases) == 0 {
+		return locale.Get(lang, "rules.open")
+	}
+	return strings.Join(phases, "; ")
+}
+
+// parseVoiceRules parses rules of the form phase:group:action, like tasks:alive:deaf or discuss:dead:mute. Anything
+// that isn't given is left unmuted and undeafened
+func parseVoiceRules(args []string) (VoiceRules, error) {
+	rules := MakeOpenRules()
+	if len(args) == 0 {
+		return rules, locale.Errorf("error.noRules")
+	}
+	for _, arg := range args {
+		parts := strings.Split(arg, ":")
+		if len(parts) != 3 {
+			return rules, locale.Errorf("error.ruleFormat", arg)
+		}
+		phase := getPhaseFromArgs(parts[:1])
+		if phase == game.UNINITIALIZED {
+			return rules, locale.Errorf("error.unknownPhase", parts[0])
+		}
+		groups := []string{parts[1]}
+		switch parts[1] {
+		case "alive", "dead":
+		case "all":
+			groups = []string{"alive", "dead"}
+		default:
+			return rules, locale.Errorf("error.unknownRuleGroup", parts[1])
+		}
+		for _, group := range groups {
+			phaseName := game.PhaseNames[phase]
+			switch parts[2] {
+			case "deaf":
+				rules.MuteRules[phaseName][group] = true
+				rules.DeafRules[phaseName][group] = true
+			case "mute":
+				rules.MuteRules[phaseName][group] = true
+				rules.DeafRules[phaseName][group] = false
+			case "none":
+				rules.MuteRules[phaseName][group] = false
+				rules.DeafRules[phaseName][group] = false
+			default:
+				return rules, locale.Errorf("error.unknownAction", parts[2])
+			}
+		}
+	}
+	return rules, nil
+}
+
+// voiceRulesFor returns the rules for a member of a game in a voice channel; a preset assigned to the channel
+// takes precedence over the rules of the game
+func (guild *GuildState) voiceRulesFor(gs *GameState, channelID string) VoiceRules {
+	if _, rules, ok := guild.PersistentGuildData.VoicePresets.ChannelPreset(channelID); ok {
+		return rules
+	}
+	return gs.GetVoiceRules()
+}
+
+// voiceRulesStatusString is shown in the status message; the game's rules, then any channels with their own
+func (guild *GuildState) voiceRulesStatusString(gs *GameState) string {
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(gs.GetVoicePresetName())
+	channels := guild.PersistentGuildData.VoicePresets.ChannelAssignments()
+	for _, channelID := range sortedChannelIDs(channels) {
+		//only the channels this game can actually use are relevant
+		if gs.Tracking.IsTracked(channelID) {
+			buf.WriteString(fmt.Sprintf("\n<#%s>: %s", channelID, channels[channelID]))
+		}
+	}
+	return buf.String()
+}
+
+//sortedChannelIDs returns the channels of preset assignments in order, so they're always listed the same way
+func sortedChannelIDs(channels map[string]string) []string {
+	channelIDs := make([]string, 0, len(channels))
+	for channelID := range channels {
+		channelIDs = append(channelIDs, channelID)
+	}
+	sort.Strings(channelIDs)
+	return channelIDs
+}
+
+func (guild *GuildState) presetsResponse() string {
+	presets := &guild.PersistentGuildData.VoicePresets
+	lang := guild.PersistentGuildData.GetLanguage()
+	buf := bytes.NewBuffer([]byte{})
+	buf.WriteString(guild.text("reply.presets") + "\n")
+	for _, name := range presets.Names() {
+		rules, _ := presets.Get(name)
+		kind := guild.text("reply.presetBuiltin")
+		if _, ok := builtinPreset(name); !ok {
+			kind = guild.text("reply.presetCustom")
+		}
+		buf.WriteString(fmt.Sprintf("`%s` (%s): %s\n", name, kind, rules.ToString(lang)))
+	}
+	channels := presets.ChannelAssignments()
+	if len(channels) == 0 {
+		buf.WriteString(guild.text("reply.noChannelPresets"))
+		return buf.String()
+	}
+	buf.WriteString(guild.text("reply.channelPresets") + "\n")
+	for _, channelID := range sortedChannelIDs(channels) {
+		buf.WriteString(fmt.Sprintf("<#%s>: `%s`\n", channelID, channels[channelID]))
+	}
+	return buf.String()
+}
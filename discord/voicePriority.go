@@ -0,0 +1,226 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/game"
+	"github.com/denverquane/amongusdiscord/locale"
+)
+
+// PriorityGroup selects which members a step of a phase transition applies to
+type PriorityGroup string
+
+// PriorityGroup constants
+const (
+	AliveGroup   PriorityGroup = "alive"
+	DeadGroup    PriorityGroup = "dead"
+	MutingGroup  PriorityGroup = "muting"   //members about to be muted or deafened
+	UnmutedGroup PriorityGroup = "unmuting" //members about to be unmuted and undeafened
+	AnyGroup     PriorityGroup = "any"      //everyone not handled by an earlier step
+)
+
+// PriorityStep is one group of voice updates within a phase transition. Steps are applied in order, and each step
+// waits for the previous one to completely finish first
+type PriorityStep struct {
+	Group PriorityGroup `json:"group"`
+	//DelayMs is how long to wait before this group starts, after the previous group is done
+	DelayMs int `json:"delayMs"`
+	//StaggerMs is how long to wait between each member of the group. 0 updates the whole group at once
+	StaggerMs int `json:"staggerMs"`
+}
+
+// Matches determines if a member belongs to the group of this step
+func (step *PriorityStep) Matches(isAlive, isMuting bool) bool {
+	switch step.Group {
+	case AliveGroup:
+		return isAlive
+	case DeadGroup:
+		return !isAlive
+	case MutingGroup:
+		return isMuting
+	case UnmutedGroup:
+		return !isMuting
+	default:
+		return true
+	}
+}
+
+// ToString for a step, in the same format the priority command accepts
+func (step *PriorityStep) ToString() string {
+	if step.DelayMs == 0 && step.StaggerMs == 0 {
+		return string(step.Group)
+	}
+	return fmt.Sprintf("%s:%d:%d", step.Group, step.DelayMs, step.StaggerMs)
+}
+
+// VoicePriorities struct
+type VoicePriorities struct {
+	//maps from origin->new phases, to the ordered steps voice updates are applied in
+	Priorities map[game.PhaseNameString]map[game.PhaseNameString][]PriorityStep `json:"priorities"`
+
+	lock sync.RWMutex
+}
+
+func MakeDefaultVoicePriorities() VoicePriorities {
+	anyFirst := []PriorityStep{{Group: AnyGroup}}
+	return VoicePriorities{
+		Priorities: map[game.PhaseNameString]map[game.PhaseNameString][]PriorityStep{
+			game.PhaseNames[game.LOBBY]: {
+				game.PhaseNames[game.TASKS]: anyFirst,
+			},
+			game.PhaseNames[game.TASKS]: {
+				game.PhaseNames[game.LOBBY]: anyFirst,
+				//when going from tasks to discussion, we should unmute the dead FIRST
+				game.PhaseNames[game.DISCUSS]: {{Group: DeadGroup}, {Group: AnyGroup}},
+			},
+			game.PhaseNames[game.DISCUSS]: {
+				game.PhaseNames[game.LOBBY]: anyFirst,
+				//when going from discussion to tasks, we should mute alive players FIRST
+				game.PhaseNames[game.TASKS]: {{Group: AliveGroup}, {Group: AnyGroup}},
+			},
+		},
+		lock: sync.RWMutex{},
+	}
+}
+
+// MarshalJSON marshals the priorities while nothing can change them
+func (vp *VoicePriorities) MarshalJSON() ([]byte, error) {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	return json.Marshal(struct {
+		Priorities map[game.PhaseNameString]map[game.PhaseNameString][]PriorityStep `json:"priorities"`
+	}{vp.Priorities})
+}
+
+// Reset goes back to the default priorities
+func (vp *VoicePriorities) Reset() {
+	vp.lock.Lock()
+	vp.Priorities = MakeDefaultVoicePriorities().Priorities
+	vp.lock.Unlock()
+}
+
+// GetSteps returns the steps for a transition, which are never empty
+func (vp *VoicePriorities) GetSteps(origin, dest game.Phase) []PriorityStep {
+	vp.lock.RLock()
+	defer vp.lock.RUnlock()
+	if vp.Priorities != nil {
+		if steps, ok := vp.Priorities[game.PhaseNames[origin]][game.PhaseNames[dest]]; ok && len(steps) > 0 {
+			return steps
+		}
+	}
+	return []PriorityStep{{Group: AnyGroup}}
+}
+
+func (vp *VoicePriorities) SetSteps(origin, dest game.Phase, steps []PriorityStep) {
+	vp.lock.Lock()
+	defer vp.lock.Unlock()
+	if vp.Priorities == nil {
+		vp.Priorities = map[game.PhaseNameString]map[game.PhaseNameString][]PriorityStep{}
+	}
+	if _, ok := vp.Priorities[game.PhaseNames[origin]]; !ok {
+		vp.Priorities[game.PhaseNames[origin]] = map[game.PhaseNameString][]PriorityStep{}
+	}
+	vp.Priorities[game.PhaseNames[origin]][game.PhaseNames[dest]] = steps
+}
+
+// ToStatusString lists the ordering used for every transition, with the phases named in the language
+func (vp *VoicePriorities) ToStatusString(lang string) string {
+	buf := bytes.NewBuffer([]byte{})
+	for _, origin := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		for _, dest := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+			if origin == dest || origin == game.LOBBY && dest == game.DISCUSS {
+				continue
+			}
+			steps := vp.GetSteps(origin, dest)
+			strs := make([]string, len(steps))
+			for i, v := range steps {
+				strs[i] = v.ToString()
+			}
+			buf.WriteString(fmt.Sprintf("%s → %s: `%s`\n", locale.PhaseName(lang, origin), locale.PhaseName(lang, dest), strings.Join(strs, " ")))
+		}
+	}
+	return buf.String()
+}
+
+// parsePriorityStep parses steps of the form group[:delayMs[:staggerMs]], like dead:0:500
+func parsePriorityStep(arg string) (PriorityStep, error) {
+	parts := strings.Split(arg, ":")
+	step := PriorityStep{Group: PriorityGroup(parts[0])}
+	switch step.Group {
+	case AliveGroup, DeadGroup, MutingGroup, UnmutedGroup, AnyGroup:
+	default:
+		return step, locale.Errorf("error.unknownPriorityGroup", parts[0])
+	}
+	if len(parts) > 3 {
+		return step, locale.Errorf("error.tooManyValues", arg)
+	}
+	for i, v := range parts[1:] {
+		num, err := strconv.Atoi(v)
+		if err != nil || num < 0 || num > 10000 {
+			return step, locale.Errorf("error.badDelay", v)
+		}
+		if i == 0 {
+			step.DelayMs = num
+		} else {
+			step.StaggerMs = num
+		}
+	}
+	return step, nil
+}
+
+func parsePrioritySteps(args []string) ([]PriorityStep, error) {
+	if len(args) == 0 {
+		return nil, locale.Errorf("error.noGroups")
+	}
+	steps := make([]PriorityStep, len(args))
+	for i, v := range args {
+		step, err := parsePriorityStep(v)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+// VoiceCountdown tracks when pending voice changes are going to be applied, so it can be shown in the status message
+type VoiceCountdown struct {
+	deadline time.Time
+	label    string
+	lock     sync.RWMutex
+}
+
+func (vc *VoiceCountdown) Set(label string, duration time.Duration) {
+	vc.lock.Lock()
+	vc.label = label
+	vc.deadline = time.Now().Add(duration)
+	vc.lock.Unlock()
+}
+
+func (vc *VoiceCountdown) Clear() {
+	vc.lock.Lock()
+	vc.label = ""
+	vc.deadline = time.Time{}
+	vc.lock.Unlock()
+}
+
+// ToStatusString returns "" when there's nothing pending. The label is already in the language of the guild
+func (vc *VoiceCountdown) ToStatusString(lang string) string {
+	vc.lock.RLock()
+	defer vc.lock.RUnlock()
+
+	if vc.deadline.IsZero() {
+		return ""
+	}
+	remaining := time.Until(vc.deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return locale.Get(lang, "countdown.remaining", vc.label, int((remaining+time.Second-1)/time.Second))
+}
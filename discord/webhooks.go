@@ -0,0 +1,333 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/locale"
+	"github.com/denverquane/amongusdiscord/logger"
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+const (
+	// WebhookQueueSize is how many events can wait to be sent to each webhook
+	WebhookQueueSize = 100
+	// WebhookMaxAttempts is how many times an event is sent before it's given up on
+	WebhookMaxAttempts = 5
+	// WebhookBackoff is how long to wait before the first retry; every retry after waits twice as long
+	WebhookBackoff = 2 * time.Second
+	// WebhookTimeout is how long a webhook has to answer
+	WebhookTimeout = 10 * time.Second
+	// WebhookDeadLetters is how many events that were given up on are kept, per guild
+	WebhookDeadLetters = 100
+	// MaxWebhooks is how many webhooks a guild can have
+	MaxWebhooks = 5
+)
+
+//webhookClient is replaced by one that allows private addresses when they're allowed
+var webhookClient = webhook.NewClient(WebhookTimeout, false)
+
+// Webhook is an endpoint a guild's events are POSTed to, signed with its secret
+type Webhook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	//Events are the types of event sent to it; empty sends every type
+	Events []GuildEventType `json:"events,omitempty"`
+}
+
+func (wh *Webhook) wants(eventType GuildEventType) bool {
+	if len(wh.Events) == 0 || eventType == TestEvent {
+		return true
+	}
+	for _, v := range wh.Events {
+		if v == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeadLettersFilename returns the filename the events a guild's webhooks never got are kept in
+func WebhookDeadLettersFilename(guildID string) string {
+	return fmt.Sprintf("%s_webhook_deadletters.json", guildID)
+}
+
+// Webhooks sends a guild's events to its webhooks, each with its own queue
+type Webhooks struct {
+	hooks       []Webhook
+	senders     map[string]*webhook.Sender
+	deadLetters *webhook.DeadLetters
+	lock        sync.RWMutex
+}
+
+func MakeWebhooks(guildID string, hooks []Webhook) *Webhooks {
+	w := &Webhooks{
+		senders:     map[string]*webhook.Sender{},
+		deadLetters: webhook.LoadDeadLetters(WebhookDeadLettersFilename(guildID), WebhookDeadLetters),
+	}
+	w.Set(hooks)
+	return w
+}
+
+// Set replaces the webhooks events are sent to. Events waiting for removed webhooks are dropped
+func (w *Webhooks) Set(hooks []Webhook) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.hooks = make([]Webhook, len(hooks))
+	copy(w.hooks, hooks)
+
+	kept := map[string]bool{}
+	for _, v := range hooks {
+		kept[v.ID] = true
+		if _, ok := w.senders[v.ID]; !ok {
+			w.senders[v.ID] = webhook.NewSender(webhookClient, WebhookQueueSize, WebhookMaxAttempts, WebhookBackoff, w.attempted, w.giveUp)
+		}
+	}
+	for id, sender := range w.senders {
+		if !kept[id] {
+			sender.Close()
+			delete(w.senders, id)
+		}
+	}
+}
+
+func (w *Webhooks) attempted(d webhook.Delivery, result webhook.Result) {
+	if result.OK() {
+		webhookAttempts.Inc("ok")
+		return
+	}
+	webhookAttempts.Inc("error")
+	logger.With(logger.Fields{"webhook": d.WebhookID, "delivery": d.ID}).Warnf("Webhook delivery attempt %d failed: %s", d.Attempts, result)
+}
+
+func (w *Webhooks) giveUp(d webhook.Delivery) {
+	webhookDeadLetters.Inc("failed")
+	err := w.deadLetters.Add(d)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+// Dispatch queues an event for every webhook that wants it. It never blocks
+func (w *Webhooks) Dispatch(event GuildEvent) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if len(w.hooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, v := range w.hooks {
+		if !v.wants(event.Type) {
+			continue
+		}
+		d := webhook.Delivery{
+			ID:        webhook.NewDeliveryID(),
+			WebhookID: v.ID,
+			Event:     string(event.Type),
+			Body:      body,
+			URL:       v.URL,
+			Secret:    v.Secret,
+		}
+		if !w.senders[v.ID].Enqueue(d) {
+			//a webhook that's down shouldn't hold up the bot; it can be retried later
+			d.LastError = "too many events waiting to be sent"
+			d.FailedAt = time.Now()
+			webhookDeadLetters.Inc("queueFull")
+			if err := w.deadLetters.Add(d); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// Test sends an event to a webhook right away, once, and returns what happened
+func (w *Webhooks) Test(id string, event GuildEvent) (webhook.Result, error) {
+	w.lock.RLock()
+	hook, ok := w.get(id)
+	w.lock.RUnlock()
+	if !ok {
+		return webhook.Result{}, locale.Errorf("error.noWebhook", id)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return webhook.Result{}, err
+	}
+	return webhook.Post(webhookClient, webhook.Delivery{
+		ID:        webhook.NewDeliveryID(),
+		WebhookID: hook.ID,
+		Event:     string(event.Type),
+		Body:      body,
+		Attempts:  1,
+		URL:       hook.URL,
+		Secret:    hook.Secret,
+	}), nil
+}
+
+// RetryDeadLetters queues every event that was given up on again, and returns how many were queued. Events for
+// webhooks that were removed are dropped, and events that don't fit in their webhook's queue are kept for next time
+func (w *Webhooks) RetryDeadLetters() (int, error) {
+	letters, err := w.deadLetters.Take()
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	queued := 0
+	for _, d := range letters {
+		hook, ok := w.get(d.WebhookID)
+		if !ok {
+			continue
+		}
+		retry := d
+		retry.URL, retry.Secret = hook.URL, hook.Secret
+		retry.Attempts, retry.LastError, retry.FailedAt = 0, "", time.Time{}
+		if w.senders[hook.ID].Enqueue(retry) {
+			queued++
+			continue
+		}
+		if addErr := w.deadLetters.Add(d); addErr != nil {
+			err = addErr
+		}
+	}
+	return queued, err
+}
+
+// DeadLetters returns the events that were given up on, oldest first
+func (w *Webhooks) DeadLetters() []webhook.Delivery {
+	return w.deadLetters.All()
+}
+
+// Close stops sending events
+func (w *Webhooks) Close() {
+	w.Set(nil)
+}
+
+//get must be called with the lock held
+func (w *Webhooks) get(id string) (Webhook, bool) {
+	for _, v := range w.hooks {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	return Webhook{}, false
+}
+
+// webhookEventTypes are the event types webhooks can ask for
+var webhookEventTypes = []GuildEventType{GameStartEvent, GameEndEvent, PhaseEvent, ForcedPhaseEvent, PlayerJoinedEvent,
+	PlayerLeftEvent, PlayerDiedEvent, PlayerExiledEvent, LinkEvent, UnlinkEvent, PatchFailedEvent}
+
+func parseWebhookEventTypes(args []string) ([]GuildEventType, error) {
+	types := make([]GuildEventType, 0, len(args))
+	for _, arg := range args {
+		found := false
+		for _, v := range webhookEventTypes {
+			if strings.EqualFold(string(v), arg) {
+				types = append(types, v)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, locale.Errorf("error.unknownEvent", arg)
+		}
+	}
+	return types, nil
+}
+
+//addWebhook adds a webhook to the guild, and returns it
+func (guild *GuildState) addWebhook(rawURL string, events []GuildEventType) (Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return Webhook{}, locale.Errorf("error.webhookURL")
+	}
+	existing := guild.PersistentGuildData.GetWebhooks()
+	if len(existing) >= MaxWebhooks {
+		return Webhook{}, locale.Errorf("error.tooManyWebhooks", MaxWebhooks)
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	//IDs of webhooks with dead letters aren't reused, so retries can't end up sent to the wrong webhook
+	id := 1
+	for _, v := range existing {
+		if n, err := strconv.Atoi(v.ID); err == nil && n >= id {
+			id = n + 1
+		}
+	}
+	for _, v := range guild.Webhooks.DeadLetters() {
+		if n, err := strconv.Atoi(v.WebhookID); err == nil && n >= id {
+			id = n + 1
+		}
+	}
+
+	hook := Webhook{ID: strconv.Itoa(id), URL: rawURL, Secret: secret, Events: events}
+	hooks := append(existing, hook)
+	guild.PersistentGuildData.SetWebhooks(hooks)
+	guild.Webhooks.Set(hooks)
+	return hook, guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+}
+
+//removeWebhook removes one of the guild's webhooks, and returns if there was one with the ID
+func (guild *GuildState) removeWebhook(id string) (bool, error) {
+	existing := guild.PersistentGuildData.GetWebhooks()
+	hooks := make([]Webhook, 0, len(existing))
+	for _, v := range existing {
+		if v.ID != id {
+			hooks = append(hooks, v)
+		}
+	}
+	if len(hooks) == len(existing) {
+		return false, nil
+	}
+	guild.PersistentGuildData.SetWebhooks(hooks)
+	guild.Webhooks.Set(hooks)
+	return true, guild.PersistentGuildData.ToFile(ConfigFilename(guild.PersistentGuildData.GuildID))
+}
+
+func (guild *GuildState) webhooksResponse() string {
+	hooks := guild.PersistentGuildData.GetWebhooks()
+	prefix := guild.PersistentGuildData.CommandPrefix
+	if len(hooks) == 0 {
+		return guild.text("reply.noWebhooks", prefix)
+	}
+	buf := strings.Builder{}
+	buf.WriteString(guild.text("reply.webhooks", len(guild.Webhooks.DeadLetters()), prefix))
+	for _, v := range hooks {
+		events := guild.text("reply.webhookAllEvents")
+		if len(v.Events) > 0 {
+			names := make([]string, len(v.Events))
+			for i, e := range v.Events {
+				names[i] = string(e)
+			}
+			events = strings.Join(names, ", ")
+		}
+		buf.WriteString(fmt.Sprintf("\n`%s` %s: %s", v.ID, webhook.HostOf(v.URL), events))
+	}
+	return buf.String()
+}
+
+func (guild *GuildState) webhookDeadLettersResponse() string {
+	letters := guild.Webhooks.DeadLetters()
+	if len(letters) == 0 {
+		return guild.text("reply.noDeadLetters")
+	}
+	buf := strings.Builder{}
+	buf.WriteString(guild.text("reply.deadLetters", len(letters), guild.PersistentGuildData.CommandPrefix))
+	//only the most recent ones fit in a message
+	if len(letters) > 10 {
+		letters = letters[len(letters)-10:]
+	}
+	for _, v := range letters {
+		buf.WriteString("\n" + guild.text("reply.deadLetter", v.FailedAt.UTC().Format("2006-01-02 15:04:05"), v.WebhookID, v.Event, v.Attempts, v.LastError))
+	}
+	return buf.String()
+}
@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denverquane/amongusdiscord/webhook"
+)
+
+//startBlockedWebhook starts a webhook that holds every delivery until release is closed, and records the IDs of
+//the deliveries it got
+func startBlockedWebhook(t *testing.T) (*Webhooks, chan struct{}, func() []string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	client := webhookClient
+	webhookClient = webhook.NewClient(time.Second, true)
+	t.Cleanup(func() {
+		webhookClient = client
+		os.Chdir(dir)
+	})
+
+	release := make(chan struct{})
+	received := []string{}
+	lock := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		received = append(received, r.Header.Get(webhook.DeliveryHeader))
+		lock.Unlock()
+		<-release
+	}))
+	w := MakeWebhooks("webhooks", []Webhook{{ID: "1", URL: server.URL, Secret: "secret"}})
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+		w.Close()
+		server.Close()
+	})
+	return w, release, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, received...)
+	}
+}
+
+func TestWebhookQueueFullDeadLetters(t *testing.T) {
+	w, release, received := startBlockedWebhook(t)
+	event := GuildEvent{Type: PhaseEvent, GuildID: "webhooks"}
+
+	//one event is being sent, so the queue fills up and the rest are given up on right away
+	w.Dispatch(event)
+	waitFor(t, "the first event to arrive", func() bool { return len(received()) == 1 })
+	for i := 0; i < WebhookQueueSize+3; i++ {
+		w.Dispatch(event)
+	}
+	dead := w.DeadLetters()
+	if len(dead) != 3 {
+		t.Fatalf("%d dead letters, want 3", len(dead))
+	}
+	for _, v := range dead {
+		if v.WebhookID != "1" || v.LastError != "too many events waiting to be sent" || v.FailedAt.IsZero() {
+			t.Errorf("got dead letter %+v, want one for a full queue", v)
+		}
+	}
+
+	close(release)
+	waitFor(t, "the queued events to arrive", func() bool { return len(received()) == WebhookQueueSize+1 })
+	if len(w.DeadLetters()) != 3 {
+		t.Errorf("%d dead letters after the queue emptied, want 3", len(w.DeadLetters()))
+	}
+}
+
+func TestRetryDeadLetters(t *testing.T) {
+	w, release, received := startBlockedWebhook(t)
+	event := GuildEvent{Type: PhaseEvent, GuildID: "webhooks"}
+
+	w.Dispatch(event)
+	waitFor(t, "the first event to arrive", func() bool { return len(received()) == 1 })
+	for i := 0; i < WebhookQueueSize+3; i++ {
+		w.Dispatch(event)
+	}
+	dead := w.DeadLetters()
+	if err := w.deadLetters.Add(webhook.Delivery{ID: "gone", WebhookID: "2", Body: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	//while the queue is still full nothing can be retried, and only the dead letter for the removed webhook is dropped
+	queued, err := w.RetryDeadLetters()
+	if err != nil || queued != 0 || len(w.DeadLetters()) != 3 {
+		t.Fatalf("queued %d with error %v, and %d are left; want 0, none and 3", queued, err, len(w.DeadLetters()))
+	}
+
+	close(release)
+	waitFor(t, "the queued events to arrive", func() bool { return len(received()) == WebhookQueueSize+1 })
+	queued, err = w.RetryDeadLetters()
+	if err != nil || queued != 3 {
+		t.Fatalf("queued %d with error %v, want 3", queued, err)
+	}
+	if left := w.DeadLetters(); len(left) != 0 {
+		t.Errorf("%d dead letters are left, want none", len(left))
+	}
+	waitFor(t, "the retried events to arrive", func() bool { return len(received()) == WebhookQueueSize+4 })
+	retried := received()[WebhookQueueSize+1:]
+	for i, v := range dead {
+		if retried[i] != v.ID {
+			t.Errorf("retry %d was delivery %s, want %s", i, retried[i], v.ID)
+		}
+	}
+}
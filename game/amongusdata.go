@@ -2,9 +2,11 @@ package game
 
 import (
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/denverquane/amongusdiscord/logger"
 )
 
 //TODO make this private?
@@ -13,6 +15,23 @@ type PlayerData struct {
 	Color   int
 	Name    string
 	IsAlive bool
+	//ClientID identifies the player's game client, when the capture provides it (0 otherwise). It's the only part
+	//of a player's identity that survives renames and color changes
+	ClientID int
+
+	//lock is the lock of the AmongUsData the player belongs to, which guards the fields above; nil for players
+	//that don't belong to one
+	lock *sync.RWMutex
+}
+
+// Snapshot returns a copy of the player, read under the lock of the game they belong to. Users are linked to the
+// *PlayerData the game updates, so this is how to read them from outside of AmongUsData
+func (auData *PlayerData) Snapshot() PlayerData {
+	if auData.lock != nil {
+		auData.lock.RLock()
+		defer auData.lock.RUnlock()
+	}
+	return *auData
 }
 
 // ToString a user
@@ -21,12 +40,15 @@ func (auData *PlayerData) ToString() string {
 }
 
 func (auData *PlayerData) isDifferent(player Player) bool {
-	return auData.IsAlive != !player.IsDead || auData.Color != player.Color || auData.Name != player.Name
+	return auData.IsAlive != !player.IsDead || auData.Color != player.Color || auData.Name != player.Name ||
+		(player.ClientID != 0 && auData.ClientID != player.ClientID)
 }
 
 type AmongUsData struct {
-	//indexed by amongusname
-	playerData map[string]*PlayerData
+	//indexed by a key of our own, since names (and colors) can change, and names don't have to be unique.
+	//Users are linked to the *PlayerData, so those are updated in place and never replaced
+	playerData map[int]*PlayerData
+	nextKey    int
 	//what current phase the game is in (lobby, tasks, discussion)
 	phase  Phase
 	room   string
@@ -37,7 +59,7 @@ type AmongUsData struct {
 
 func NewAmongUsData() AmongUsData {
 	return AmongUsData{
-		playerData: map[string]*PlayerData{},
+		playerData: map[int]*PlayerData{},
 		phase:      LOBBY,
 		room:       "",
 		region:     "",
@@ -87,34 +109,131 @@ func (auData *AmongUsData) GetPhase() Phase {
 
 func (auData *AmongUsData) ClearAllPlayerData() {
 	auData.lock.Lock()
-	auData.playerData = map[string]*PlayerData{}
+	auData.playerData = map[int]*PlayerData{}
 	auData.lock.Unlock()
 }
 
-func (auData *AmongUsData) ApplyPlayerUpdate(update Player) (bool, bool) {
+// findPlayer returns the key of the player an update is about, or -1 if it's about a new player. Callers must hold the lock
+func (auData *AmongUsData) findPlayer(update Player) int {
+	//the client ID is the same through renames and color changes, so when it's known nothing else matters
+	if update.ClientID != 0 {
+		for k, v := range auData.playerData {
+			if v.ClientID == update.ClientID {
+				return k
+			}
+		}
+	}
+
+	for k, v := range auData.playerData {
+		if v.Name == update.Name && v.Color == update.Color && (update.ClientID == 0 || v.ClientID == 0) {
+			return k
+		}
+	}
+
+	switch update.Action {
+	case CHANGECOLOR:
+		//the name stays the same; only trust it when it's unambiguous
+		found := -1
+		for k, v := range auData.playerData {
+			if v.Name == update.Name && (update.ClientID == 0 || v.ClientID == 0) {
+				if found != -1 {
+					logger.Warnf("More than one player is named %s; can't tell whose color changed", update.Name)
+					return -1
+				}
+				found = k
+			}
+		}
+		return found
+	case JOINED:
+		return -1
+	default:
+		//no two players in a game share a color, so an update with a known color and a new name is a rename
+		for k, v := range auData.playerData {
+			if v.Color == update.Color && (update.ClientID == 0 || v.ClientID == 0) {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// ApplyPlayerUpdate records a player update. It returns whether anything changed, whether the player died or
+// came back to life, and the player it replaced, if any: a player joining with the color of a player we never saw
+// leave. Users linked to the replaced player need to be unlinked
+func (auData *AmongUsData) ApplyPlayerUpdate(update Player) (bool, bool, *PlayerData) {
 	auData.lock.Lock()
 	defer auData.lock.Unlock()
 
-	if _, ok := auData.playerData[update.Name]; !ok {
-		auData.playerData[update.Name] = &PlayerData{
-			Color:   update.Color,
-			Name:    update.Name,
-			IsAlive: !update.IsDead,
+	key := auData.findPlayer(update)
+	if key == -1 {
+		var replaced *PlayerData
+		for k, v := range auData.playerData {
+			if v.Color == update.Color {
+				logger.Debugf("%s joined with the color of %s; replacing them", update.Name, v.Name)
+				replaced = v
+				delete(auData.playerData, k)
+				break
+			}
+		}
+		auData.playerData[auData.nextKey] = &PlayerData{
+			Color:    update.Color,
+			Name:     update.Name,
+			IsAlive:  !update.IsDead,
+			ClientID: update.ClientID,
+			lock:     &auData.lock,
 		}
-		log.Printf("Added new player instance for %s\n", update.Name)
-		return true, false
+		auData.nextKey++
+		logger.Debugf("Added new player instance for %s", update.Name)
+		return true, false, replaced
 	}
-	guildDataTempPtr := auData.playerData[update.Name]
-	isUpdate := guildDataTempPtr.isDifferent(update)
-	isAliveUpdate := (*auData.playerData[update.Name]).IsAlive != !update.IsDead
+
+	playerData := auData.playerData[key]
+	isUpdate := playerData.isDifferent(update)
+	isAliveUpdate := playerData.IsAlive != !update.IsDead
 	if isUpdate {
-		(*auData.playerData[update.Name]).Color = update.Color
-		(*auData.playerData[update.Name]).Name = update.Name
-		(*auData.playerData[update.Name]).IsAlive = !update.IsDead
-		log.Printf("Updated %s", (*auData.playerData[update.Name]).ToString())
+		if playerData.Name != update.Name {
+			logger.Debugf("Player %s renamed to %s", playerData.Name, update.Name)
+		}
+		playerData.Color = update.Color
+		playerData.Name = update.Name
+		playerData.IsAlive = !update.IsDead
+		if update.ClientID != 0 {
+			playerData.ClientID = update.ClientID
+		}
+		logger.Debugf("Updated %s", playerData.ToString())
 	}
 
-	return isUpdate, isAliveUpdate
+	return isUpdate, isAliveUpdate, nil
+}
+
+// RemovePlayer forgets the player an update is about, like when they disconnect, and returns them (or nil if they
+// weren't known) so users linked to them can be unlinked
+func (auData *AmongUsData) RemovePlayer(update Player) *PlayerData {
+	auData.lock.Lock()
+	defer auData.lock.Unlock()
+
+	key := auData.findPlayer(update)
+	if key == -1 {
+		return nil
+	}
+	playerData := auData.playerData[key]
+	delete(auData.playerData, key)
+	return playerData
+}
+
+// GetAllPlayers returns copies of every player, ordered by color
+func (auData *AmongUsData) GetAllPlayers() []PlayerData {
+	auData.lock.RLock()
+	defer auData.lock.RUnlock()
+
+	players := make([]PlayerData, 0, len(auData.playerData))
+	for _, v := range auData.playerData {
+		players = append(players, *v)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Color < players[j].Color
+	})
+	return players
 }
 
 func (auData *AmongUsData) GetByColor(text string) *PlayerData {
@@ -130,15 +249,27 @@ func (auData *AmongUsData) GetByColor(text string) *PlayerData {
 	return nil
 }
 
+// GetByName finds a player by name, ignoring case and spaces. When more than one player has the name, it's
+// ambiguous, and nil is returned; see GetAllByName
 func (auData *AmongUsData) GetByName(text string) *PlayerData {
-	text = strings.ToLower(text)
+	players := auData.GetAllByName(text)
+	if len(players) != 1 {
+		return nil
+	}
+	return players[0]
+}
+
+// GetAllByName finds every player with a name, ignoring case and spaces
+func (auData *AmongUsData) GetAllByName(text string) []*PlayerData {
+	text = strings.ReplaceAll(strings.ToLower(text), " ", "")
 	auData.lock.RLock()
 	defer auData.lock.RUnlock()
 
+	players := make([]*PlayerData, 0)
 	for _, playerData := range auData.playerData {
 		if strings.ReplaceAll(strings.ToLower(playerData.Name), " ", "") == text {
-			return playerData
+			players = append(players, playerData)
 		}
 	}
-	return nil
+	return players
 }
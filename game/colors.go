@@ -1,6 +1,6 @@
 package game
 
-// Color : Int constant mapping
+// Color : Int constant mapping. The values are the color IDs the game (and the capture) use
 const (
 	Red    = 0
 	Blue   = 1
@@ -14,32 +14,73 @@ const (
 	Brown  = 9
 	Cyan   = 10
 	Lime   = 11
+	Maroon = 12
+	Rose   = 13
+	Banana = 14
+	Gray   = 15
+	Tan    = 16
+	Coral  = 17
 )
 
-// ColorStrings for lowercase, possibly for translation if needed
-var ColorStrings = map[string]int{
-	"red":    Red,
-	"blue":   Blue,
-	"green":  Green,
-	"pink":   Pink,
-	"orange": Orange,
-	"yellow": Yellow,
-	"black":  Black,
-	"white":  White,
-	"purple": Purple,
-	"brown":  Brown,
-	"cyan":   Cyan,
-	"lime":   Lime,
+// ColorInfo describes one of the player colors
+type ColorInfo struct {
+	ID int
+	//Name is the lowercase english name of the color
+	Name string
+	//Aliases are other names players might link the color by
+	Aliases []string
+	//RGB is what the color looks like in the game
+	RGB int
+	//Emoji is the unicode emoji that looks the most like the color
+	Emoji string
+}
+
+// Palette is every player color, in order of ID. Supporting a new color only takes adding it here (and naming it in
+// the locale catalogs, and adding its emoji assets)
+var Palette = []ColorInfo{
+	{ID: Red, Name: "red", RGB: 0xC51111, Emoji: "🔴"},
+	{ID: Blue, Name: "blue", RGB: 0x132ED1, Emoji: "🔵"},
+	{ID: Green, Name: "green", RGB: 0x117F2D, Emoji: "🟢"},
+	{ID: Pink, Name: "pink", RGB: 0xED54BA, Emoji: "🌸"},
+	{ID: Orange, Name: "orange", RGB: 0xEF7D0D, Emoji: "🟠"},
+	{ID: Yellow, Name: "yellow", RGB: 0xF5F557, Emoji: "🟡"},
+	{ID: Black, Name: "black", RGB: 0x3F474E, Emoji: "⚫"},
+	{ID: White, Name: "white", RGB: 0xD6E0F0, Emoji: "⚪"},
+	{ID: Purple, Name: "purple", RGB: 0x6B2FBB, Emoji: "🟣"},
+	{ID: Brown, Name: "brown", RGB: 0x71491E, Emoji: "🟤"},
+	{ID: Cyan, Name: "cyan", RGB: 0x38FEDC, Emoji: "💠"},
+	{ID: Lime, Name: "lime", RGB: 0x50EF39, Emoji: "🍏"},
+	{ID: Maroon, Name: "maroon", RGB: 0x6B2B3C, Emoji: "🍷"},
+	{ID: Rose, Name: "rose", RGB: 0xECC0D3, Emoji: "🌹"},
+	{ID: Banana, Name: "banana", RGB: 0xFFFEBE, Emoji: "🍌"},
+	{ID: Gray, Name: "gray", Aliases: []string{"grey"}, RGB: 0x708496, Emoji: "⚙️"},
+	{ID: Tan, Name: "tan", Aliases: []string{"beige"}, RGB: 0x928776, Emoji: "🥜"},
+	{ID: Coral, Name: "coral", RGB: 0xEC7578, Emoji: "🍑"},
+}
+
+// NumColors is how many player colors there are
+var NumColors = len(Palette)
+
+// ColorStrings for lowercase, possibly for translation if needed. Includes the aliases of colors
+var ColorStrings = makeColorStrings()
+
+func makeColorStrings() map[string]int {
+	colorStrings := map[string]int{}
+	for _, v := range Palette {
+		colorStrings[v.Name] = v.ID
+		for _, alias := range v.Aliases {
+			colorStrings[alias] = v.ID
+		}
+	}
+	return colorStrings
 }
 
 // GetColorStringForInt does what it sounds like
 func GetColorStringForInt(colorint int) string {
-	for str, idx := range ColorStrings {
-		if idx == colorint {
-			return str
-		}
+	if colorint < 0 || colorint >= len(Palette) {
+		return ""
 	}
-	return ""
+	return Palette[colorint].Name
 }
 
 // IsColorString determines if a string is actually one of our colors
@@ -48,4 +48,6 @@ type Player struct {
 	Color        int          `json:"Color"`
 	IsDead       bool         `json:"IsDead"`
 	Disconnected bool         `json:"Disconnected"`
+	//ClientID is sent by captures that know it; older captures leave it out
+	ClientID int `json:"ClientID,omitempty"`
 }
@@ -1,6 +1,8 @@
 package game
 
 import (
+	"time"
+
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -17,7 +19,9 @@ type User struct {
 type UserData struct {
 	user               User
 	pendingVoiceUpdate bool
+	pendingSince       time.Time
 	auData             *PlayerData //we want to point to player data that isn't necessarily correlated with a player yet...
+	gameID             string      //which of the guild's games auData belongs to
 }
 
 func MakeUserDataFromDiscordUser(dUser *discordgo.User, nick string) UserData {
@@ -37,7 +41,7 @@ func MakeUserDataFromDiscordUser(dUser *discordgo.User, nick string) UserData {
 // IsAlive for a user
 func (user *UserData) IsAlive() bool {
 	if user.auData != nil {
-		return user.auData.IsAlive
+		return user.auData.Snapshot().IsAlive
 	}
 	return true //Assume that users we can't correlate to among us game data are always alive (safer policy)
 }
@@ -52,12 +56,26 @@ func (user *UserData) IsPendingVoiceUpdate() bool {
 
 func (user *UserData) SetPendingVoiceUpdate(is bool) {
 	user.pendingVoiceUpdate = is
+	if is {
+		user.pendingSince = time.Now()
+	} else {
+		user.pendingSince = time.Time{}
+	}
+}
+
+// IsPendingVoiceUpdateTimedOut determines if we've been waiting on a voice update for longer than the timeout
+func (user *UserData) IsPendingVoiceUpdateTimedOut(timeout time.Duration) bool {
+	return user.pendingVoiceUpdate && time.Since(user.pendingSince) > timeout
 }
 
 func (user *UserData) GetNickName() string {
 	return user.user.nick
 }
 
+func (user *UserData) SetNickName(nick string) {
+	user.user.nick = nick
+}
+
 func (user *UserData) GetOriginalNickName() string {
 	return user.user.originalNick
 }
@@ -76,19 +94,29 @@ func (user *UserData) GetID() string {
 
 func (user *UserData) GetPlayerName() string {
 	if user.auData != nil {
-		return user.auData.Name
+		return user.auData.Snapshot().Name
 	} else {
 		return ""
 	}
 }
 
-func (user *UserData) SetPlayerData(player *PlayerData) {
+// SetPlayerData links the user to a player in one of the guild's games; a nil player unlinks them
+func (user *UserData) SetPlayerData(gameID string, player *PlayerData) {
 	user.auData = player
+	if player == nil {
+		gameID = ""
+	}
+	user.gameID = gameID
+}
+
+// GetGameID returns the game the user is linked in, or "" if they aren't linked
+func (user *UserData) GetGameID() string {
+	return user.gameID
 }
 
 func (user *UserData) GetColor() int {
 	if user.auData != nil {
-		return user.auData.Color
+		return user.auData.Snapshot().Color
 	} else {
 		return 0
 	}
@@ -96,5 +124,17 @@ func (user *UserData) GetColor() int {
 
 // AmongUsPlayerMatch determines if a player is in the game
 func (user *UserData) AmongUsPlayerMatch(player Player) bool {
-	return user.auData != nil && user.auData.Color == player.Color && user.auData.Name == player.Name
+	if user.auData == nil {
+		return false
+	}
+	auData := user.auData.Snapshot()
+	if player.ClientID != 0 && auData.ClientID != 0 {
+		return auData.ClientID == player.ClientID
+	}
+	return auData.Color == player.Color && auData.Name == player.Name
+}
+
+// IsLinkedTo is true when the user is linked to exactly that player, not just one with the same name or color
+func (user *UserData) IsLinkedTo(player *PlayerData) bool {
+	return player != nil && user.auData == player
 }
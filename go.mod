@@ -1,6 +1,6 @@
 module github.com/denverquane/amongusdiscord
 
-go 1.13
+go 1.16
 
 require (
 	github.com/BurntSushi/xgb v0.0.0-20200324125942-20f126ea2843 // indirect
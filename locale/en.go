@@ -0,0 +1,237 @@
+package locale
+
+import "github.com/denverquane/amongusdiscord/game"
+
+// English is the reference catalog; every message ID must be in it
+const English = "en"
+
+var english = Catalog{
+	Name: "English",
+	Messages: map[string]string{
+		//help; %[1]s is the command prefix
+		"help.header":   "Among Us Bot command reference:",
+		"help.support":  "Having issues or have suggestions? Join the discord at <https://discord.gg/ZkqZSWF>!",
+		"help.help":     "`%[1]s help` or `%[1]s h`: Print help info and command usage.",
+		"help.new":      "`%[1]s new` or `%[1]s n`: Start the game in this text channel. Accepts room code and region as arguments. Ex: `%[1]s new CODE eu`. Also works for restarting.",
+		"help.refresh":  "`%[1]s refresh` or `%[1]s r`: Remake the bot's status message entirely, in case it ends up too far up in the chat.",
+		"help.end":      "`%[1]s end` or `%[1]s e`: End the game entirely, and stop tracking players. Unmutes all and resets state.",
+		"help.track":    "`%[1]s track` or `%[1]s t`: Instruct bot to only use the provided voice channel for automute. Ex: `%[1]s t <vc_name>`",
+		"help.link":     "`%[1]s link` or `%[1]s l`: Manually link a player to their in-game name or color. Ex: `%[1]s l @player cyan` or `%[1]s l @player bob`",
+		"help.unlink":   "`%[1]s unlink` or `%[1]s u`: Manually unlink a player. Ex: `%[1]s u @player`",
+		"help.force":    "`%[1]s force` or `%[1]s f`: Force a transition to a stage if you encounter a problem in the state. Ex: `%[1]s f task` or `%[1]s f d`(discuss)",
+		"help.nickname": "`%[1]s nickname` or `%[1]s nick`: Rename linked players while a game runs, optionally with a template using {ign}, {color} and {user}. Ex: `%[1]s nick on` or `%[1]s nick template {ign} ({color})`",
+		"help.priority": "`%[1]s priority` or `%[1]s p`: Set the order voice changes are applied in for a transition, as groups (alive, dead, muting, unmuting, any) with optional delay and stagger in ms. Ex: `%[1]s p t d dead:0:500 alive:0:500` or `%[1]s p reset`",
+		"help.drift":    "`%[1]s drift`: Show how often discord's mutes/deafens didn't match what the bot wanted, and were corrected. Use `%[1]s drift now` to check right away",
+		"help.dryrun":   "`%[1]s dryrun` or `%[1]s dry`: Post the mutes/deafens/nicknames the bot would apply to a channel, instead of applying them. Ex: `%[1]s dry on #bot-log` or `%[1]s dry off`",
+		"help.audit":    "`%[1]s audit`: Post a record of game starts and ends, phase changes, links and unlinks, and mutes/deafens that failed to a channel. Ex: `%[1]s audit on #bot-log` or `%[1]s audit off`",
+		"help.webhook":  "`%[1]s webhook`: Send game, phase, player and link events to your own HTTPS endpoints, signed with a secret I DM you. `%[1]s webhook add <url> [events]`, `remove <id>`, `test <id>`, `failed` lists events that couldn't be sent, and `retry` sends them again. Ex: `%[1]s webhook add https://example.com/hook phase playerDied`",
+		"help.overlay":  "`%[1]s overlay`: DM you a link to an overlay for OBS (as a browser source) showing the players of this server's games, with the same spoiler settings as the status message. `%[1]s overlay reset` makes a new link and stops the old one working, and `%[1]s overlay off` turns it off",
+		"help.games":    "`%[1]s games` or `%[1]s g`: List the games running in this server. `%[1]s new` from a voice channel no other game tracks starts another game",
+		"help.rules":    "`%[1]s rules`: Set the voice rule preset one game uses. Ex: `%[1]s rules deafen`, `%[1]s rules mute` or `%[1]s rules open`",
+		"help.preset":   "`%[1]s preset` or `%[1]s pr`: List voice rule presets, create custom ones from `phase:group:action` rules, or give a voice channel its own preset. Ex: `%[1]s pr create comp tasks:alive:deaf discuss:dead:mute` or `%[1]s pr channel open Casual VC`",
+		"help.template": "`%[1]s template` or `%[1]s tmpl`: Customize the status message of each phase with Go templates, like `{{.Room}}` or `{{range .Players}}`. Ex: `%[1]s tmpl lobby title Lobby {{.Room}}`, `%[1]s tmpl d color #9b59b6`, `%[1]s tmpl preview`, `%[1]s tmpl thumbnail bot` or `%[1]s tmpl reset`",
+		"help.language": "`%[1]s language` or `%[1]s lang`: Change the language the bot uses in this server. Ex: `%[1]s lang es`",
+		"help.spoilers": "`%[1]s spoilers`: Choose what the status message shows in each phase: who is dead (alive), in-game names (names), who is linked (links) and the order players died in (deaths). Ex: `%[1]s spoilers tasks alive off`, `%[1]s spoilers d deaths on` or `%[1]s spoilers reset`",
+		"help.emojis":   "`%[1]s emojis`: Manage the emojis the bot adds to the server. `%[1]s emojis sync` adds missing ones and updates changed ones, `%[1]s emojis cleanup` removes them all, and `%[1]s emojis status` shows which ones are missing",
+		"help.gameID":   "When more than one game is running, put the game ID before a command to pick the game. Ex: `%[1]s 2 end` or `%[1]s 2 f d`",
+
+		//replies to commands
+		"reply.usage":             "You used this command incorrectly! Please refer to `%s help` for proper command usage",
+		"reply.unknownCommand":    "Sorry, I didn't understand that command! Please see `%s help` for commands",
+		"reply.unknownPhase":      "Sorry, I didn't understand the game phase you tried to force",
+		"reply.whichGame":         "I'm not sure which game you mean: %s",
+		"reply.badChannel":        "I couldn't understand the channel %s; please mention it like #channel",
+		"reply.nicknameTemplate":  "Nickname templates need to include `{ign}` or `{color}`. Ex: `{ign} ({color})`",
+		"reply.badOrdering":       "I couldn't understand that ordering: %s",
+		"reply.unknownPreset":     "There's no preset named `%s`; see `%s preset` for the presets",
+		"reply.noVoiceChannel":    "I couldn't find that voice channel; give its name, or join it and leave the name out",
+		"reply.presetError":       "I couldn't change the presets: %s",
+		"reply.templateBroken":    "That template doesn't work: %s",
+		"reply.templateNotSaved":  "I didn't save that template, because it doesn't work: %s",
+		"reply.templateField":     "Fields are given as `name | value`. Ex: `%s tmpl lobby field Room | {{.Room}}`",
+		"reply.templateSaved":     "Saved! Use `%s tmpl preview` to see how it looks",
+		"reply.cantRename":        "I won't be able to change the nickname of <@!%s>: %s",
+		"reply.language":          "I'm speaking %s in this server. Languages I know: %s",
+		"reply.unknownLanguage":   "I don't know the language `%s`. Languages I know: %s",
+		"reply.trackAlreadyTaken": "\"%s\" is already tracked by game %s!",
+		"reply.trackNow":          "Now tracking \"%s\" Voice Channel for Automute (for ghosts? %v)!",
+		"reply.disclosureError":   "I couldn't change that: %s",
+		"reply.emojisError":       "I couldn't remove the emojis: %s",
+		"reply.emojisRemoved":     "Removed %d emoji(s). Use `%s emojis sync` to add them back",
+		"reply.emojisStatus":      "%d of %d emojis are in the server. The missing ones (⚠️) are shown as the unicode emoji next to them instead; free up emoji slots and use `%s emojis sync` to add them",
+		"reply.emojisSynced":      "The emojis are up to date!",
+		"reply.alreadyLinked":     "That player is already linked to <@!%s>; unlink them first with `%s unlink`",
+		"reply.ambiguousName":     "More than one player is named %s; link them by color instead",
+		"reply.trackNotFound":     "No channel found by the name %s!",
+		"reply.auditOn":           "Posting the audit log to <#%s>",
+		"reply.auditOff":          "The audit log is off; turn it on with `%s audit on #channel`",
+		"reply.webhookError":      "I couldn't do that: %s",
+		"reply.overlayLink":       "Add this as a browser source in OBS, and keep it to yourself; anyone with it can watch the games: %s\nAdd `&game=<id>` to only show one game",
+		"reply.overlaySent":       "I've DMed you the overlay link. If it ever gets out, use `%s overlay reset`",
+		"reply.overlayReset":      "I've DMed you a new overlay link; the old one doesn't work anymore",
+		"reply.overlayNoDM":       "I couldn't DM you the overlay link; allow DMs from server members and try again",
+		"reply.overlayOff":        "The overlay is off. `%s overlay` turns it back on, with a new link",
+		"reply.webhookAdded":      "Added webhook `%s` for %s, and sent you its secret. Check it works with `%s webhook test %[1]s`",
+		"reply.webhookNoDM":       "Added webhook `%s`, but I couldn't DM you its secret; remove it with `%s webhook remove %[1]s`, allow DMs from server members, and add it again",
+		"reply.webhookSecret":     "The secret of webhook `%s` (%s) is `%s`. Every request is signed with it in the X-AutoMute-Signature header",
+		"reply.webhookRemoved":    "Removed webhook `%s`",
+		"reply.webhookNotFound":   "There's no webhook with the ID `%s`",
+		"reply.webhookTestOK":     "Webhook `%s` got the test event: %s",
+		"reply.webhookTestFailed": "Webhook `%s` didn't accept the test event: %s",
+		"reply.webhookRetried":    "Sending %d event(s) again",
+		"reply.webhookAllEvents":  "every event",
+		"reply.webhooks":          "Webhooks (%d event(s) couldn't be sent; see `%s webhook failed`):",
+		"reply.noWebhooks":        "There are no webhooks. Add one with `%s webhook add <url>`",
+		"reply.noDeadLetters":     "Every event was sent!",
+		"reply.deadLetters":       "%d event(s) couldn't be sent; `%s webhook retry` sends them again. The most recent:",
+		"reply.deadLetter":        "`%s` webhook `%s`, %s, %d attempt(s): %s",
+		"reply.rules":             "Game %s uses the `%s` rules: %s",
+		"reply.priorities":        "Order voice changes are applied in for each transition (`group:delayMs:staggerMs`):",
+		"reply.presets":           "Voice rule presets:",
+		"reply.presetBuiltin":     "built-in",
+		"reply.presetCustom":      "custom",
+		"reply.noChannelPresets":  "No voice channels have their own preset; they use the rules of the game",
+		"reply.channelPresets":    "Voice channels with their own preset:",
+		"reply.disclosure":        "What the status message shows in each phase:",
+		"reply.disclosurePhase":   "%s: shows %s",
+		"reply.disclosureNothing": "nothing",
+		"reply.dryRunOff":         "Dry run is disabled; voice and nickname changes will be applied",
+		"reply.dryRunOn":          "Dry run is enabled; voice and nickname changes will be posted to <#%s> instead of being applied",
+		"reply.noGames":           "There's no game running",
+		"reply.game":              "**Game %s**: %s, room %s (%s), tracking %s, capture %s, %d player(s) linked",
+		"reply.captureLinked":     "linked",
+		"reply.captureNotLinked":  "not linked (code `%s`)",
+		"reply.nicknamesOff":      "Nicknames are disabled; linked users keep their own nickname",
+		"reply.nicknamesOn":       "Nicknames are enabled; linked users are renamed to `%s` (for example, `%s`) and get their original nickname back when the game ends",
+		"reply.driftNotRun":       "The reconciler hasn't run yet",
+		"reply.drift":             "Last reconcile %s ago found %d member(s) out of date. Over %d runs, %d patch(es) were re-issued, %d of them after timing out",
+
+		//who is muted or deafened by voice rules
+		"rules.open":          "nobody is muted or deafened",
+		"rules.aliveDeafened": "alive deafened",
+		"rules.aliveMuted":    "alive muted",
+		"rules.deadDeafened":  "dead deafened",
+		"rules.deadMuted":     "dead muted",
+
+		//status message templates
+		"template.header":          "Status message template for **%s**:",
+		"template.title":           "Title: `%s`",
+		"template.description":     "Description: `%s`",
+		"template.footer":          "Footer: `%s`",
+		"template.color":           "Color: `#%06x`",
+		"template.defaultFields":   "Fields: room code, region, tracking and linked players",
+		"template.field":           "Field %d: `%s` | `%s`",
+		"template.noThumbnail":     "Thumbnail: none",
+		"template.thumbnail":       "Thumbnail: <%s>",
+		"template.titlePart":       "the title",
+		"template.descriptionPart": "the description",
+		"template.footerPart":      "the footer",
+		"template.fieldNamePart":   "the name of field %d",
+		"template.fieldValuePart":  "the value of field %d",
+
+		//why a command couldn't be done; shown after a reply like reply.presetError
+		"error.noGameID":             "there's no game with the ID %s",
+		"error.noGame":               "there's no game running",
+		"error.manyGames":            "there are %d games running; put the game ID before the command, like `%s %s end`",
+		"error.builtinPreset":        "`%s` is a built-in preset, and can't be changed",
+		"error.numericPreset":        "preset names can't be numbers",
+		"error.noCustomPreset":       "there's no custom preset named `%s`",
+		"error.noPreset":             "there's no preset named `%s`",
+		"error.noRules":              "no rules provided",
+		"error.ruleFormat":           "`%s` should look like phase:group:action, like tasks:alive:deaf",
+		"error.unknownPhase":         "unknown phase `%s`; use lobby, tasks or discuss",
+		"error.unknownRuleGroup":     "unknown group `%s`; use alive, dead or all",
+		"error.unknownAction":        "unknown action `%s`; use deaf, mute or none",
+		"error.unknownPriorityGroup": "unknown group `%s`; use alive, dead, muting, unmuting or any",
+		"error.tooManyValues":        "too many values in `%s`",
+		"error.badDelay":             "`%s` should be a number of milliseconds between 0 and 10000",
+		"error.noGroups":             "no groups provided",
+		"error.unknownPart":          "unknown part `%s`; use alive, names, links or deaths",
+		"error.unknownEvent":         "unknown event type `%s`",
+		"error.webhookURL":           "webhook URLs have to start with https:// or http://",
+		"error.tooManyWebhooks":      "a server can't have more than %d webhooks",
+		"error.noWebhook":            "there's no webhook with the ID %s",
+		"error.tooLong":              "%s is %d characters long, but discord only allows %d",
+		"error.templateColor":        "%s: the color should be between #000000 and #FFFFFF",
+		"error.tooManyFields":        "%s: there can't be more than %d fields",
+		"error.inPhase":              "%s: %s",
+		"error.thumbnailURL":         "the thumbnail should be an http(s) URL",
+		"error.badColor":             "`%s` isn't a color; use a hex code like #2ecc71",
+		"error.renameOwner":          "they own the server, and discord doesn't let bots rename server owners",
+		"error.renamePermission":     "I don't have the Manage Nicknames permission",
+		"error.renameRole":           "their highest role is above mine; move the bot up in your Roles",
+
+		//voice changes waiting to be applied, and dry runs of them
+		"countdown.applying":  "Applying voice changes",
+		"countdown.updating":  "Updating %s players",
+		"countdown.remaining": "%s in %ds",
+		"dryRun.header":       "**Dry run** (%s): %d change(s) would be applied",
+		"dryRun.phase":        "game %s, phase %s, after a %d second delay",
+		"dryRun.voiceState":   "voice state change",
+
+		//audit log; the last argument is who or what did it
+		"audit.gameStart":       "Game %s started by %s",
+		"audit.gameEnd":         "Game %s ended by %s",
+		"audit.phase":           "Game %s: %s → %s",
+		"audit.forcedPhase":     "Game %s: %s → %s, forced by %s",
+		"audit.link":            "Game %s: <@!%s> linked to %s (%s) by %s",
+		"audit.unlink":          "Game %s: <@!%s> unlinked by %s",
+		"audit.patchFailed":     "Failed to change the voice state of <@!%s>: %s",
+		"audit.dropped":         "⚠️ %d older record(s) were dropped, because too many were waiting to be posted",
+		"audit.byUser":          "<@!%s> (%s)",
+		"audit.source.command":  "command",
+		"audit.source.reaction": "reaction",
+		"audit.source.capture":  "the capture",
+		"audit.source.adminAPI": "the admin API",
+		"audit.source.bot":      "the bot",
+
+		//status message
+		"embed.lobby.title":         "Lobby is Open! (Game {{.GameID}})",
+		"embed.lobby.description":   "{{if .CaptureLinked}}Successfully linked to capture!{{else}}{{.Alarm}}**No capture linked! Enter the code `{{.LinkCode}}` in your capture to connect!**{{.Alarm}}{{end}}",
+		"embed.lobby.footer":        "React to this message with your in-game color! (or ❌ to leave)",
+		"embed.running.title":       "Game {{.GameID}} is Running",
+		"embed.running.description": "Current Phase: {{.Phase}}",
+		"field.room":                "Room Code",
+		"field.region":              "Region",
+		"field.tracking":            "Tracking",
+		"field.linked":              "Players Linked",
+		"field.voiceRules":          "Voice Rules",
+		"field.voiceChanges":        "Voice Changes",
+		"field.deaths":              "Death Order",
+		"tracking.any":              "Any Voice Channel",
+		"tracking.ghosts":           "(ghosts)",
+		"tracking.or":               "or",
+
+		//message asking for the color of a user
+		"private.userID":   "User ID",
+		"private.username": "Username",
+		"private.title":    "What color is %s?",
+		"private.footer":   "React to this message with %s's color! (or ❌ to skip)",
+	},
+	Colors: map[int]string{
+		game.Red:    "red",
+		game.Blue:   "blue",
+		game.Green:  "green",
+		game.Pink:   "pink",
+		game.Orange: "orange",
+		game.Yellow: "yellow",
+		game.Black:  "black",
+		game.White:  "white",
+		game.Purple: "purple",
+		game.Brown:  "brown",
+		game.Cyan:   "cyan",
+		game.Lime:   "lime",
+		game.Maroon: "maroon",
+		game.Rose:   "rose",
+		game.Banana: "banana",
+		game.Gray:   "gray",
+		game.Tan:    "tan",
+		game.Coral:  "coral",
+	},
+	Phases: map[game.Phase]string{
+		game.LOBBY:   "LOBBY",
+		game.TASKS:   "TASKS",
+		game.DISCUSS: "DISCUSSION",
+		game.MENU:    "MENU",
+	},
+}
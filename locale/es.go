@@ -0,0 +1,233 @@
+package locale
+
+import "github.com/denverquane/amongusdiscord/game"
+
+// Spanish is shipped as a reference for other translations
+const Spanish = "es"
+
+var spanish = Catalog{
+	Name: "Español",
+	Messages: map[string]string{
+		"help.header":   "Comandos del bot de Among Us:",
+		"help.support":  "¿Tienes problemas o sugerencias? ¡Únete al discord en <https://discord.gg/ZkqZSWF>!",
+		"help.help":     "`%[1]s help` o `%[1]s h`: Muestra la ayuda y cómo usar los comandos.",
+		"help.new":      "`%[1]s new` o `%[1]s n`: Empieza la partida en este canal de texto. Acepta el código de sala y la región. Ej: `%[1]s new CODIGO eu`. También sirve para reiniciarla.",
+		"help.refresh":  "`%[1]s refresh` o `%[1]s r`: Vuelve a crear el mensaje de estado del bot, por si ha quedado muy arriba en el chat.",
+		"help.end":      "`%[1]s end` o `%[1]s e`: Termina la partida y deja de seguir a los jugadores. Desmutea a todos y reinicia el estado.",
+		"help.track":    "`%[1]s track` o `%[1]s t`: Indica al bot que solo use el canal de voz dado para mutear. Ej: `%[1]s t <canal_de_voz>`",
+		"help.link":     "`%[1]s link` o `%[1]s l`: Vincula a mano a un jugador con su nombre o color en el juego. Ej: `%[1]s l @jugador cian` o `%[1]s l @jugador bob`",
+		"help.unlink":   "`%[1]s unlink` o `%[1]s u`: Desvincula a mano a un jugador. Ej: `%[1]s u @jugador`",
+		"help.force":    "`%[1]s force` o `%[1]s f`: Fuerza el cambio a una fase si el estado no es correcto. Ej: `%[1]s f task` o `%[1]s f d` (discusión)",
+		"help.nickname": "`%[1]s nickname` o `%[1]s nick`: Cambia el apodo de los jugadores vinculados durante la partida, opcionalmente con una plantilla con {ign}, {color} y {user}. Ej: `%[1]s nick on` o `%[1]s nick template {ign} ({color})`",
+		"help.priority": "`%[1]s priority` o `%[1]s p`: Elige el orden en que se aplican los cambios de voz en una transición, por grupos (alive, dead, muting, unmuting, any) con retraso y escalonado opcionales en ms. Ej: `%[1]s p t d dead:0:500 alive:0:500` o `%[1]s p reset`",
+		"help.drift":    "`%[1]s drift`: Muestra cuántas veces el muteo de discord no coincidía con lo que quería el bot, y se corrigió. Usa `%[1]s drift now` para comprobarlo ya",
+		"help.dryrun":   "`%[1]s dryrun` o `%[1]s dry`: Publica en un canal los muteos/apodos que aplicaría el bot, en vez de aplicarlos. Ej: `%[1]s dry on #bot-log` o `%[1]s dry off`",
+		"help.audit":    "`%[1]s audit`: Publica en un canal un registro de inicios y finales de partida, cambios de fase, vinculaciones y muteos que fallaron. Ej: `%[1]s audit on #bot-log` o `%[1]s audit off`",
+		"help.webhook":  "`%[1]s webhook`: Envía eventos de partidas, fases, jugadores y vinculaciones a tus propios endpoints HTTPS, firmados con un secreto que te envío por DM. `%[1]s webhook add <url> [eventos]`, `remove <id>`, `test <id>`, `failed` lista los eventos que no se pudieron enviar, y `retry` los vuelve a enviar. Ej: `%[1]s webhook add https://example.com/hook phase playerDied`",
+		"help.overlay":  "`%[1]s overlay`: Te envía por DM un enlace a un overlay para OBS (como fuente de navegador) que muestra los jugadores de las partidas de este servidor, con los mismos ajustes de spoilers que el mensaje de estado. `%[1]s overlay reset` crea un enlace nuevo y desactiva el anterior, y `%[1]s overlay off` lo apaga",
+		"help.games":    "`%[1]s games` o `%[1]s g`: Lista las partidas en curso en este servidor. `%[1]s new` desde un canal de voz que no siga otra partida empieza otra partida",
+		"help.rules":    "`%[1]s rules`: Elige el preset de reglas de voz de una partida. Ej: `%[1]s rules deafen`, `%[1]s rules mute` o `%[1]s rules open`",
+		"help.preset":   "`%[1]s preset` o `%[1]s pr`: Lista los presets de reglas de voz, crea presets propios con reglas `fase:grupo:acción`, o asigna un preset a un canal de voz. Ej: `%[1]s pr create comp tasks:alive:deaf discuss:dead:mute` o `%[1]s pr channel open Canal Casual`",
+		"help.template": "`%[1]s template` o `%[1]s tmpl`: Personaliza el mensaje de estado de cada fase con plantillas de Go, como `{{.Room}}` o `{{range .Players}}`. Ej: `%[1]s tmpl lobby title Sala {{.Room}}`, `%[1]s tmpl d color #9b59b6`, `%[1]s tmpl preview`, `%[1]s tmpl thumbnail bot` o `%[1]s tmpl reset`",
+		"help.language": "`%[1]s language` o `%[1]s lang`: Cambia el idioma que usa el bot en este servidor. Ej: `%[1]s lang en`",
+		"help.spoilers": "`%[1]s spoilers`: Elige qué muestra el mensaje de estado en cada fase: quién ha muerto (alive), los nombres del juego (names), quién está vinculado (links) y el orden de muertes (deaths). Ej: `%[1]s spoilers tasks alive off`, `%[1]s spoilers d deaths on` o `%[1]s spoilers reset`",
+		"help.emojis":   "`%[1]s emojis`: Gestiona los emojis que el bot añade al servidor. `%[1]s emojis sync` añade los que faltan y actualiza los que han cambiado, `%[1]s emojis cleanup` los quita todos, y `%[1]s emojis status` muestra cuáles faltan",
+		"help.gameID":   "Si hay más de una partida en curso, pon el ID de la partida antes del comando para elegirla. Ej: `%[1]s 2 end` o `%[1]s 2 f d`",
+
+		"reply.usage":             "¡Has usado mal este comando! Consulta `%s help` para ver cómo se usa",
+		"reply.unknownCommand":    "¡Perdona, no he entendido ese comando! Consulta `%s help` para ver los comandos",
+		"reply.unknownPhase":      "Perdona, no he entendido la fase que querías forzar",
+		"reply.whichGame":         "No sé a qué partida te refieres: %s",
+		"reply.badChannel":        "No he entendido el canal %s; menciónalo así: #canal",
+		"reply.nicknameTemplate":  "Las plantillas de apodo deben incluir `{ign}` o `{color}`. Ej: `{ign} ({color})`",
+		"reply.badOrdering":       "No he entendido ese orden: %s",
+		"reply.unknownPreset":     "No hay ningún preset llamado `%s`; consulta `%s preset` para verlos",
+		"reply.noVoiceChannel":    "No he encontrado ese canal de voz; dime su nombre, o entra en él y no pongas el nombre",
+		"reply.presetError":       "No he podido cambiar los presets: %s",
+		"reply.templateBroken":    "Esa plantilla no funciona: %s",
+		"reply.templateNotSaved":  "No he guardado esa plantilla porque no funciona: %s",
+		"reply.templateField":     "Los campos se escriben como `nombre | valor`. Ej: `%s tmpl lobby field Sala | {{.Room}}`",
+		"reply.templateSaved":     "¡Guardado! Usa `%s tmpl preview` para ver cómo queda",
+		"reply.cantRename":        "No podré cambiar el apodo de <@!%s>: %s",
+		"reply.language":          "En este servidor hablo %s. Idiomas que conozco: %s",
+		"reply.unknownLanguage":   "No conozco el idioma `%s`. Idiomas que conozco: %s",
+		"reply.trackAlreadyTaken": "¡\"%s\" ya lo sigue la partida %s!",
+		"reply.trackNow":          "¡Siguiendo el canal de voz \"%s\" para mutear (¿para fantasmas? %v)!",
+		"reply.disclosureError":   "No he podido cambiarlo: %s",
+		"reply.emojisError":       "No he podido quitar los emojis: %s",
+		"reply.emojisRemoved":     "He quitado %d emoji(s). Usa `%s emojis sync` para volver a añadirlos",
+		"reply.emojisStatus":      "Hay %d de %d emojis en el servidor. Los que faltan (⚠️) se muestran con el emoji unicode que tienen al lado; libera huecos de emojis y usa `%s emojis sync` para añadirlos",
+		"reply.emojisSynced":      "¡Los emojis están al día!",
+		"reply.alreadyLinked":     "Ese jugador ya está vinculado a <@!%s>; desvincúlalo antes con `%s unlink`",
+		"reply.ambiguousName":     "Hay más de un jugador llamado %s; vincúlalo por su color",
+		"reply.trackNotFound":     "¡No hay ningún canal llamado %s!",
+		"reply.auditOn":           "Publicando el registro de auditoría en <#%s>",
+		"reply.auditOff":          "El registro de auditoría está apagado; actívalo con `%s audit on #canal`",
+		"reply.webhookError":      "No pude hacerlo: %s",
+		"reply.overlayLink":       "Añade esto como fuente de navegador en OBS, y no lo compartas; cualquiera que lo tenga puede ver las partidas: %s\nAñade `&game=<id>` para mostrar solo una partida",
+		"reply.overlaySent":       "Te envié el enlace del overlay por DM. Si alguna vez se filtra, usa `%s overlay reset`",
+		"reply.overlayReset":      "Te envié un enlace nuevo por DM; el anterior ya no funciona",
+		"reply.overlayNoDM":       "No pude enviarte el enlace por DM; permite DMs de miembros del servidor e inténtalo de nuevo",
+		"reply.overlayOff":        "El overlay está apagado. `%s overlay` lo vuelve a encender, con un enlace nuevo",
+		"reply.webhookAdded":      "Añadí el webhook `%s` para %s, y te envié su secreto. Comprueba que funciona con `%s webhook test %[1]s`",
+		"reply.webhookNoDM":       "Añadí el webhook `%s`, pero no pude enviarte su secreto por DM; quítalo con `%s webhook remove %[1]s`, permite DMs de miembros del servidor y vuelve a añadirlo",
+		"reply.webhookSecret":     "El secreto del webhook `%s` (%s) es `%s`. Cada petición se firma con él en la cabecera X-AutoMute-Signature",
+		"reply.webhookRemoved":    "Quité el webhook `%s`",
+		"reply.webhookNotFound":   "No hay ningún webhook con el ID `%s`",
+		"reply.webhookTestOK":     "El webhook `%s` recibió el evento de prueba: %s",
+		"reply.webhookTestFailed": "El webhook `%s` no aceptó el evento de prueba: %s",
+		"reply.webhookRetried":    "Enviando %d evento(s) de nuevo",
+		"reply.webhookAllEvents":  "todos los eventos",
+		"reply.webhooks":          "Webhooks (%d evento(s) no se pudieron enviar; mira `%s webhook failed`):",
+		"reply.noWebhooks":        "No hay webhooks. Añade uno con `%s webhook add <url>`",
+		"reply.noDeadLetters":     "¡Se enviaron todos los eventos!",
+		"reply.deadLetters":       "%d evento(s) no se pudieron enviar; `%s webhook retry` los vuelve a enviar. Los más recientes:",
+		"reply.deadLetter":        "`%s` webhook `%s`, %s, %d intento(s): %s",
+		"reply.rules":             "La partida %s usa las reglas `%s`: %s",
+		"reply.priorities":        "Orden en que se aplican los cambios de voz en cada transición (`grupo:retrasoMs:escalonadoMs`):",
+		"reply.presets":           "Presets de reglas de voz:",
+		"reply.presetBuiltin":     "incluido",
+		"reply.presetCustom":      "personalizado",
+		"reply.noChannelPresets":  "Ningún canal de voz tiene su propio preset; usan las reglas de la partida",
+		"reply.channelPresets":    "Canales de voz con su propio preset:",
+		"reply.disclosure":        "Lo que muestra el mensaje de estado en cada fase:",
+		"reply.disclosurePhase":   "%s: muestra %s",
+		"reply.disclosureNothing": "nada",
+		"reply.dryRunOff":         "El simulacro está apagado; los cambios de voz y de apodo se aplicarán",
+		"reply.dryRunOn":          "El simulacro está encendido; los cambios de voz y de apodo se publicarán en <#%s> en lugar de aplicarse",
+		"reply.noGames":           "No hay ninguna partida en curso",
+		"reply.game":              "**Partida %s**: %s, sala %s (%s), siguiendo %s, captura %s, %d jugador(es) vinculado(s)",
+		"reply.captureLinked":     "vinculada",
+		"reply.captureNotLinked":  "sin vincular (código `%s`)",
+		"reply.nicknamesOff":      "Los apodos están apagados; los usuarios vinculados mantienen su propio apodo",
+		"reply.nicknamesOn":       "Los apodos están encendidos; los usuarios vinculados pasan a llamarse `%s` (por ejemplo, `%s`) y recuperan su apodo cuando termina la partida",
+		"reply.driftNotRun":       "El reconciliador todavía no se ha ejecutado",
+		"reply.drift":             "La última reconciliación, hace %s, encontró %d miembro(s) desactualizado(s). En %d ejecuciones se repitieron %d cambio(s), %d de ellos tras agotar el tiempo",
+
+		//quién está muteado o ensordecido según las reglas de voz
+		"rules.open":          "nadie está muteado ni ensordecido",
+		"rules.aliveDeafened": "vivos ensordecidos",
+		"rules.aliveMuted":    "vivos muteados",
+		"rules.deadDeafened":  "muertos ensordecidos",
+		"rules.deadMuted":     "muertos muteados",
+
+		//plantillas del mensaje de estado
+		"template.header":          "Plantilla del mensaje de estado para **%s**:",
+		"template.title":           "Título: `%s`",
+		"template.description":     "Descripción: `%s`",
+		"template.footer":          "Pie: `%s`",
+		"template.color":           "Color: `#%06x`",
+		"template.defaultFields":   "Campos: código de sala, región, canales seguidos y jugadores vinculados",
+		"template.field":           "Campo %d: `%s` | `%s`",
+		"template.noThumbnail":     "Miniatura: ninguna",
+		"template.thumbnail":       "Miniatura: <%s>",
+		"template.titlePart":       "el título",
+		"template.descriptionPart": "la descripción",
+		"template.footerPart":      "el pie",
+		"template.fieldNamePart":   "el nombre del campo %d",
+		"template.fieldValuePart":  "el valor del campo %d",
+
+		//por qué no se pudo hacer un comando; se muestra tras una respuesta como reply.presetError
+		"error.noGameID":             "no hay ninguna partida con el ID %s",
+		"error.noGame":               "no hay ninguna partida en curso",
+		"error.manyGames":            "hay %d partidas en curso; pon el ID de la partida antes del comando, así: `%s %s end`",
+		"error.builtinPreset":        "`%s` es un preset incluido, y no se puede cambiar",
+		"error.numericPreset":        "los nombres de los presets no pueden ser números",
+		"error.noCustomPreset":       "no hay ningún preset personalizado llamado `%s`",
+		"error.noPreset":             "no hay ningún preset llamado `%s`",
+		"error.noRules":              "no has dado ninguna regla",
+		"error.ruleFormat":           "`%s` debería tener la forma fase:grupo:acción, como tasks:alive:deaf",
+		"error.unknownPhase":         "fase desconocida `%s`; usa lobby, tasks o discuss",
+		"error.unknownRuleGroup":     "grupo desconocido `%s`; usa alive, dead o all",
+		"error.unknownAction":        "acción desconocida `%s`; usa deaf, mute o none",
+		"error.unknownPriorityGroup": "grupo desconocido `%s`; usa alive, dead, muting, unmuting o any",
+		"error.tooManyValues":        "demasiados valores en `%s`",
+		"error.badDelay":             "`%s` debería ser un número de milisegundos entre 0 y 10000",
+		"error.noGroups":             "no has dado ningún grupo",
+		"error.unknownPart":          "parte desconocida `%s`; usa alive, names, links o deaths",
+		"error.unknownEvent":         "tipo de evento desconocido `%s`",
+		"error.webhookURL":           "las URLs de los webhooks tienen que empezar por https:// o http://",
+		"error.tooManyWebhooks":      "un servidor no puede tener más de %d webhooks",
+		"error.noWebhook":            "no hay ningún webhook con el ID %s",
+		"error.tooLong":              "%s tiene %d caracteres, pero discord solo permite %d",
+		"error.templateColor":        "%s: el color debe estar entre #000000 y #FFFFFF",
+		"error.tooManyFields":        "%s: no puede haber más de %d campos",
+		"error.inPhase":              "%s: %s",
+		"error.thumbnailURL":         "la miniatura debe ser una URL http(s)",
+		"error.badColor":             "`%s` no es un color; usa un código hexadecimal como #2ecc71",
+		"error.renameOwner":          "es el dueño del servidor, y discord no deja que los bots les cambien el apodo",
+		"error.renamePermission":     "no tengo el permiso Gestionar apodos",
+		"error.renameRole":           "su rol más alto está por encima del mío; sube el bot en tus Roles",
+
+		//cambios de voz pendientes de aplicar, y simulacros de ellos
+		"countdown.applying":  "Aplicando los cambios de voz",
+		"countdown.updating":  "Actualizando a los jugadores %s",
+		"countdown.remaining": "%s en %ds",
+		"dryRun.header":       "**Simulacro** (%s): se aplicarían %d cambio(s)",
+		"dryRun.phase":        "partida %s, fase %s, tras un retraso de %d segundos",
+		"dryRun.voiceState":   "cambio de estado de voz",
+
+		//registro de auditoría; el último argumento es quién o qué lo hizo
+		"audit.gameStart":       "Partida %s iniciada por %s",
+		"audit.gameEnd":         "Partida %s terminada por %s",
+		"audit.phase":           "Partida %s: %s → %s",
+		"audit.forcedPhase":     "Partida %s: %s → %s, forzado por %s",
+		"audit.link":            "Partida %s: <@!%s> vinculado a %s (%s) por %s",
+		"audit.unlink":          "Partida %s: <@!%s> desvinculado por %s",
+		"audit.patchFailed":     "No se pudo cambiar el estado de voz de <@!%s>: %s",
+		"audit.dropped":         "⚠️ Se descartaron %d registro(s) antiguos, porque había demasiados esperando",
+		"audit.byUser":          "<@!%s> (%s)",
+		"audit.source.command":  "comando",
+		"audit.source.reaction": "reacción",
+		"audit.source.capture":  "la captura",
+		"audit.source.adminAPI": "la API de administración",
+		"audit.source.bot":      "el bot",
+
+		"embed.lobby.title":         "¡La sala está abierta! (Partida {{.GameID}})",
+		"embed.lobby.description":   "{{if .CaptureLinked}}¡Conectado con la captura!{{else}}{{.Alarm}}**¡No hay ninguna captura conectada! ¡Introduce el código `{{.LinkCode}}` en tu captura para conectarla!**{{.Alarm}}{{end}}",
+		"embed.lobby.footer":        "¡Reacciona a este mensaje con tu color en el juego! (o ❌ para salir)",
+		"embed.running.title":       "La partida {{.GameID}} está en curso",
+		"embed.running.description": "Fase actual: {{.Phase}}",
+		"field.room":                "Código de sala",
+		"field.region":              "Región",
+		"field.tracking":            "Canales",
+		"field.linked":              "Jugadores vinculados",
+		"field.voiceRules":          "Reglas de voz",
+		"field.voiceChanges":        "Cambios de voz",
+		"field.deaths":              "Orden de muertes",
+		"tracking.any":              "Cualquier canal de voz",
+		"tracking.ghosts":           "(fantasmas)",
+		"tracking.or":               "o",
+
+		"private.userID":   "ID de usuario",
+		"private.username": "Nombre de usuario",
+		"private.title":    "¿De qué color es %s?",
+		"private.footer":   "¡Reacciona a este mensaje con el color de %s! (o ❌ para saltar)",
+	},
+	Colors: map[int]string{
+		game.Red:    "rojo",
+		game.Blue:   "azul",
+		game.Green:  "verde",
+		game.Pink:   "rosa",
+		game.Orange: "naranja",
+		game.Yellow: "amarillo",
+		game.Black:  "negro",
+		game.White:  "blanco",
+		game.Purple: "morado",
+		game.Brown:  "marrón",
+		game.Cyan:   "cian",
+		game.Lime:   "lima",
+		game.Maroon: "granate",
+		game.Rose:   "rosado",
+		game.Banana: "plátano",
+		game.Gray:   "gris",
+		game.Tan:    "canela",
+		game.Coral:  "coral",
+	},
+	Phases: map[game.Phase]string{
+		game.LOBBY:   "SALA",
+		game.TASKS:   "TAREAS",
+		game.DISCUSS: "DISCUSIÓN",
+		game.MENU:    "MENÚ",
+	},
+}
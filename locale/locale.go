@@ -0,0 +1,146 @@
+package locale
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/denverquane/amongusdiscord/game"
+)
+
+// DefaultLanguage is used for guilds that haven't picked a language, and for any message a catalog is missing
+const DefaultLanguage = English
+
+// Catalog holds the text of every message in one language, keyed by message ID. Messages are fmt format strings
+type Catalog struct {
+	//Name is the name of the language, in that language
+	Name     string
+	Messages map[string]string
+
+	//Colors and Phases are indexed by the game's color and phase constants
+	Colors map[int]string
+	Phases map[game.Phase]string
+}
+
+var catalogs = map[string]*Catalog{
+	English: &english,
+	Spanish: &spanish,
+}
+
+// IsSupported determines if there's a catalog for a language code
+func IsSupported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Languages returns the codes of every supported language, in alphabetical order
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for k := range catalogs {
+		langs = append(langs, k)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LanguageName returns the name of a language, in that language
+func LanguageName(lang string) string {
+	if c, ok := catalogs[lang]; ok {
+		return c.Name
+	}
+	return lang
+}
+
+func getCatalog(lang string) *Catalog {
+	if c, ok := catalogs[lang]; ok {
+		return c
+	}
+	return catalogs[DefaultLanguage]
+}
+
+// Get formats the message with the ID in the language, falling back to English, then to the ID itself
+func Get(lang, id string, args ...interface{}) string {
+	format, ok := getCatalog(lang).Messages[id]
+	if !ok {
+		format, ok = catalogs[DefaultLanguage].Messages[id]
+		if !ok {
+			return id
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Error is an error whose message is in the catalogs, so it can be shown in the language of whoever reads it
+type Error struct {
+	ID   string
+	Args []interface{}
+}
+
+// Errorf makes an Error from a message ID. Args that are errors are shown in the same language as the message
+func Errorf(id string, args ...interface{}) error {
+	return &Error{ID: id, Args: args}
+}
+
+// Error returns the message in the default language, for logs
+func (e *Error) Error() string {
+	return e.In(DefaultLanguage)
+}
+
+// In returns the message in the language
+func (e *Error) In(lang string) string {
+	args := make([]interface{}, len(e.Args))
+	for i, v := range e.Args {
+		if err, ok := v.(error); ok {
+			args[i] = ErrorText(lang, err)
+		} else {
+			args[i] = v
+		}
+	}
+	return Get(lang, e.ID, args...)
+}
+
+// ErrorText returns the message of an error in the language. Errors that didn't come from Errorf are shown as they are
+func ErrorText(lang string, err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.In(lang)
+	}
+	return err.Error()
+}
+
+// ColorName returns the name of a color in the language
+func ColorName(lang string, color int) string {
+	if name, ok := getCatalog(lang).Colors[color]; ok {
+		return name
+	}
+	return game.GetColorStringForInt(color)
+}
+
+// PhaseName returns the name of a phase in the language
+func PhaseName(lang string, phase game.Phase) string {
+	if name, ok := getCatalog(lang).Phases[phase]; ok {
+		return name
+	}
+	return string(game.PhaseNames[phase])
+}
+
+// accents are ignored when matching color names, so players don't need to type them
+var stripAccents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// ParseColor finds the color with the name in the language. English names are always accepted too
+func ParseColor(lang, name string) (int, bool) {
+	name = stripAccents.Replace(strings.ToLower(strings.TrimSpace(name)))
+	for color, v := range getCatalog(lang).Colors {
+		if stripAccents.Replace(v) == name {
+			return color, true
+		}
+	}
+	if color, ok := game.ColorStrings[name]; ok {
+		return color, true
+	}
+	return 0, false
+}
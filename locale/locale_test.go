@@ -0,0 +1,41 @@
+package locale
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Every catalog has to translate exactly the messages of the English one, so nothing falls back to English
+func TestCatalogsHaveTheSameMessages(t *testing.T) {
+	for _, lang := range Languages() {
+		if lang == English {
+			continue
+		}
+		catalog := catalogs[lang]
+		for id := range english.Messages {
+			if _, ok := catalog.Messages[id]; !ok {
+				t.Errorf("%s is missing %s", lang, id)
+			}
+		}
+		for id := range catalog.Messages {
+			if _, ok := english.Messages[id]; !ok {
+				t.Errorf("%s has %s, which English doesn't", lang, id)
+			}
+		}
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	inner := Errorf("error.noPreset", "comp")
+	err := fmt.Errorf("wrapped: %w", Errorf("error.inPhase", "TASKS", inner))
+
+	if got, want := ErrorText(Spanish, err), "TASKS: no hay ningún preset llamado `comp`"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := inner.Error(), "there's no preset named `comp`"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := ErrorText(Spanish, fmt.Errorf("plain")), "plain"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+// Package logger writes leveled log lines with fields (like the guild or game they're about), as text or JSON
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how important a log line is. Lines below the configured level aren't written
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseLevel accepts debug, info, warn (or warning) and error
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn":
+		fallthrough
+	case "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %s; use debug, info, warn or error", s)
+}
+
+// Format is how log lines are written
+type Format int
+
+const (
+	TextFormat Format = iota
+	JSONFormat
+)
+
+// ParseFormat accepts text and json
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+	return TextFormat, fmt.Errorf("unknown log format %s; use text or json", s)
+}
+
+// The fields most log lines are about
+const (
+	GuildKey   = "guild"
+	GameKey    = "game"
+	UserKey    = "user"
+	CaptureKey = "capture"
+)
+
+// Fields are attached to every line a Logger writes
+type Fields map[string]interface{}
+
+type config struct {
+	level  Level
+	format Format
+	out    io.Writer
+	lock   sync.Mutex
+}
+
+var std = &config{
+	level:  InfoLevel,
+	format: TextFormat,
+	out:    os.Stdout,
+}
+
+// Configure sets the level, format and output of every Logger
+func Configure(level Level, format Format, out io.Writer) {
+	std.lock.Lock()
+	std.level = level
+	std.format = format
+	std.out = out
+	std.lock.Unlock()
+}
+
+// IsEnabled is true when lines of the level are written, for skipping work that only a log line needs
+func IsEnabled(level Level) bool {
+	std.lock.Lock()
+	defer std.lock.Unlock()
+	return level >= std.level
+}
+
+// Logger writes log lines with its fields attached
+type Logger struct {
+	fields Fields
+}
+
+var root = &Logger{fields: Fields{}}
+
+// With returns a Logger with the fields attached
+func With(fields Fields) *Logger {
+	return root.With(fields)
+}
+
+// With returns a Logger with the fields attached, on top of this Logger's
+func (l *Logger) With(fields Fields) *Logger {
+	combined := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		combined[k] = v
+	}
+	for k, v := range fields {
+		combined[k] = v
+	}
+	return &Logger{fields: combined}
+}
+
+func (l *Logger) Debug(args ...interface{}) { l.write(DebugLevel, fmt.Sprint(args...)) }
+func (l *Logger) Info(args ...interface{})  { l.write(InfoLevel, fmt.Sprint(args...)) }
+func (l *Logger) Warn(args ...interface{})  { l.write(WarnLevel, fmt.Sprint(args...)) }
+func (l *Logger) Error(args ...interface{}) { l.write(ErrorLevel, fmt.Sprint(args...)) }
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.write(DebugLevel, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.write(InfoLevel, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.write(WarnLevel, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.write(ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func Debug(args ...interface{})                 { root.Debug(args...) }
+func Info(args ...interface{})                  { root.Info(args...) }
+func Warn(args ...interface{})                  { root.Warn(args...) }
+func Error(args ...interface{})                 { root.Error(args...) }
+func Debugf(format string, args ...interface{}) { root.Debugf(format, args...) }
+func Infof(format string, args ...interface{})  { root.Infof(format, args...) }
+func Warnf(format string, args ...interface{})  { root.Warnf(format, args...) }
+func Errorf(format string, args ...interface{}) { root.Errorf(format, args...) }
+
+func (l *Logger) write(level Level, msg string) {
+	std.lock.Lock()
+	defer std.lock.Unlock()
+	if level < std.level {
+		return
+	}
+	msg = strings.TrimRight(msg, "\n")
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer([]byte{})
+	if std.format == JSONFormat {
+		line := make(map[string]interface{}, len(l.fields)+3)
+		for k, v := range l.fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			line[k] = v
+		}
+		line["time"] = now
+		line["level"] = level.String()
+		line["msg"] = msg
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			encoded, _ = json.Marshal(map[string]string{"time": now, "level": level.String(), "msg": msg})
+		}
+		buf.Write(encoded)
+	} else {
+		buf.WriteString(fmt.Sprintf("%s %-5s %s", now, strings.ToUpper(level.String()), msg))
+		for _, k := range keys {
+			buf.WriteString(fmt.Sprintf(" %s=%s", k, formatTextValue(l.fields[k])))
+		}
+	}
+	buf.WriteByte('\n')
+	std.out.Write(buf.Bytes())
+}
+
+func formatTextValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \"=\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// stdWriter turns lines from the standard library's log package into info lines
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	root.write(InfoLevel, string(p))
+	return len(p), nil
+}
+
+// StdWriter is for log.SetOutput, so lines logged with the standard library (by dependencies too) are written
+// like every other line. Use it with log.SetFlags(0), since lines get their own timestamps
+func StdWriter() io.Writer {
+	return stdWriter{}
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+//capture configures logging into a buffer for the rest of the test
+func capture(t *testing.T, level Level, format Format) *bytes.Buffer {
+	buf := bytes.NewBuffer([]byte{})
+	Configure(level, format, buf)
+	t.Cleanup(func() {
+		Configure(InfoLevel, TextFormat, os.Stdout)
+	})
+	return buf
+}
+
+//lines splits what was logged into lines, without the timestamps text lines start with
+func lines(buf *bytes.Buffer) []string {
+	out := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		out = append(out, line[strings.Index(line, " ")+1:])
+	}
+	return out
+}
+
+func TestLevelFiltering(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  []string
+	}{
+		{DebugLevel, []string{"DEBUG debug", "INFO  info", "WARN  warn", "ERROR error"}},
+		{InfoLevel, []string{"INFO  info", "WARN  warn", "ERROR error"}},
+		{WarnLevel, []string{"WARN  warn", "ERROR error"}},
+		{ErrorLevel, []string{"ERROR error"}},
+		{ErrorLevel + 1, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			buf := capture(t, tt.level, TextFormat)
+			Debug("debug")
+			Info("info")
+			Warn("warn")
+			Error("error")
+
+			got := lines(buf)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel} {
+				if IsEnabled(level) != (level >= tt.level) {
+					t.Errorf("IsEnabled(%s) is %v", level, IsEnabled(level))
+				}
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"debug": DebugLevel, " INFO ": InfoLevel, "warn": WarnLevel, "Warning": WarnLevel, "error": ErrorLevel} {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %s, %v; want %s", s, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel accepted verbose")
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	buf := capture(t, DebugLevel, TextFormat)
+	l := With(Fields{GuildKey: "1", UserKey: "some user"}).With(Fields{GameKey: 2, "empty": "", "err": errors.New(`bad "thing"`)})
+	l.Warnf("%d things happened\n", 3)
+
+	want := `WARN  3 things happened empty="" err="bad \"thing\"" game=2 guild=1 user="some user"`
+	if got := lines(buf); len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	stamp := buf.String()[:strings.Index(buf.String(), " ")]
+	if _, err := time.Parse(time.RFC3339, stamp); err != nil {
+		t.Errorf("lines should start with an RFC 3339 time: %s", err)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf := capture(t, InfoLevel, JSONFormat)
+	With(Fields{GuildKey: "1", "err": errors.New("bad thing"), "count": 3}).Error("it broke")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%q isn't JSON: %s", buf.String(), err)
+	}
+	want := map[string]interface{}{"level": "error", "msg": "it broke", "guild": "1", "err": "bad thing", "count": float64(3)}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s is %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["time"]; !ok {
+		t.Error("there's no time")
+	}
+}
+
+func TestStdWriter(t *testing.T) {
+	buf := capture(t, InfoLevel, TextFormat)
+	std := log.New(StdWriter(), "", 0)
+	std.Println("from the standard library")
+
+	if got, want := lines(buf), "INFO  from the standard library"; len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
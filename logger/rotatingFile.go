@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file that's renamed to <path>.1 (and <path>.1 to <path>.2, and so on) when it gets too big,
+// keeping a number of old files. Unlike os.Create, opening it doesn't throw away the logs of the last run
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	file *os.File
+	size int64
+	lock sync.Mutex
+}
+
+// NewRotatingFile opens (or creates) the log file at path, appending to it
+func NewRotatingFile(path string, maxBytes int64, backups int) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxBytes: maxBytes, backups: backups}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		err := rf.rotate()
+		if err != nil {
+			//keep logging to the file we have, rather than losing lines
+			fmt.Fprintf(os.Stderr, "couldn't rotate log file %s: %s\n", rf.path, err)
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along, dropping the oldest, and starts a new file
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	if err != nil {
+		return err
+	}
+	if rf.backups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.backups))
+		for i := rf.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		err = os.Rename(rf.path, rf.path+".1")
+	} else {
+		err = os.Remove(rf.path)
+	}
+	openErr := rf.open()
+	if openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.file.Close()
+}
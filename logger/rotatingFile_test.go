@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//contents returns the contents of each file, or "-" for files that don't exist
+func contents(t *testing.T, paths ...string) []string {
+	out := make([]string, len(paths))
+	for i, v := range paths {
+		b, err := ioutil.ReadFile(v)
+		if os.IsNotExist(err) {
+			out[i] = "-"
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = string(b)
+	}
+	return out
+}
+
+func checkContents(t *testing.T, got, want []string) {
+	t.Helper()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d has %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRotatingFileKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	rf, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	//each line fills a file, so every write after the first rotates
+	for _, v := range []string{"line one\n", "line two\n", "line three\n", "line four\n"} {
+		if _, err := rf.Write([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkContents(t, contents(t, path, path+".1", path+".2", path+".3"), []string{"line four\n", "line three\n", "line two\n", "-"})
+}
+
+func TestRotatingFileFillsUpBeforeRotating(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	rf, err := NewRotatingFile(path, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, v := range []string{"abc\n", "def\n", "ghi\n"} {
+		rf.Write([]byte(v))
+	}
+	checkContents(t, contents(t, path, path+".1"), []string{"ghi\n", "abc\ndef\n"})
+}
+
+func TestRotatingFileAppendsToTheLastRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	if err := ioutil.WriteFile(path, []byte("last run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := NewRotatingFile(path, 16, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	rf.Write([]byte("this run\n"))
+	//the size of the file from the last run counts towards rotating
+	checkContents(t, contents(t, path, path+".1"), []string{"this run\n", "last run\n"})
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	rf, err := NewRotatingFile(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	//a line longer than the limit still goes in a file of its own, rather than being lost
+	rf.Write([]byte("a long line\n"))
+	rf.Write([]byte("next\n"))
+	checkContents(t, contents(t, path, path+".1"), []string{"next\n", "-"})
+}
+
+func TestRotatingFileWithoutLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	rf, err := NewRotatingFile(path, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for i := 0; i < 100; i++ {
+		rf.Write([]byte("line\n"))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 500 {
+		t.Errorf("the file is %d bytes, want 500", info.Size())
+	}
+	checkContents(t, contents(t, path+".1"), []string{"-"})
+}
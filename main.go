@@ -2,13 +2,17 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/denverquane/amongusdiscord/bus"
 	"github.com/denverquane/amongusdiscord/discord"
+	"github.com/denverquane/amongusdiscord/logger"
 	"github.com/joho/godotenv"
 )
 
@@ -19,9 +23,8 @@ const DefaultPort = "8123"
 func main() {
 	err := discordMainWrapper()
 	if err != nil {
-		log.Println("Program exited with the following error:")
-		log.Println(err)
-		log.Println("This window will automatically terminate in 10 seconds")
+		logger.Errorf("Program exited with the following error: %s", err)
+		logger.Error("This window will automatically terminate in 10 seconds")
 		time.Sleep(10 * time.Second)
 		return
 	}
@@ -32,23 +35,27 @@ func discordMainWrapper() error {
 	if err != nil {
 		err = godotenv.Load("final.txt")
 		if err != nil {
-			log.Println("Can't open env file, hopefully you're running in docker and have provided the DISCORD_BOT_TOKEN...")
+			logger.Warn("Can't open env file, hopefully you're running in docker and have provided the DISCORD_BOT_TOKEN...")
 		}
 	}
 
-	logEntry := os.Getenv("DISABLE_LOG_FILE")
-	if logEntry == "" {
-		file, err := os.Create("logs.txt")
-		if err != nil {
-			return err
-		}
-		mw := io.MultiWriter(os.Stdout, file)
-		log.SetOutput(mw)
+	err = setupLogging()
+	if err != nil {
+		return err
 	}
 
 	emojiGuildID := os.Getenv("EMOJI_GUILD_ID")
 
-	log.Println(VERSION)
+	//the admin API is only served when a token for it is provided
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+
+	//webhooks can only point at loopback or private addresses, like a receiver on the same machine, when this is set
+	allowPrivateWebhooks := os.Getenv("WEBHOOK_ALLOW_PRIVATE") != ""
+
+	//where the bot can be reached from outside, for the overlay links it hands out
+	publicURL := os.Getenv("PUBLIC_URL")
+
+	logger.Info(VERSION)
 
 	discordToken := os.Getenv("DISCORD_BOT_TOKEN")
 	if discordToken == "" {
@@ -58,11 +65,111 @@ func discordMainWrapper() error {
 	port := os.Getenv("SERVER_PORT")
 	num, err := strconv.Atoi(port)
 	if err != nil || num < 1000 || num > 9999 {
-		log.Printf("Invalid or no particular SERVER_PORT provided. Defaulting to %s\n", DefaultPort)
+		logger.Warnf("Invalid or no particular SERVER_PORT provided. Defaulting to %s", DefaultPort)
 		port = DefaultPort
 	}
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+
+	shards, err := setupSharding()
+	if err != nil {
+		return err
+	}
+
+	//the data of guilds that removed the bot is kept forever, unless GUILD_DATA_RETENTION_DAYS says otherwise
+	retention := discord.KeepGuildData
+	if days := os.Getenv("GUILD_DATA_RETENTION_DAYS"); days != "" {
+		num, err := strconv.Atoi(days)
+		if err != nil || num < 0 {
+			return errors.New("GUILD_DATA_RETENTION_DAYS has to be a number, 0 or more")
+		}
+		retention = time.Duration(num) * 24 * time.Hour
+	}
 
 	//start the discord bot
-	discord.MakeAndStartBot(discordToken, port, emojiGuildID)
+	discord.MakeAndStartBot(discordToken, port, emojiGuildID, adminToken, allowPrivateWebhooks, publicURL, shards, retention)
+	return nil
+}
+
+const BusTimeout = 5 * time.Second
+
+// setupSharding reads which shard this process runs from SHARD_ID and SHARD_COUNT. Sharded bots list the URL of
+// every shard's process in SHARD_URLS, in shard order, so captures can connect to any of them; BUS_SECRET signs what
+// they forward to each other
+func setupSharding() (discord.Sharding, error) {
+	shards := discord.Sharding{ID: 0, Count: 1, Bus: bus.NewMemory()}
+	if os.Getenv("SHARD_COUNT") == "" {
+		return shards, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("SHARD_COUNT"))
+	if err != nil || count < 1 {
+		return shards, errors.New("SHARD_COUNT has to be a number, 1 or more")
+	}
+	id, err := strconv.Atoi(os.Getenv("SHARD_ID"))
+	if err != nil || id < 0 || id >= count {
+		return shards, fmt.Errorf("SHARD_ID has to be a number from 0 to %d", count-1)
+	}
+	shards.ID, shards.Count = id, count
+
+	urls := os.Getenv("SHARD_URLS")
+	if urls == "" {
+		if count > 1 {
+			logger.Warn("No SHARD_URLS provided; captures will only be able to link to games on the shard they connect to")
+		}
+		return shards, nil
+	}
+	peers := map[string]string{}
+	for i, v := range strings.Split(urls, ",") {
+		peers[discord.ShardTopic(i)] = strings.TrimSpace(v)
+	}
+	if len(peers) != count {
+		return shards, fmt.Errorf("SHARD_URLS has %d URLs, but there are %d shards", len(peers), count)
+	}
+	secret := os.Getenv("BUS_SECRET")
+	if secret == "" {
+		return shards, errors.New("no BUS_SECRET provided; the shards need it to trust each other")
+	}
+	shards.Bus = bus.NewHTTP(peers, secret, BusTimeout)
+	return shards, nil
+}
+
+const DefaultLogFileMaxMB = 10
+const DefaultLogFileBackups = 5
+
+// setupLogging configures the level and format of the logs from LOG_LEVEL (debug, info, warn or error) and LOG_FORMAT
+// (text or json), and logs to logs.txt too, unless DISABLE_LOG_FILE is set. logs.txt is rotated once it reaches
+// LOG_FILE_MAX_MB, keeping LOG_FILE_BACKUPS old files
+func setupLogging() error {
+	level, err := logger.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil && os.Getenv("LOG_LEVEL") != "" {
+		logger.Warn(err)
+	}
+	format, err := logger.ParseFormat(os.Getenv("LOG_FORMAT"))
+	if err != nil && os.Getenv("LOG_FORMAT") != "" {
+		logger.Warn(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if os.Getenv("DISABLE_LOG_FILE") == "" {
+		maxMB, err := strconv.Atoi(os.Getenv("LOG_FILE_MAX_MB"))
+		if err != nil || maxMB < 1 {
+			maxMB = DefaultLogFileMaxMB
+		}
+		backups, err := strconv.Atoi(os.Getenv("LOG_FILE_BACKUPS"))
+		if err != nil || backups < 0 {
+			backups = DefaultLogFileBackups
+		}
+		file, err := logger.NewRotatingFile("logs.txt", int64(maxMB)*1024*1024, backups)
+		if err != nil {
+			return err
+		}
+		out = io.MultiWriter(os.Stdout, file)
+	}
+
+	logger.Configure(level, format, out)
+	//anything still logging through the standard library ends up in the same place, in the same format
+	log.SetFlags(0)
+	log.SetOutput(logger.StdWriter())
 	return nil
 }
@@ -0,0 +1,336 @@
+// Package metrics keeps counters, gauges and histograms, and serves them in the Prometheus text format. It's
+// deliberately small, so the bot doesn't need the Prometheus client library
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is anything that can write itself in the Prometheus text format
+type metric interface {
+	name() string
+	write(buf *bytes.Buffer)
+}
+
+var (
+	registry     = map[string]metric{}
+	registryLock sync.RWMutex
+)
+
+func register(m metric) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[m.name()]; ok {
+		panic("metric registered twice: " + m.name())
+	}
+	registry[m.name()] = m
+}
+
+// Handler serves every registered metric
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(Render())
+	})
+}
+
+// Render writes every registered metric in the Prometheus text format, ordered by name
+func Render() []byte {
+	registryLock.RLock()
+	names := make([]string, 0, len(registry))
+	for k := range registry {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, v := range names {
+		metrics[i] = registry[v]
+	}
+	registryLock.RUnlock()
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.Bytes()
+}
+
+// labelSet holds one value per label of a metric, for each combination of values seen so far
+type labelSet struct {
+	labels []string
+	values map[string][]string
+}
+
+func makeLabelSet(labels []string) labelSet {
+	return labelSet{labels: labels, values: map[string][]string{}}
+}
+
+// key returns the key of a combination of label values, remembering the combination
+func (ls *labelSet) key(values []string) string {
+	if len(values) != len(ls.labels) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(ls.labels), len(values)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := ls.values[k]; !ok {
+		ls.values[k] = append([]string{}, values...)
+	}
+	return k
+}
+
+// sortedKeys returns every combination of label values, so output is stable between scrapes
+func (ls *labelSet) sortedKeys() []string {
+	keys := make([]string, 0, len(ls.values))
+	for k := range ls.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(labels, values []string, extra ...string) string {
+	if len(labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)+len(extra)/2)
+	for i, v := range labels {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", v, escapeLabelValue(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", extra[i], escapeLabelValue(extra[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+//help text escapes the same characters as label values, except for quotes
+var helpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(buf *bytes.Buffer, name, help, kind string) {
+	buf.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, kind))
+}
+
+// Counter is a value that only goes up, like a number of events
+type Counter struct {
+	metricName string
+	help       string
+	labels     labelSet
+	counts     map[string]float64
+	lock       sync.Mutex
+}
+
+// NewCounter makes and registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metricName: name, help: help, labels: makeLabelSet(labels), counts: map[string]float64{}}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds to the counter with the given label values. Negative values are ignored
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.lock.Lock()
+	c.counts[c.labels.key(labelValues)] += v
+	c.lock.Unlock()
+}
+
+func (c *Counter) name() string {
+	return c.metricName
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeHeader(buf, c.metricName, c.help, "counter")
+	for _, k := range c.labels.sortedKeys() {
+		buf.WriteString(fmt.Sprintf("%s%s %s\n", c.metricName, formatLabels(c.labels.labels, c.labels.values[k]), formatValue(c.counts[k])))
+	}
+}
+
+// Gauge is a value that can go up and down, like a number of connections
+type Gauge struct {
+	metricName string
+	help       string
+	labels     labelSet
+	values     map[string]float64
+	lock       sync.Mutex
+}
+
+// NewGauge makes and registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{metricName: name, help: help, labels: makeLabelSet(labels), values: map[string]float64{}}
+	register(g)
+	return g
+}
+
+// Set sets the gauge with the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.lock.Lock()
+	g.values[g.labels.key(labelValues)] = v
+	g.lock.Unlock()
+}
+
+// Add adds to (or, with a negative value, subtracts from) the gauge with the given label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.lock.Lock()
+	g.values[g.labels.key(labelValues)] += v
+	g.lock.Unlock()
+}
+
+// Delete forgets the gauge with the given label values, like when the thing it measured is gone
+func (g *Gauge) Delete(labelValues ...string) {
+	g.lock.Lock()
+	k := g.labels.key(labelValues)
+	delete(g.values, k)
+	delete(g.labels.values, k)
+	g.lock.Unlock()
+}
+
+func (g *Gauge) name() string {
+	return g.metricName
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	writeHeader(buf, g.metricName, g.help, "gauge")
+	for _, k := range g.labels.sortedKeys() {
+		buf.WriteString(fmt.Sprintf("%s%s %s\n", g.metricName, formatLabels(g.labels.labels, g.labels.values[k]), formatValue(g.values[k])))
+	}
+}
+
+// Sample is one value of a GaugeFunc
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose values are collected when the metrics are scraped, like the length of a queue
+type GaugeFunc struct {
+	metricName string
+	help       string
+	labels     []string
+	collect    func() []Sample
+}
+
+// NewGaugeFunc makes and registers a gauge that calls collect for its values on every scrape
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, labels: labels, collect: collect}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(buf *bytes.Buffer) {
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+	writeHeader(buf, g.metricName, g.help, "gauge")
+	for _, v := range samples {
+		if len(v.LabelValues) != len(g.labels) {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%s%s %s\n", g.metricName, formatLabels(g.labels, v.LabelValues), formatValue(v.Value)))
+	}
+}
+
+// DefaultBuckets suit latencies of calls to discord, in seconds
+var DefaultBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogramValues struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations, like latencies, in buckets
+type Histogram struct {
+	metricName string
+	help       string
+	buckets    []float64
+	labels     labelSet
+	values     map[string]*histogramValues
+	lock       sync.Mutex
+}
+
+// NewHistogram makes and registers a histogram with the given bucket upper bounds and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &Histogram{metricName: name, help: help, buckets: b, labels: makeLabelSet(labels), values: map[string]*histogramValues{}}
+	register(h)
+	return h
+}
+
+// Observe records one observation with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	k := h.labels.key(labelValues)
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValues{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			hv.counts[i]++
+		}
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	writeHeader(buf, h.metricName, h.help, "histogram")
+	for _, k := range h.labels.sortedKeys() {
+		values := h.labels.values[k]
+		hv := h.values[k]
+		for i, bound := range h.buckets {
+			buf.WriteString(fmt.Sprintf("%s_bucket%s %d\n", h.metricName, formatLabels(h.labels.labels, values, "le", formatValue(bound)), hv.counts[i]))
+		}
+		buf.WriteString(fmt.Sprintf("%s_bucket%s %d\n", h.metricName, formatLabels(h.labels.labels, values, "le", "+Inf"), hv.count))
+		buf.WriteString(fmt.Sprintf("%s_sum%s %s\n", h.metricName, formatLabels(h.labels.labels, values), formatValue(hv.sum)))
+		buf.WriteString(fmt.Sprintf("%s_count%s %d\n", h.metricName, formatLabels(h.labels.labels, values), hv.count))
+	}
+}
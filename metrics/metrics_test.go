@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+//isolate gives the test a registry of its own, so the same metrics can be registered each time it runs
+func isolate(t *testing.T) {
+	registryLock.Lock()
+	saved := registry
+	registry = map[string]metric{}
+	registryLock.Unlock()
+	t.Cleanup(func() {
+		registryLock.Lock()
+		registry = saved
+		registryLock.Unlock()
+	})
+}
+
+func render(m metric) string {
+	buf := bytes.NewBuffer([]byte{})
+	m.write(buf)
+	return buf.String()
+}
+
+func checkOutput(t *testing.T, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	isolate(t)
+	c := NewCounter("test_events_total", "Events seen.", "guild", "kind")
+	c.Inc("2", "phase")
+	c.Add(2.5, "1", "player")
+	c.Inc("1", "player")
+	//counters only go up
+	c.Add(-10, "1", "player")
+
+	checkOutput(t, render(c), `# HELP test_events_total Events seen.
+# TYPE test_events_total counter
+test_events_total{guild="1",kind="player"} 3.5
+test_events_total{guild="2",kind="phase"} 1
+`)
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	isolate(t)
+	c := NewCounter("test_plain_total", "No labels.")
+	checkOutput(t, render(c), "# HELP test_plain_total No labels.\n# TYPE test_plain_total counter\n")
+	c.Inc()
+	checkOutput(t, render(c), "# HELP test_plain_total No labels.\n# TYPE test_plain_total counter\ntest_plain_total 1\n")
+}
+
+func TestGauge(t *testing.T) {
+	isolate(t)
+	g := NewGauge("test_connections", "Open connections.", "guild")
+	g.Set(3, "1")
+	g.Add(-1, "1")
+	g.Add(1, "2")
+	g.Set(math.Inf(1), "3")
+	g.Set(5, "4")
+	g.Delete("4")
+
+	checkOutput(t, render(g), `# HELP test_connections Open connections.
+# TYPE test_connections gauge
+test_connections{guild="1"} 2
+test_connections{guild="2"} 1
+test_connections{guild="3"} +Inf
+`)
+}
+
+func TestGaugeFunc(t *testing.T) {
+	isolate(t)
+	g := NewGaugeFunc("test_queue_length", "Queued updates.", []string{"guild", "kind"}, func() []Sample {
+		return []Sample{
+			{LabelValues: []string{"2", "phase"}, Value: 4},
+			{LabelValues: []string{"1", "player"}, Value: 0.5},
+			//samples with the wrong number of label values are skipped
+			{LabelValues: []string{"3"}, Value: 1},
+		}
+	})
+
+	checkOutput(t, render(g), `# HELP test_queue_length Queued updates.
+# TYPE test_queue_length gauge
+test_queue_length{guild="1",kind="player"} 0.5
+test_queue_length{guild="2",kind="phase"} 4
+`)
+}
+
+func TestHistogram(t *testing.T) {
+	isolate(t)
+	//buckets are sorted, whatever order they're given in
+	h := NewHistogram("test_latency_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(v, "patch")
+	}
+	h.Observe(0.7, "get")
+
+	checkOutput(t, render(h), `# HELP test_latency_seconds Request latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="get",le="0.1"} 0
+test_latency_seconds_bucket{route="get",le="0.5"} 0
+test_latency_seconds_bucket{route="get",le="1"} 1
+test_latency_seconds_bucket{route="get",le="+Inf"} 1
+test_latency_seconds_sum{route="get"} 0.7
+test_latency_seconds_count{route="get"} 1
+test_latency_seconds_bucket{route="patch",le="0.1"} 2
+test_latency_seconds_bucket{route="patch",le="0.5"} 3
+test_latency_seconds_bucket{route="patch",le="1"} 3
+test_latency_seconds_bucket{route="patch",le="+Inf"} 4
+test_latency_seconds_sum{route="patch"} 2.45
+test_latency_seconds_count{route="patch"} 4
+`)
+}
+
+func TestEscaping(t *testing.T) {
+	isolate(t)
+	c := NewCounter("test_escaped_total", "Help with a \\ and\na newline; \"quotes\" are fine.", "name")
+	c.Inc("a \"quoted\" \\ name\non two lines")
+
+	checkOutput(t, render(c), `# HELP test_escaped_total Help with a \\ and\na newline; "quotes" are fine.
+# TYPE test_escaped_total counter
+test_escaped_total{name="a \"quoted\" \\ name\non two lines"} 1
+`)
+}
+
+func TestHandler(t *testing.T) {
+	isolate(t)
+	g := NewGauge("test_handler_b", "Second.")
+	g.Set(2)
+	c := NewCounter("test_handler_a", "First.")
+	c.Inc()
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; got != want {
+		t.Errorf("content type is %q, want %q", got, want)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every metric is rendered, ordered by name
+	a := bytes.Index(body, []byte(render(c)))
+	b := bytes.Index(body, []byte(render(g)))
+	if a == -1 || b == -1 || a > b {
+		t.Errorf("metrics are missing or out of order:\n%s", body)
+	}
+}
+
+func TestRegisteringTwicePanics(t *testing.T) {
+	isolate(t)
+	NewCounter("test_twice_total", "Registered twice.")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a metric twice didn't panic")
+		}
+	}()
+	NewGauge("test_twice_total", "Registered twice.")
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// DeadLetters keeps the most recent deliveries that were given up on, in a file, so they can be looked at and retried
+type DeadLetters struct {
+	path    string
+	max     int
+	letters []Delivery
+	lock    sync.Mutex
+}
+
+// LoadDeadLetters reads the dead letters kept in the file, if there is one, keeping at most max
+func LoadDeadLetters(path string, max int) *DeadLetters {
+	dl := &DeadLetters{path: path, max: max, letters: []Delivery{}}
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		//a broken file isn't worth failing over; it gets replaced with the next dead letter
+		json.Unmarshal(b, &dl.letters)
+	}
+	return dl
+}
+
+// Add records a delivery, dropping the oldest if there are too many
+func (dl *DeadLetters) Add(d Delivery) error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	dl.letters = append(dl.letters, d)
+	if over := len(dl.letters) - dl.max; over > 0 {
+		dl.letters = dl.letters[over:]
+	}
+	return dl.save()
+}
+
+// All returns the dead letters, oldest first
+func (dl *DeadLetters) All() []Delivery {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	letters := make([]Delivery, len(dl.letters))
+	copy(letters, dl.letters)
+	return letters
+}
+
+// Take removes and returns every dead letter, to retry them
+func (dl *DeadLetters) Take() ([]Delivery, error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	letters := dl.letters
+	dl.letters = []Delivery{}
+	return letters, dl.save()
+}
+
+func (dl *DeadLetters) Len() int {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	return len(dl.letters)
+}
+
+//save must be called with the lock held
+func (dl *DeadLetters) save() error {
+	b, err := json.MarshalIndent(dl.letters, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dl.path, b, os.ModePerm)
+}
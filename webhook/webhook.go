@@ -0,0 +1,287 @@
+// Package webhook delivers HMAC-signed JSON POSTs, retrying failures and keeping a record of the ones that never
+// got through
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// SignatureHeader is the HMAC-SHA256 of the body keyed with the webhook's secret, like "sha256=<hex>"
+	SignatureHeader = "X-AutoMute-Signature"
+	// EventHeader is the type of event in the body
+	EventHeader = "X-AutoMute-Event"
+	// DeliveryHeader is the ID of the delivery, which stays the same across retries
+	DeliveryHeader = "X-AutoMute-Delivery"
+)
+
+// Sign returns the signature of the body, as sent in SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is true if the signature is the body's, for receivers to check deliveries came from the bot
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// NewSecret makes a random secret for signing a webhook's deliveries
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewDeliveryID makes a random ID for a delivery, so receivers can tell retries of it apart from new deliveries
+func NewDeliveryID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Delivery is one body to be POSTed to a webhook
+type Delivery struct {
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhookID"`
+	Event     string          `json:"event"`
+	Body      json.RawMessage `json:"body"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	FailedAt  time.Time       `json:"failedAt,omitempty"`
+
+	//the URL and secret aren't kept in dead letters; they're looked up again if the delivery is retried
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// Result is what came of one attempt at a delivery
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+// Retryable is true for failures that might go away, like timeouts and server errors
+func (r Result) Retryable() bool {
+	if r.Err != nil {
+		return !errors.Is(r.Err, ErrPrivateAddress)
+	}
+	return r.StatusCode >= 500 || r.StatusCode == http.StatusTooManyRequests || r.StatusCode == http.StatusRequestTimeout
+}
+
+// OK is true if the receiver accepted the delivery
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return fmt.Sprintf("HTTP %d", r.StatusCode)
+}
+
+// ErrPrivateAddress is returned for webhooks pointing at loopback or private addresses, unless they're allowed
+var ErrPrivateAddress = errors.New("webhooks can't be sent to private addresses")
+
+// NewClient makes the client deliveries are sent with. Unless allowPrivate is set, it refuses to connect to loopback,
+// private and link-local addresses, so webhooks can't be used to reach the bot's own network. The check happens when
+// connecting, so it can't be dodged with DNS
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if isPrivate(net.ParseIP(host)) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		//a redirect could point anywhere; receivers should give the final URL instead
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+var privateRanges = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, v := range cidrs {
+		_, nets[i], _ = net.ParseCIDR(v)
+	}
+	return nets
+}
+
+func isPrivate(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, v := range privateRanges {
+		if v.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Post makes one attempt at a delivery
+func Post(client *http.Client, d Delivery) Result {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "amongusdiscord-webhook")
+	req.Header.Set(SignatureHeader, Sign(d.Secret, d.Body))
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	//read a little of the body, so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	return Result{StatusCode: resp.StatusCode}
+}
+
+// Sender delivers to one webhook in order, one delivery at a time, retrying failures with exponential backoff
+type Sender struct {
+	client      *http.Client
+	queue       chan Delivery
+	maxAttempts int
+	backoff     time.Duration
+
+	//called with every attempt, and with deliveries that are given up on
+	onAttempt func(Delivery, Result)
+	onDead    func(Delivery)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSender starts a sender with room for queueSize deliveries, which tries each one maxAttempts times, waiting
+// backoff, then twice that, and so on, between attempts
+func NewSender(client *http.Client, queueSize, maxAttempts int, backoff time.Duration, onAttempt func(Delivery, Result), onDead func(Delivery)) *Sender {
+	s := &Sender{
+		client:      client,
+		queue:       make(chan Delivery, queueSize),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		onAttempt:   onAttempt,
+		onDead:      onDead,
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Enqueue queues a delivery without blocking. It's false if the queue is full, or the sender is closed
+func (s *Sender) Enqueue(d Delivery) bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	select {
+	case s.queue <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+// Len is how many deliveries are waiting
+func (s *Sender) Len() int {
+	return len(s.queue)
+}
+
+// Close stops the sender. Deliveries still waiting are dropped
+func (s *Sender) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *Sender) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case d := <-s.queue:
+			s.deliver(d)
+		}
+	}
+}
+
+func (s *Sender) deliver(d Delivery) {
+	wait := s.backoff
+	for {
+		d.Attempts++
+		result := Post(s.client, d)
+		if s.onAttempt != nil {
+			s.onAttempt(d, result)
+		}
+		if result.OK() {
+			return
+		}
+		d.LastError = result.String()
+		if !result.Retryable() || d.Attempts >= s.maxAttempts {
+			d.FailedAt = time.Now()
+			if s.onDead != nil {
+				s.onDead(d)
+			}
+			return
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// HostOf is the host of a webhook's URL, for showing which webhook is which without showing any token in its path
+func HostOf(url string) string {
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	if i := strings.IndexAny(url, "/?#"); i >= 0 {
+		url = url[:i]
+	}
+	if i := strings.LastIndex(url, "@"); i >= 0 {
+		url = url[i+1:]
+	}
+	return url
+}
@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"test"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	signature := Sign("secret", body)
+	if signature != want {
+		t.Errorf("Sign returned %s, want %s", signature, want)
+	}
+	if !Verify("secret", body, signature) {
+		t.Error("the signature didn't verify")
+	}
+	for name, ok := range map[string]bool{
+		"wrong secret":             Verify("other", body, signature),
+		"changed body":             Verify("secret", []byte(`{"type":"tset"}`), signature),
+		"no prefix":                Verify("secret", body, signature[len("sha256="):]),
+		"no signature":             Verify("secret", body, ""),
+		"another body's signature": Verify("secret", body, Sign("secret", []byte("other"))),
+	} {
+		if ok {
+			t.Errorf("a signature with the %s verified", name)
+		}
+	}
+}
+
+func TestPostSignsDeliveries(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{r.Header, body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := Delivery{ID: "delivery", Event: "phase", Body: []byte(`{"phase":"TASKS"}`), URL: server.URL, Secret: "secret"}
+	result := Post(NewClient(time.Second, true), d)
+	if !result.OK() {
+		t.Fatalf("the delivery failed: %s", result)
+	}
+	r := <-requests
+	if !Verify("secret", r.body, r.header.Get(SignatureHeader)) {
+		t.Error("the receiver couldn't verify the delivery")
+	}
+	for header, want := range map[string]string{EventHeader: "phase", DeliveryHeader: "delivery", "Content-Type": "application/json"} {
+		if got := r.header.Get(header); got != want {
+			t.Errorf("%s is %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		result    Result
+		ok        bool
+		retryable bool
+	}{
+		{Result{StatusCode: 200}, true, false},
+		{Result{StatusCode: 204}, true, false},
+		{Result{StatusCode: 301}, false, false},
+		{Result{StatusCode: 400}, false, false},
+		{Result{StatusCode: 401}, false, false},
+		{Result{StatusCode: 404}, false, false},
+		{Result{StatusCode: 408}, false, true},
+		{Result{StatusCode: 429}, false, true},
+		{Result{StatusCode: 500}, false, true},
+		{Result{StatusCode: 503}, false, true},
+		{Result{Err: errors.New("connection refused")}, false, true},
+		{Result{Err: fmt.Errorf("dial: %w", ErrPrivateAddress)}, false, false},
+	}
+	for _, tt := range tests {
+		if tt.result.OK() != tt.ok || tt.result.Retryable() != tt.retryable {
+			t.Errorf("%s: OK %v and Retryable %v, want %v and %v", tt.result, tt.result.OK(), tt.result.Retryable(), tt.ok, tt.retryable)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback address")
+	}))
+	defer server.Close()
+
+	result := Post(NewClient(time.Second, false), Delivery{URL: server.URL})
+	if !errors.Is(result.Err, ErrPrivateAddress) || result.Retryable() {
+		t.Errorf("got %s, want a failure that isn't retried", result)
+	}
+}
+
+//recorder collects what a sender does with its deliveries
+type recorder struct {
+	attempts []time.Time
+	dead     []Delivery
+	done     chan struct{}
+	lock     sync.Mutex
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan struct{}, 10)}
+}
+
+func (r *recorder) onAttempt(d Delivery, result Result) {
+	r.lock.Lock()
+	r.attempts = append(r.attempts, time.Now())
+	r.lock.Unlock()
+	if result.OK() {
+		r.done <- struct{}{}
+	}
+}
+
+func (r *recorder) onDead(d Delivery) {
+	r.lock.Lock()
+	r.dead = append(r.dead, d)
+	r.lock.Unlock()
+	r.done <- struct{}{}
+}
+
+func (r *recorder) wait(t *testing.T) {
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delivery was never finished")
+	}
+}
+
+//failingServer answers with status for the first failures requests, then with 200
+func failingServer(failures, status int) *httptest.Server {
+	count := 0
+	lock := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		count++
+		n := count
+		lock.Unlock()
+		if n <= failures {
+			w.WriteHeader(status)
+		}
+	}))
+}
+
+func TestSenderRetriesWithBackoff(t *testing.T) {
+	server := failingServer(2, http.StatusServiceUnavailable)
+	defer server.Close()
+	rec := newRecorder()
+	const backoff = 20 * time.Millisecond
+	sender := NewSender(NewClient(time.Second, true), 10, 5, backoff, rec.onAttempt, rec.onDead)
+	defer sender.Close()
+
+	if !sender.Enqueue(Delivery{URL: server.URL}) {
+		t.Fatal("the delivery wasn't queued")
+	}
+	rec.wait(t)
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if len(rec.attempts) != 3 || len(rec.dead) != 0 {
+		t.Fatalf("%d attempts and %d dead letters, want 3 and 0", len(rec.attempts), len(rec.dead))
+	}
+	//the wait doubles after every failure
+	if gap := rec.attempts[1].Sub(rec.attempts[0]); gap < backoff {
+		t.Errorf("the first retry came after %s, want at least %s", gap, backoff)
+	}
+	if gap := rec.attempts[2].Sub(rec.attempts[1]); gap < 2*backoff {
+		t.Errorf("the second retry came after %s, want at least %s", gap, 2*backoff)
+	}
+}
+
+func TestSenderGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+	}{
+		{"after the last attempt", http.StatusInternalServerError, 3},
+		{"right away when retrying won't help", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := failingServer(100, tt.status)
+			defer server.Close()
+			rec := newRecorder()
+			sender := NewSender(NewClient(time.Second, true), 10, 3, time.Millisecond, rec.onAttempt, rec.onDead)
+			defer sender.Close()
+
+			sender.Enqueue(Delivery{ID: "delivery", URL: server.URL})
+			rec.wait(t)
+
+			rec.lock.Lock()
+			defer rec.lock.Unlock()
+			if len(rec.dead) != 1 {
+				t.Fatalf("%d dead letters, want 1", len(rec.dead))
+			}
+			d := rec.dead[0]
+			if d.ID != "delivery" || d.Attempts != tt.attempts || d.LastError != fmt.Sprintf("HTTP %d", tt.status) || d.FailedAt.IsZero() {
+				t.Errorf("got dead letter %+v, want %d attempts ending in HTTP %d", d, tt.attempts, tt.status)
+			}
+		})
+	}
+}
+
+func TestSenderQueue(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	rec := newRecorder()
+	sender := NewSender(NewClient(time.Second, true), 2, 1, time.Millisecond, rec.onAttempt, rec.onDead)
+
+	//one delivery is being sent, and two wait
+	queued := 0
+	for i := 0; i < 10; i++ {
+		if sender.Enqueue(Delivery{URL: server.URL}) {
+			queued++
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if queued != 3 || sender.Len() != 2 {
+		t.Errorf("%d deliveries were queued and %d are waiting, want 3 and 2", queued, sender.Len())
+	}
+	sender.Close()
+	if sender.Enqueue(Delivery{URL: server.URL}) {
+		t.Error("a closed sender queued a delivery")
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.json")
+	dl := LoadDeadLetters(path, 2)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := dl.Add(Delivery{ID: id, Body: []byte(`{}`), URL: "https://example.com", Secret: "secret"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//only the newest are kept, and they survive a restart without their URL and secret
+	loaded := LoadDeadLetters(path, 2).All()
+	if len(loaded) != 2 || loaded[0].ID != "2" || loaded[1].ID != "3" {
+		t.Fatalf("got %+v, want deliveries 2 and 3", loaded)
+	}
+	if loaded[0].URL != "" || loaded[0].Secret != "" {
+		t.Error("the URL and secret were saved")
+	}
+
+	taken, err := dl.Take()
+	if err != nil || len(taken) != 2 || dl.Len() != 0 || LoadDeadLetters(path, 2).Len() != 0 {
+		t.Errorf("took %d with error %v, and %d are left", len(taken), err, dl.Len())
+	}
+}